@@ -0,0 +1,51 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package entities
+
+import (
+	"errors"
+	"sync"
+)
+
+// AckFunc is the callback invoked once an event has been processed: err is nil when the event
+// has been successfully handled, otherwise it is the reason why the event should be delivered again.
+type AckFunc func(err error)
+
+// SplitAck returns n callbacks that call ack only once all of them have been invoked, with the
+// join of the errors they received. It is used when the processing of an event is split in
+// multiple parts, such as an event sent to multiple pipelines. Each returned callback must
+// be invoked once, further calls are ignored.
+func SplitAck(ack AckFunc, n int) []AckFunc {
+	if n <= 0 {
+		ack(nil)
+		return nil
+	}
+
+	var (
+		mtx     sync.Mutex
+		pending = n
+		errs    = make([]error, 0)
+	)
+
+	acks := make([]AckFunc, n)
+	for i := range acks {
+		var once sync.Once
+		acks[i] = func(err error) {
+			once.Do(func() {
+				mtx.Lock()
+				if err != nil {
+					errs = append(errs, err)
+				}
+				pending--
+				done := pending == 0
+				mtx.Unlock()
+
+				if done {
+					ack(errors.Join(errs...))
+				}
+			})
+		}
+	}
+	return acks
+}
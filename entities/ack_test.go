@@ -0,0 +1,72 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package entities
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitAck(t *testing.T) {
+	t.Run("ack is invoked once all the parts are acked", func(t *testing.T) {
+		invocations := 0
+		var ackErr error
+		acks := SplitAck(func(err error) {
+			invocations++
+			ackErr = err
+		}, 3)
+		require.Len(t, acks, 3)
+
+		acks[0](nil)
+		acks[1](nil)
+		// further calls are ignored
+		acks[1](errors.New("ignored"))
+		require.Zero(t, invocations)
+
+		acks[2](nil)
+		require.Equal(t, 1, invocations)
+		require.NoError(t, ackErr)
+	})
+
+	t.Run("ack receives the errors of all the parts", func(t *testing.T) {
+		var ackErr error
+		acks := SplitAck(func(err error) { ackErr = err }, 3)
+
+		acks[0](errors.New("first error"))
+		acks[1](nil)
+		acks[2](errors.New("second error"))
+		require.EqualError(t, ackErr, "first error\nsecond error")
+	})
+
+	t.Run("ack is invoked immediately without parts", func(t *testing.T) {
+		invoked := false
+		acks := SplitAck(func(err error) {
+			require.NoError(t, err)
+			invoked = true
+		}, 0)
+		require.Empty(t, acks)
+		require.True(t, invoked)
+	})
+}
+
+func TestEventAck(t *testing.T) {
+	e := &Event{PrimaryKeys: PkFields{{Key: "test", Value: "test"}}}
+	// without callback Ack is a no-op
+	e.Ack(nil)
+
+	require.Nil(t, e.GetAck())
+
+	var ackErr error
+	e.WithAck(func(err error) { ackErr = err })
+	e.Ack(errors.New("some error"))
+	require.EqualError(t, ackErr, "some error")
+	require.NotNil(t, e.GetAck())
+
+	cloned := e.Clone()
+	ackErr = nil
+	cloned.Ack(errors.New("clone error"))
+	require.NoError(t, ackErr, "the ack callback is not copied to the clone")
+}
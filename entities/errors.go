@@ -5,4 +5,10 @@ package entities
 
 import "errors"
 
-var ErrDiscardEvent = errors.New("event discarded")
+var (
+	ErrDiscardEvent   = errors.New("event discarded")
+	ErrMultipleEvents = errors.New("processor returned multiple events")
+	// ErrRetryable is wrapped by the errors of the processors that could succeed if the event is
+	// processed again, as the failures of a remote service.
+	ErrRetryable = errors.New("retryable")
+)
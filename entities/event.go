@@ -47,8 +47,18 @@ type PipelineEvent interface {
 	Data() []byte
 	Operation() Operation
 	WithData([]byte)
+	WithPrimaryKeys(PkFields)
+	WithOperation(Operation)
 	JSON() (map[string]any, error)
 	Clone() PipelineEvent
+
+	// Ack notifies the source that the event has been processed. A nil error acknowledges it,
+	// otherwise the event has not been written and the source should deliver it again.
+	Ack(err error)
+	// WithAck sets the callback invoked by Ack.
+	WithAck(ack AckFunc)
+	// GetAck returns the callback invoked by Ack, or nil if not set, so that it can be wrapped.
+	GetAck() AckFunc
 }
 
 type EventBuilder interface {
@@ -62,6 +72,7 @@ type Event struct {
 
 	OriginalRaw []byte
 	jsonData    map[string]any
+	ack         AckFunc
 }
 
 func (e Event) GetPrimaryKeys() PkFields {
@@ -93,6 +104,30 @@ func (e *Event) WithData(raw []byte) {
 	e.OriginalRaw = raw
 }
 
+func (e *Event) WithPrimaryKeys(primaryKeys PkFields) {
+	e.PrimaryKeys = primaryKeys
+}
+
+func (e *Event) WithOperation(operation Operation) {
+	e.OperationType = operation
+}
+
+func (e *Event) Ack(err error) {
+	if e.ack != nil {
+		e.ack(err)
+	}
+}
+
+func (e *Event) WithAck(ack AckFunc) {
+	e.ack = ack
+}
+
+func (e *Event) GetAck() AckFunc {
+	return e.ack
+}
+
+// Clone returns a copy of the event without its ack callback, which must be set explicitly
+// on the copy if needed.
 func (e *Event) Clone() PipelineEvent {
 	return &Event{
 		PrimaryKeys:   e.PrimaryKeys,
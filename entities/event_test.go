@@ -35,6 +35,12 @@ func TestEvent(t *testing.T) {
 	cloneParsed, err := e.JSON()
 	require.Equal(t, map[string]any{"test": "test2"}, cloneParsed)
 	require.NoError(t, err)
+
+	e.WithPrimaryKeys(PkFields{{Key: "id", Value: "new-id"}})
+	e.WithOperation(Delete)
+	require.Equal(t, PkFields{{Key: "id", Value: "new-id"}}, e.GetPrimaryKeys())
+	require.Equal(t, Delete, e.Operation())
+	require.Equal(t, PkFields{{Key: "test", Value: "test"}}, eventCloned.GetPrimaryKeys())
 }
 
 func TestPkField(t *testing.T) {
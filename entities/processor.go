@@ -3,10 +3,49 @@
 
 package entities
 
+import (
+	"fmt"
+	"time"
+)
+
 type Processor interface {
 	Process(data PipelineEvent) (PipelineEvent, error)
 }
 
+// MultiProcessor is implemented by the processors returning zero or more events for each event,
+// such as the ones splitting or aggregating the events: the pipeline calls ProcessEvents instead
+// of Process, and applies the next processors to each returned event.
+type MultiProcessor interface {
+	Processor
+	ProcessEvents(data PipelineEvent) ([]PipelineEvent, error)
+}
+
+// FlushingProcessor is implemented by the processors buffering the events, which are returned
+// by Flush once ready. The pipeline calls Flush every FlushInterval, and a last time with final
+// set when it is closed, to get all the buffered events.
+type FlushingProcessor interface {
+	MultiProcessor
+	Flush(now time.Time, final bool) ([]PipelineEvent, error)
+	FlushInterval() time.Duration
+}
+
+// ProcessOne implements Process for a MultiProcessor: it fails if ProcessEvents returns more
+// than one event, and discards the event if none is returned.
+func ProcessOne(processor MultiProcessor, data PipelineEvent) (PipelineEvent, error) {
+	events, err := processor.ProcessEvents(data)
+	if err != nil {
+		return nil, err
+	}
+	switch len(events) {
+	case 0:
+		return nil, ErrDiscardEvent
+	case 1:
+		return events[0], nil
+	default:
+		return nil, fmt.Errorf("%w: %d events", ErrMultipleEvents, len(events))
+	}
+}
+
 type Initializable interface {
 	Init(config []byte) error
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azeventhubs/v2"
+	"github.com/mia-platform/integration-connector-agent/entities"
 	"github.com/mia-platform/integration-connector-agent/internal/config"
 )
 
@@ -82,7 +83,9 @@ func (c *AuthConfig) AzureTokenProvider() (azcore.TokenCredential, error) {
 	return azidentity.NewChainedTokenCredential(credentials, nil)
 }
 
-type EventConsumer func(event *azeventhubs.ReceivedEventData) error
+// EventConsumer handles an event received from the Event Hub. The partition checkpoint advances
+// past the event only once ack is invoked without error.
+type EventConsumer func(event *azeventhubs.ReceivedEventData, ack entities.AckFunc) error
 
 type EventHubConfig struct {
 	AuthConfig
@@ -17,6 +17,8 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+var errEventNotProcessed = errors.New("event not processed, it will be received again")
+
 func NewConsumerClient(config azure.EventHubConfig, consumerGroup string) (*azeventhubs.ConsumerClient, error) {
 	credentials, err := config.AzureTokenProvider()
 	if err != nil {
@@ -180,13 +182,29 @@ func processEventsForPartition(ctx context.Context, partitionClient *azeventhubs
 			return err
 		}
 
+		acks := make([]chan error, 0, len(events))
 		for _, event := range events {
-			err := consumer(event)
-			if err != nil {
+			ack := make(chan error, 1)
+			if err := consumer(event, func(err error) { ack <- err }); err != nil {
 				return err
 			}
+			acks = append(acks, ack)
+		}
+
+		// the checkpoint advances only past the events successfully processed: on failure the
+		// partition client is closed, so that the partition is claimed again and its events are
+		// received again starting from the last checkpoint
+		for i, ack := range acks {
+			select {
+			case err := <-ack:
+				if err != nil {
+					return fmt.Errorf("%w: %w", errEventNotProcessed, err)
+				}
+			case <-ctx.Done():
+				return nil
+			}
 
-			if err := partitionClient.UpdateCheckpoint(ctx, event, nil); err != nil {
+			if err := partitionClient.UpdateCheckpoint(ctx, events[i], nil); err != nil {
 				return err
 			}
 		}
@@ -0,0 +1,112 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is an in-memory cache holding at most maxEntries values, each for ttl since it was added.
+// When full, the least recently used value is evicted. It is safe for concurrent use.
+type LRU[V any] struct {
+	mtx sync.Mutex
+
+	maxEntries int
+	ttl        time.Duration
+	now        func() time.Time
+
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type entry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+// NewLRU returns a cache of maxEntries values. A ttl of zero keeps the values until evicted.
+func NewLRU[V any](maxEntries int, ttl time.Duration) *LRU[V] {
+	return &LRU[V]{
+		maxEntries: max(maxEntries, 1),
+		ttl:        ttl,
+		now:        time.Now,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns the value of key, if it is in the cache and not expired.
+func (c *LRU[V]) Get(key string) (V, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+
+	e := element.Value.(*entry[V])
+	if c.expired(e) {
+		c.remove(element)
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(element)
+	return e.value, true
+}
+
+// Add sets the value of key, resetting its expiration.
+func (c *LRU[V]) Add(key string, value V) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var expiresAt time.Time
+	if c.ttl > 0 {
+		expiresAt = c.now().Add(c.ttl)
+	}
+
+	if element, ok := c.entries[key]; ok {
+		e := element.Value.(*entry[V])
+		e.value = value
+		e.expiresAt = expiresAt
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry[V]{key: key, value: value, expiresAt: expiresAt})
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+// Remove removes key from the cache.
+func (c *LRU[V]) Remove(key string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+}
+
+// Len returns the number of values in the cache, including the expired ones not evicted yet.
+func (c *LRU[V]) Len() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.order.Len()
+}
+
+func (c *LRU[V]) expired(e *entry[V]) bool {
+	return !e.expiresAt.IsZero() && !c.now().Before(e.expiresAt)
+}
+
+func (c *LRU[V]) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*entry[V]).key)
+}
@@ -0,0 +1,82 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package cache
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRU(t *testing.T) {
+	t.Run("get added values", func(t *testing.T) {
+		c := NewLRU[string](2, 0)
+
+		_, ok := c.Get("missing")
+		require.False(t, ok)
+
+		c.Add("a", "1")
+		c.Add("a", "2")
+		value, ok := c.Get("a")
+		require.True(t, ok)
+		require.Equal(t, "2", value)
+		require.Equal(t, 1, c.Len())
+
+		c.Remove("a")
+		_, ok = c.Get("a")
+		require.False(t, ok)
+	})
+
+	t.Run("evicts the least recently used value", func(t *testing.T) {
+		c := NewLRU[int](2, 0)
+		c.Add("a", 1)
+		c.Add("b", 2)
+		_, ok := c.Get("a")
+		require.True(t, ok)
+
+		c.Add("c", 3)
+		require.Equal(t, 2, c.Len())
+		_, ok = c.Get("b")
+		require.False(t, ok, "b is the least recently used")
+		_, ok = c.Get("a")
+		require.True(t, ok)
+		_, ok = c.Get("c")
+		require.True(t, ok)
+	})
+
+	t.Run("values expire after ttl", func(t *testing.T) {
+		now := time.Now()
+		c := NewLRU[int](2, time.Minute)
+		c.now = func() time.Time { return now }
+
+		c.Add("a", 1)
+		now = now.Add(59 * time.Second)
+		_, ok := c.Get("a")
+		require.True(t, ok)
+
+		now = now.Add(time.Second)
+		_, ok = c.Get("a")
+		require.False(t, ok)
+		require.Equal(t, 0, c.Len())
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		c := NewLRU[int](10, time.Minute)
+		var wg sync.WaitGroup
+		for i := range 10 {
+			wg.Go(func() {
+				for j := range 100 {
+					key := strconv.Itoa((i + j) % 20)
+					c.Add(key, j)
+					c.Get(key)
+				}
+			})
+		}
+		wg.Wait()
+		require.LessOrEqual(t, c.Len(), 10)
+	})
+}
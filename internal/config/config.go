@@ -28,11 +28,17 @@ type Sinks []GenericConfig
 type Source GenericConfig
 
 type Pipeline struct {
-	Processors Processors `json:"processors"`
-	Sinks      Sinks      `json:"sinks"`
+	Processors      Processors     `json:"processors"`
+	Sinks           Sinks          `json:"sinks"`
+	SinksPolicy     string         `json:"sinksPolicy,omitempty"`
+	Workers         int            `json:"workers,omitempty"`
+	Queue           *GenericConfig `json:"queue,omitempty"`
+	DeadLetterQueue *GenericConfig `json:"deadLetterQueue,omitempty"`
 }
 
 type Integration struct {
+	// Name identifies the integration in logs and metrics.
+	Name      string        `json:"name,omitempty"`
 	Source    GenericConfig `json:"source"`
 	Pipelines []Pipeline    `json:"pipelines"`
 }
@@ -0,0 +1,32 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuration(t *testing.T) {
+	t.Run("unmarshal from string", func(t *testing.T) {
+		var d Duration
+		require.NoError(t, json.Unmarshal([]byte(`"1m30s"`), &d))
+		require.Equal(t, 90*time.Second, d.Duration())
+	})
+
+	t.Run("fails on invalid duration", func(t *testing.T) {
+		var d Duration
+		require.Error(t, json.Unmarshal([]byte(`"not a duration"`), &d))
+		require.Error(t, json.Unmarshal([]byte(`10`), &d))
+	})
+
+	t.Run("marshal to string", func(t *testing.T) {
+		data, err := json.Marshal(Duration(500 * time.Millisecond))
+		require.NoError(t, err)
+		require.JSONEq(t, `"500ms"`, string(data))
+	})
+}
@@ -12,5 +12,6 @@ type EnvironmentVariables struct {
 	ServicePrefix        string `env:"SERVICE_PREFIX"`
 	DelayShutdownSeconds int    `env:"DELAY_SHUTDOWN_SECONDS" envDefault:"10"`
 
-	ConfigurationPath string `env:"CONFIGURATION_PATH,required"`
+	ConfigurationPath                  string `env:"CONFIGURATION_PATH,required"`
+	ConfigurationReloadIntervalSeconds int    `env:"CONFIGURATION_RELOAD_INTERVAL_SECONDS"`
 }
@@ -0,0 +1,133 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+	"github.com/mia-platform/integration-connector-agent/internal/sinks/kafka"
+	"github.com/mia-platform/integration-connector-agent/internal/sinks/mongo"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrUnsupportedQueue = errors.New("unsupported dead letter queue type")
+	ErrSetupQueue       = errors.New("error setting up dead letter queue")
+)
+
+const (
+	File  = "file"
+	Mongo = "mongo"
+	Kafka = "kafka"
+)
+
+// Record is the entry saved in the dead letter queue for each event that the pipeline
+// was not able to write to its sink.
+type Record struct {
+	Error     string    `json:"error"`
+	SinkType  string    `json:"sinkType"`
+	Attempts  int       `json:"attempts"`
+	Timestamp time.Time `json:"timestamp"`
+
+	EventType   string            `json:"eventType"`
+	Operation   string            `json:"operation"`
+	PrimaryKeys entities.PkFields `json:"primaryKeys"`
+	Data        json.RawMessage   `json:"data"`
+}
+
+// NewRecord builds the Record for an event that failed to be written. When the event body
+// is not a valid JSON it is saved as a JSON string, so that the record can always be encoded.
+func NewRecord(event entities.PipelineEvent, sinkType string, attempts int, err error) Record {
+	data := event.Data()
+	if !json.Valid(data) {
+		data, _ = json.Marshal(string(data))
+	}
+
+	return Record{
+		Error:     err.Error(),
+		SinkType:  sinkType,
+		Attempts:  attempts,
+		Timestamp: time.Now().UTC(),
+
+		EventType:   event.GetType(),
+		Operation:   event.Operation().String(),
+		PrimaryKeys: event.GetPrimaryKeys(),
+		Data:        data,
+	}
+}
+
+// MongoConfig is the configuration of the queue saving the records in a MongoDB collection.
+// Each record is inserted as a new document, so the options of the mongo sink that replace,
+// delete or defer the writes are not supported.
+type MongoConfig struct {
+	mongo.Config
+}
+
+func (c *MongoConfig) Validate() error {
+	if err := c.Config.Validate(); err != nil {
+		return err
+	}
+	if c.DeleteMode != "" || c.History != nil || c.Batch != nil {
+		return errors.New("deleteMode, history and batch are not supported by the dead letter queue")
+	}
+	return nil
+}
+
+func (c *MongoConfig) sinkConfig() *mongo.Config {
+	cfg := c.Config
+	cfg.InsertOnly = true
+	return &cfg
+}
+
+// Queue is the destination of the events that the pipeline failed to write.
+type Queue interface {
+	Publish(ctx context.Context, record Record) error
+	Close(ctx context.Context) error
+}
+
+// New returns the Queue described by the given configuration.
+func New(ctx context.Context, log *logrus.Logger, cfg config.GenericConfig) (Queue, error) {
+	switch cfg.Type {
+	case File:
+		fileConfig, err := config.GetConfig[*FileConfig](cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSetupQueue, err)
+		}
+		queue, err := NewFileQueue(fileConfig)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSetupQueue, err)
+		}
+		return queue, nil
+	case Mongo:
+		mongoConfig, err := config.GetConfig[*MongoConfig](cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSetupQueue, err)
+		}
+		writer, err := mongo.NewMongoDBWriter[entities.PipelineEvent](ctx, mongoConfig.sinkConfig())
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSetupQueue, err)
+		}
+		return NewSinkQueue(writer), nil
+	case Kafka:
+		kafkaConfig, err := config.GetConfig[*kafka.Config](cfg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSetupQueue, err)
+		}
+		producer, err := kafka.New[entities.PipelineEvent](kafkaConfig)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSetupQueue, err)
+		}
+		return NewSinkQueue(producer), nil
+	default:
+		log.WithField("type", cfg.Type).Error("dead letter queue type not supported")
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedQueue, cfg.Type)
+	}
+}
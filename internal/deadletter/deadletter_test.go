@@ -0,0 +1,90 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package deadletter
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRecord(t *testing.T) {
+	testCases := map[string]struct {
+		event entities.PipelineEvent
+
+		expectedData string
+	}{
+		"json body is kept as is": {
+			event: &entities.Event{
+				PrimaryKeys:   entities.PkFields{{Key: "id", Value: "123"}},
+				Type:          "issue",
+				OperationType: entities.Write,
+				OriginalRaw:   []byte(`{"id":"123"}`),
+			},
+			expectedData: `{"id":"123"}`,
+		},
+		"non json body is saved as string": {
+			event: &entities.Event{
+				PrimaryKeys:   entities.PkFields{{Key: "id", Value: "123"}},
+				Type:          "issue",
+				OperationType: entities.Delete,
+				OriginalRaw:   []byte(`not json`),
+			},
+			expectedData: `"not json"`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			record := NewRecord(tc.event, "mongo", 3, errors.New("write failed"))
+
+			require.Equal(t, "write failed", record.Error)
+			require.Equal(t, "mongo", record.SinkType)
+			require.Equal(t, 3, record.Attempts)
+			require.False(t, record.Timestamp.IsZero())
+			require.Equal(t, tc.event.GetType(), record.EventType)
+			require.Equal(t, tc.event.Operation().String(), record.Operation)
+			require.Equal(t, tc.event.GetPrimaryKeys(), record.PrimaryKeys)
+			require.JSONEq(t, tc.expectedData, string(record.Data))
+		})
+	}
+
+}
+
+func TestNew(t *testing.T) {
+	log, _ := test.NewNullLogger()
+
+	t.Run("unsupported queue type", func(t *testing.T) {
+		_, err := New(t.Context(), log, config.GenericConfig{Type: "unsupported", Raw: []byte(`{}`)})
+		require.EqualError(t, err, "unsupported dead letter queue type: unsupported")
+	})
+
+	t.Run("invalid file configuration", func(t *testing.T) {
+		_, err := New(t.Context(), log, config.GenericConfig{Type: File, Raw: []byte(`{"type":"file"}`)})
+		require.EqualError(t, err, "error setting up dead letter queue: configuration not valid: path is required")
+	})
+
+	t.Run("mongo queue rejects the options replacing or deleting documents", func(t *testing.T) {
+		t.Setenv("DLQ_MONGO_URL", "mongodb://localhost:27017/db")
+		for _, option := range []string{`"deleteMode":"soft"`, `"history":{}`, `"batch":{"maxSize":10}`} {
+			raw := []byte(`{"type":"mongo","url":{"fromEnv":"DLQ_MONGO_URL"},"collection":"dlq",` + option + `}`)
+			_, err := New(t.Context(), log, config.GenericConfig{Type: Mongo, Raw: raw})
+			require.EqualError(t, err, "error setting up dead letter queue: configuration not valid: deleteMode, history and batch are not supported by the dead letter queue")
+		}
+	})
+
+	t.Run("file queue", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "dlq.jsonl")
+		queue, err := New(t.Context(), log, config.GenericConfig{Type: File, Raw: []byte(`{"type":"file","path":"` + path + `"}`)})
+		require.NoError(t, err)
+		require.IsType(t, &FileQueue{}, queue)
+		require.NoError(t, queue.Close(t.Context()))
+	})
+}
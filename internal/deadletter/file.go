@@ -0,0 +1,61 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+type FileConfig struct {
+	Path string `json:"path"`
+}
+
+func (c *FileConfig) Validate() error {
+	if c.Path == "" {
+		return errors.New("path is required")
+	}
+	return nil
+}
+
+// FileQueue appends each record as a JSON line to a local file.
+type FileQueue struct {
+	mtx  sync.Mutex
+	file *os.File
+}
+
+func NewFileQueue(cfg *FileConfig) (*FileQueue, error) {
+	file, err := os.OpenFile(cfg.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead letter file: %w", err)
+	}
+
+	return &FileQueue{file: file}, nil
+}
+
+func (q *FileQueue) Publish(_ context.Context, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter record: %w", err)
+	}
+
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if _, err := q.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write dead letter record: %w", err)
+	}
+	return nil
+}
+
+func (q *FileQueue) Close(_ context.Context) error {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	return q.file.Close()
+}
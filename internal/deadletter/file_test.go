@@ -0,0 +1,54 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package deadletter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+
+	queue, err := NewFileQueue(&FileConfig{Path: path})
+	require.NoError(t, err)
+
+	for _, id := range []string{"1", "2"} {
+		record := NewRecord(&entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "id", Value: id}},
+			Type:          "issue",
+			OperationType: entities.Write,
+			OriginalRaw:   []byte(`{"id":"` + id + `"}`),
+		}, "mongo", 1, errors.New("write failed"))
+		require.NoError(t, queue.Publish(t.Context(), record))
+	}
+	require.NoError(t, queue.Close(t.Context()))
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	records := []Record{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record Record
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+
+	require.Len(t, records, 2)
+	require.Equal(t, "1", records[0].PrimaryKeys[0].Value)
+	require.Equal(t, "2", records[1].PrimaryKeys[0].Value)
+	require.Equal(t, "write failed", records[1].Error)
+	require.JSONEq(t, `{"id":"2"}`, string(records[1].Data))
+}
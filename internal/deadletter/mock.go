@@ -0,0 +1,48 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package deadletter
+
+import (
+	"context"
+	"sync"
+)
+
+type QueueMock struct {
+	PublishError error
+	CloseError   error
+
+	mtx          sync.Mutex
+	records      []Record
+	closeInvoked bool
+}
+
+func (q *QueueMock) Publish(_ context.Context, record Record) error {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	q.records = append(q.records, record)
+	return q.PublishError
+}
+
+func (q *QueueMock) Records() []Record {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	return q.records
+}
+
+func (q *QueueMock) Close(_ context.Context) error {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	q.closeInvoked = true
+	return q.CloseError
+}
+
+func (q *QueueMock) CloseInvoked() bool {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	return q.closeInvoked
+}
@@ -0,0 +1,41 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/sinks"
+)
+
+// SinkQueue publishes the records through an existing sink, encoding each record as the
+// body of a write event that keeps the primary keys and type of the failed event.
+type SinkQueue struct {
+	sink sinks.Sink[entities.PipelineEvent]
+}
+
+func NewSinkQueue(sink sinks.Sink[entities.PipelineEvent]) *SinkQueue {
+	return &SinkQueue{sink: sink}
+}
+
+func (q *SinkQueue) Publish(ctx context.Context, record Record) error {
+	body, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter record: %w", err)
+	}
+
+	return q.sink.WriteData(ctx, &entities.Event{
+		PrimaryKeys:   record.PrimaryKeys,
+		Type:          record.EventType,
+		OperationType: entities.Write,
+		OriginalRaw:   body,
+	})
+}
+
+func (q *SinkQueue) Close(ctx context.Context) error {
+	return q.sink.Close(ctx)
+}
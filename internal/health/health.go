@@ -0,0 +1,158 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package health
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK = "OK"
+	StatusKO = "KO"
+)
+
+var checkTimeout = 5 * time.Second
+
+// Checker is implemented by the sources and sinks that can report whether they are able to
+// receive or write events, e.g. because the connection to an external service is up.
+type Checker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// ComponentStatus is the result of the health check of a single component.
+type ComponentStatus struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type component struct {
+	componentType string
+	checker       Checker
+	// critical components are run by the service itself, like the consumer loop of a source:
+	// when they fail the service is not able to recover without a restart.
+	critical bool
+}
+
+// Registry collects the components whose health is reported by the status routes.
+// All the methods of a nil Registry are no-op.
+type Registry struct {
+	mtx        sync.RWMutex
+	components map[string]component
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		components: make(map[string]component),
+	}
+}
+
+// Register adds an external dependency, like the connection to a sink, to the registry,
+// replacing the component with the same name, if any.
+func (r *Registry) Register(name, componentType string, checker Checker) {
+	r.register(name, component{componentType: componentType, checker: checker})
+}
+
+// RegisterCritical adds a component run by the service itself, like the consumer loop of a
+// source or the workers of a pipeline, replacing the component with the same name, if any.
+func (r *Registry) RegisterCritical(name, componentType string, checker Checker) {
+	r.register(name, component{componentType: componentType, checker: checker, critical: true})
+}
+
+func (r *Registry) register(name string, c component) {
+	if r == nil {
+		return
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.components[name] = c
+}
+
+// Unregister removes all the components whose name starts with prefix.
+func (r *Registry) Unregister(prefix string) {
+	if r == nil {
+		return
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for name := range r.components {
+		if strings.HasPrefix(name, prefix) {
+			delete(r.components, name)
+		}
+	}
+}
+
+// Check runs the health checks of all the registered components concurrently, and returns
+// the overall status together with the status of each component, sorted by name.
+func (r *Registry) Check(ctx context.Context) (string, []ComponentStatus) {
+	return r.check(ctx, func(component) bool { return true })
+}
+
+// CheckCritical is like Check, but only for the components added with RegisterCritical.
+func (r *Registry) CheckCritical(ctx context.Context) (string, []ComponentStatus) {
+	return r.check(ctx, func(c component) bool { return c.critical })
+}
+
+// CheckDependencies is like Check, but only for the external dependencies added with Register.
+func (r *Registry) CheckDependencies(ctx context.Context) (string, []ComponentStatus) {
+	return r.check(ctx, func(c component) bool { return !c.critical })
+}
+
+func (r *Registry) check(ctx context.Context, include func(component) bool) (string, []ComponentStatus) {
+	if r == nil {
+		return StatusOK, nil
+	}
+
+	r.mtx.RLock()
+	names := make([]string, 0, len(r.components))
+	components := make([]component, 0, len(r.components))
+	for name, c := range r.components {
+		if !include(c) {
+			continue
+		}
+		names = append(names, name)
+		components = append(components, c)
+	}
+	r.mtx.RUnlock()
+
+	statuses := make([]ComponentStatus, len(components))
+	var wg sync.WaitGroup
+	for i, c := range components {
+		wg.Add(1)
+		go func(i int, c component) {
+			defer wg.Done()
+			statuses[i] = check(ctx, names[i], c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+
+	status := StatusOK
+	for _, s := range statuses {
+		if s.Status != StatusOK {
+			status = StatusKO
+		}
+	}
+	return status, statuses
+}
+
+func check(ctx context.Context, name string, c component) ComponentStatus {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	status := ComponentStatus{Name: name, Type: c.componentType, Status: StatusOK}
+	if err := c.checker.HealthCheck(ctx); err != nil {
+		status.Status = StatusKO
+		status.Error = err.Error()
+	}
+	return status
+}
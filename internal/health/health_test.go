@@ -0,0 +1,143 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type checkerFunc func(ctx context.Context) error
+
+func (f checkerFunc) HealthCheck(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestRegistry(t *testing.T) {
+	t.Run("nil registry is healthy", func(t *testing.T) {
+		var registry *Registry
+		registry.Register("sink", "mongo", checkerFunc(func(context.Context) error { return errors.New("fake error") }))
+
+		status, components := registry.Check(t.Context())
+		require.Equal(t, StatusOK, status)
+		require.Empty(t, components)
+	})
+
+	t.Run("all the components are healthy", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.Register("b", "kafka", checkerFunc(func(context.Context) error { return nil }))
+		registry.Register("a", "mongo", checkerFunc(func(context.Context) error { return nil }))
+
+		status, components := registry.Check(t.Context())
+		require.Equal(t, StatusOK, status)
+		require.Equal(t, []ComponentStatus{
+			{Name: "a", Type: "mongo", Status: StatusOK},
+			{Name: "b", Type: "kafka", Status: StatusOK},
+		}, components)
+	})
+
+	t.Run("a failing component makes the status KO", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.Register("a", "mongo", checkerFunc(func(context.Context) error { return nil }))
+		registry.Register("b", "kafka", checkerFunc(func(context.Context) error { return errors.New("broker down") }))
+
+		status, components := registry.Check(t.Context())
+		require.Equal(t, StatusKO, status)
+		require.Equal(t, []ComponentStatus{
+			{Name: "a", Type: "mongo", Status: StatusOK},
+			{Name: "b", Type: "kafka", Status: StatusKO, Error: "broker down"},
+		}, components)
+	})
+
+	t.Run("registering the same name replaces the component", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.Register("a", "mongo", checkerFunc(func(context.Context) error { return errors.New("fake error") }))
+		registry.Register("a", "kafka", checkerFunc(func(context.Context) error { return nil }))
+
+		status, components := registry.Check(t.Context())
+		require.Equal(t, StatusOK, status)
+		require.Equal(t, []ComponentStatus{{Name: "a", Type: "kafka", Status: StatusOK}}, components)
+	})
+
+	t.Run("unregister removes the components by prefix", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.Register("jira/source", "jira", checkerFunc(func(context.Context) error { return nil }))
+		registry.Register("jira/pipelines/0/sinks/0", "mongo", checkerFunc(func(context.Context) error { return nil }))
+		registry.Register("jira-cloud/source", "jira", checkerFunc(func(context.Context) error { return nil }))
+
+		registry.Unregister("jira/")
+
+		_, components := registry.Check(t.Context())
+		require.Equal(t, []ComponentStatus{{Name: "jira-cloud/source", Type: "jira", Status: StatusOK}}, components)
+	})
+
+	t.Run("critical components and dependencies are checked separately", func(t *testing.T) {
+		registry := NewRegistry()
+		registry.RegisterCritical("jira/source", "sqs", checkerFunc(func(context.Context) error { return ErrLoopStopped }))
+		registry.Register("jira/pipelines/0/sinks/0", "mongo", checkerFunc(func(context.Context) error { return nil }))
+
+		status, components := registry.CheckCritical(t.Context())
+		require.Equal(t, StatusKO, status)
+		require.Equal(t, []ComponentStatus{{Name: "jira/source", Type: "sqs", Status: StatusKO, Error: ErrLoopStopped.Error()}}, components)
+
+		status, components = registry.CheckDependencies(t.Context())
+		require.Equal(t, StatusOK, status)
+		require.Equal(t, []ComponentStatus{{Name: "jira/pipelines/0/sinks/0", Type: "mongo", Status: StatusOK}}, components)
+
+		status, components = registry.Check(t.Context())
+		require.Equal(t, StatusKO, status)
+		require.Len(t, components, 2)
+	})
+
+	t.Run("checks are bound by a timeout", func(t *testing.T) {
+		previousTimeout := checkTimeout
+		checkTimeout = 10 * time.Millisecond
+		t.Cleanup(func() { checkTimeout = previousTimeout })
+
+		registry := NewRegistry()
+		registry.Register("a", "mongo", checkerFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+
+		status, components := registry.Check(t.Context())
+		require.Equal(t, StatusKO, status)
+		require.Equal(t, context.DeadlineExceeded.Error(), components[0].Error)
+	})
+}
+
+func TestLoop(t *testing.T) {
+	t.Run("loop is healthy while running", func(t *testing.T) {
+		stop := make(chan struct{})
+		loop := &Loop{}
+		loop.Go(func() error {
+			<-stop
+			return nil
+		})
+		require.NoError(t, loop.HealthCheck(t.Context()))
+
+		close(stop)
+		require.Eventually(t, func() bool {
+			return errors.Is(loop.HealthCheck(t.Context()), ErrLoopStopped)
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("loop reports the error it stopped with", func(t *testing.T) {
+		loop := &Loop{}
+		loop.Go(func() error { return errors.New("connection refused") })
+
+		require.Eventually(t, func() bool {
+			return loop.HealthCheck(t.Context()) != nil
+		}, time.Second, 10*time.Millisecond)
+		require.EqualError(t, loop.HealthCheck(t.Context()), "consumer loop is not running: connection refused")
+	})
+
+	t.Run("loop never started is not healthy", func(t *testing.T) {
+		require.ErrorIs(t, (&Loop{}).HealthCheck(t.Context()), ErrLoopStopped)
+	})
+}
@@ -0,0 +1,51 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrLoopStopped = errors.New("consumer loop is not running")
+
+// Loop runs a long running function, like the consumer of a queue, and reports it as unhealthy
+// once the function has returned.
+type Loop struct {
+	mtx     sync.Mutex
+	running bool
+	err     error
+}
+
+// Go runs fn in a new goroutine. The loop is considered running from the moment Go is called.
+func (l *Loop) Go(fn func() error) {
+	l.mtx.Lock()
+	l.running = true
+	l.err = nil
+	l.mtx.Unlock()
+
+	go func() {
+		err := fn()
+
+		l.mtx.Lock()
+		defer l.mtx.Unlock()
+		l.running = false
+		l.err = err
+	}()
+}
+
+func (l *Loop) HealthCheck(_ context.Context) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if l.running {
+		return nil
+	}
+	if l.err != nil {
+		return fmt.Errorf("%w: %w", ErrLoopStopped, l.err)
+	}
+	return ErrLoopStopped
+}
@@ -0,0 +1,188 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace = "integration_connector_agent"
+
+	resultSuccess = "success"
+	resultFailure = "failure"
+)
+
+// Metrics holds the Prometheus collectors describing the events flowing through the pipelines.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	eventsReceived    *prometheus.CounterVec
+	eventsDiscarded   *prometheus.CounterVec
+	processorErrors   *prometheus.CounterVec
+	processorDuration *prometheus.HistogramVec
+	sinkWrites        *prometheus.CounterVec
+	sinkWriteDuration *prometheus.HistogramVec
+	queueDepth        *queueDepthCollector
+}
+
+func New() *Metrics {
+	processorLabels := []string{"integration", "pipeline", "processor_index", "processor_type"}
+	sinkLabels := []string{"integration", "pipeline", "sink_index", "sink_type"}
+
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		eventsReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_received_total",
+			Help:      "Number of events received by the pipeline from the source.",
+		}, []string{"integration", "pipeline", "source_type"}),
+		eventsDiscarded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_discarded_total",
+			Help:      "Number of events discarded by a processor.",
+		}, processorLabels),
+		processorErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "processor_errors_total",
+			Help:      "Number of events that a processor failed to elaborate.",
+		}, processorLabels),
+		processorDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "processor_duration_seconds",
+			Help:      "Time spent by a processor to elaborate an event.",
+			Buckets:   prometheus.DefBuckets,
+		}, processorLabels),
+		sinkWrites: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sink_writes_total",
+			Help:      "Number of events written to a sink, by result.",
+		}, []string{"integration", "pipeline", "sink_index", "sink_type", "result"}),
+		sinkWriteDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "sink_write_duration_seconds",
+			Help:      "Time spent to write an event to a sink, including the retries.",
+			Buckets:   prometheus.DefBuckets,
+		}, sinkLabels),
+		queueDepth: newQueueDepthCollector(),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.eventsReceived,
+		m.eventsDiscarded,
+		m.processorErrors,
+		m.processorDuration,
+		m.sinkWrites,
+		m.sinkWriteDuration,
+		m.queueDepth,
+	)
+
+	return m
+}
+
+// Handler returns the HTTP handler exposing the metrics in the Prometheus format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Pipeline returns the metrics of a single pipeline of an integration. It returns nil if m is nil,
+// and all the methods of a nil Pipeline are no-op.
+func (m *Metrics) Pipeline(integration string, pipelineIndex int, sourceType string) *Pipeline {
+	if m == nil {
+		return nil
+	}
+
+	return &Pipeline{
+		metrics:    m,
+		labels:     prometheus.Labels{"integration": integration, "pipeline": strconv.Itoa(pipelineIndex)},
+		sourceType: sourceType,
+	}
+}
+
+// RemoveIntegration stops exposing the depth of the queues of the pipelines of the integration,
+// once it has been removed.
+func (m *Metrics) RemoveIntegration(integration string) {
+	if m == nil {
+		return
+	}
+	m.queueDepth.forget(integration)
+}
+
+// Pipeline records the metrics of a pipeline.
+type Pipeline struct {
+	metrics    *Metrics
+	labels     prometheus.Labels
+	sourceType string
+}
+
+func (p *Pipeline) EventReceived() {
+	if p == nil {
+		return
+	}
+	p.metrics.eventsReceived.With(p.with(prometheus.Labels{"source_type": p.sourceType})).Inc()
+}
+
+// ObserveProcessor records the execution of a processor: err is entities.ErrDiscardEvent when
+// the processor discarded the event.
+func (p *Pipeline) ObserveProcessor(index int, processorType string, duration time.Duration, err error) {
+	if p == nil {
+		return
+	}
+
+	labels := p.with(prometheus.Labels{"processor_index": strconv.Itoa(index), "processor_type": processorType})
+	p.metrics.processorDuration.With(labels).Observe(duration.Seconds())
+	switch {
+	case errors.Is(err, entities.ErrDiscardEvent):
+		p.metrics.eventsDiscarded.With(labels).Inc()
+	case err != nil:
+		p.metrics.processorErrors.With(labels).Inc()
+	}
+}
+
+func (p *Pipeline) ObserveSinkWrite(index int, sinkType string, duration time.Duration, err error) {
+	if p == nil {
+		return
+	}
+
+	labels := p.with(prometheus.Labels{"sink_index": strconv.Itoa(index), "sink_type": sinkType})
+	p.metrics.sinkWriteDuration.With(labels).Observe(duration.Seconds())
+
+	result := resultSuccess
+	if err != nil {
+		result = resultFailure
+	}
+	labels["result"] = result
+	p.metrics.sinkWrites.With(labels).Inc()
+}
+
+// WatchQueue exposes the depth of the pipeline queue, read through length at each scrape.
+func (p *Pipeline) WatchQueue(length func() int) {
+	if p == nil {
+		return
+	}
+	p.metrics.queueDepth.watch(p.labels["integration"], p.labels["pipeline"], length)
+}
+
+func (p *Pipeline) with(labels prometheus.Labels) prometheus.Labels {
+	merged := make(prometheus.Labels, len(p.labels)+len(labels))
+	for k, v := range p.labels {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,77 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineMetrics(t *testing.T) {
+	m := New()
+	p := m.Pipeline("my-integration", 1, "jira")
+
+	p.EventReceived()
+	p.EventReceived()
+	p.ObserveProcessor(0, "filter", time.Millisecond, entities.ErrDiscardEvent)
+	p.ObserveProcessor(1, "mapper", time.Millisecond, errors.New("some error"))
+	p.ObserveProcessor(1, "mapper", time.Millisecond, nil)
+	p.ObserveSinkWrite(0, "mongo", time.Millisecond, nil)
+	p.ObserveSinkWrite(0, "mongo", time.Millisecond, errors.New("some error"))
+	p.ObserveSinkWrite(0, "mongo", time.Millisecond, nil)
+	p.WatchQueue(func() int { return 3 })
+
+	require.Equal(t, float64(2), testutil.ToFloat64(m.eventsReceived.WithLabelValues("my-integration", "1", "jira")))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.eventsDiscarded.WithLabelValues("my-integration", "1", "0", "filter")))
+	require.Equal(t, float64(0), testutil.ToFloat64(m.processorErrors.WithLabelValues("my-integration", "1", "0", "filter")))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.processorErrors.WithLabelValues("my-integration", "1", "1", "mapper")))
+	require.Equal(t, 2, testutil.CollectAndCount(m.processorDuration))
+	require.Equal(t, float64(2), testutil.ToFloat64(m.sinkWrites.WithLabelValues("my-integration", "1", "0", "mongo", "success")))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.sinkWrites.WithLabelValues("my-integration", "1", "0", "mongo", "failure")))
+	require.Equal(t, 1, testutil.CollectAndCount(m.sinkWriteDuration))
+	require.Equal(t, float64(3), testutil.ToFloat64(m.queueDepth))
+
+	t.Run("metrics are exposed by the handler", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/-/metrics", nil))
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		body, err := io.ReadAll(recorder.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(body), `integration_connector_agent_events_received_total{integration="my-integration",pipeline="1",source_type="jira"} 2`)
+		require.Contains(t, string(body), `integration_connector_agent_queue_depth{integration="my-integration",pipeline="1"} 3`)
+	})
+
+	t.Run("queues of a removed integration are not exposed", func(t *testing.T) {
+		m.Pipeline("other-integration", 0, "jira").WatchQueue(func() int { return 1 })
+		require.Equal(t, 2, testutil.CollectAndCount(m.queueDepth))
+
+		m.RemoveIntegration("my-integration")
+		require.Equal(t, 1, testutil.CollectAndCount(m.queueDepth))
+		require.Equal(t, float64(1), testutil.ToFloat64(m.queueDepth))
+	})
+}
+
+func TestNilPipelineMetrics(t *testing.T) {
+	var m *Metrics
+	p := m.Pipeline("my-integration", 0, "jira")
+	require.Nil(t, p)
+
+	require.NotPanics(t, func() {
+		p.EventReceived()
+		p.ObserveProcessor(0, "filter", time.Millisecond, nil)
+		p.ObserveSinkWrite(0, "mongo", time.Millisecond, nil)
+		p.WatchQueue(func() int { return 1 })
+		m.RemoveIntegration("my-integration")
+	})
+}
@@ -0,0 +1,72 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type watchedQueue struct {
+	integration string
+	pipeline    string
+	length      func() int
+}
+
+// queueDepthCollector reads the depth of the pipeline queues when collected, so that the
+// exposed value is never stale.
+type queueDepthCollector struct {
+	desc *prometheus.Desc
+
+	mtx    sync.Mutex
+	queues map[[2]string]watchedQueue
+}
+
+func newQueueDepthCollector() *queueDepthCollector {
+	return &queueDepthCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "queue_depth"),
+			"Number of events waiting in the pipeline queue.",
+			[]string{"integration", "pipeline"},
+			nil,
+		),
+		queues: make(map[[2]string]watchedQueue),
+	}
+}
+
+func (c *queueDepthCollector) watch(integration, pipeline string, length func() int) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.queues[[2]string{integration, pipeline}] = watchedQueue{
+		integration: integration,
+		pipeline:    pipeline,
+		length:      length,
+	}
+}
+
+func (c *queueDepthCollector) forget(integration string) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for key := range c.queues {
+		if key[0] == integration {
+			delete(c.queues, key)
+		}
+	}
+}
+
+func (c *queueDepthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *queueDepthCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, queue := range c.queues {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(queue.length()), queue.integration, queue.pipeline)
+	}
+}
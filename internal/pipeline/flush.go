@@ -0,0 +1,75 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// startFlushing writes the events buffered by the processors every interval, until the returned
+// function is called: it waits for the flush in progress, if any, to complete.
+func (p Pipeline) startFlushing(ctx context.Context, interval time.Duration) func() {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.flushProcessors(ctx, false)
+			}
+		}
+	})
+
+	return func() {
+		close(stop)
+		wg.Wait()
+	}
+}
+
+// flushProcessors writes to the sinks the events buffered by the processors that are ready,
+// or all of them when final is set.
+func (p Pipeline) flushProcessors(ctx context.Context, final bool) {
+	events, err := p.processors.Flush(time.Now(), final)
+	if err != nil {
+		p.logger.WithError(err).Error("error flushing pipeline processors")
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"events": len(events),
+		"final":  final,
+	}).Debug("writing events flushed by pipeline processors")
+	// each flushed event carries the acks of the events it aggregates: if it is not written nor saved
+	// in the dead letter queue, they are not acknowledged, so that the sources deliver them again
+	if err := p.writeEvents(ctx, events); err != nil {
+		p.logger.WithError(err).Warn("events flushed by pipeline processors not delivered")
+	}
+}
+
+// releaseProcessors drops the events still buffered by the processors when the pipeline stops
+// without flushing them, and notifies the sources that they have not been written.
+func (p Pipeline) releaseProcessors(err error) {
+	events, _ := p.processors.Flush(time.Now(), true)
+	if len(events) == 0 {
+		return
+	}
+
+	p.logger.WithField("events", len(events)).Warn("events buffered by pipeline processors dropped")
+	for _, event := range events {
+		event.Ack(err)
+	}
+}
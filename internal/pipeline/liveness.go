@@ -0,0 +1,87 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/internal/pipeline/queue"
+)
+
+var (
+	ErrPipelineNotRunning = errors.New("pipeline is not running")
+	ErrPipelineStuck      = errors.New("pipeline is stuck processing an event")
+)
+
+// stuckAttemptTimeout is how long a single attempt of writing an event to a sink, processors
+// included, can last: the pipeline is reported as stuck when the elaboration of an event lasts
+// longer than all the attempts and the backoffs allowed by the retry policies of its sinks.
+var stuckAttemptTimeout = 5 * time.Minute
+
+// inFlight records when the elaboration of each event being processed started, to detect
+// a worker stuck on an event.
+type inFlight struct {
+	mtx     sync.Mutex
+	started map[*queue.Item]time.Time
+}
+
+func newInFlight() *inFlight {
+	return &inFlight{started: make(map[*queue.Item]time.Time)}
+}
+
+func (f *inFlight) begin(item *queue.Item) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.started[item] = time.Now()
+}
+
+func (f *inFlight) end(item *queue.Item) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	delete(f.started, item)
+}
+
+// oldest returns how long the longest running elaboration has lasted, or zero if no event
+// is being processed.
+func (f *inFlight) oldest() time.Duration {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	var oldest time.Duration
+	for _, started := range f.started {
+		oldest = max(oldest, time.Since(started))
+	}
+	return oldest
+}
+
+// stuckEventTimeout returns how long the elaboration of a single event can last before the pipeline
+// is reported as stuck: the attempts of each sink, and the backoffs between them, are summed, since
+// the sinks can be written one after the other.
+func (p Pipeline) stuckEventTimeout() time.Duration {
+	var timeout time.Duration
+	for _, sink := range p.sinks {
+		timeout += time.Duration(sink.Retry.maxAttempts())*stuckAttemptTimeout + sink.Retry.maxWait()
+	}
+	return max(timeout, stuckAttemptTimeout)
+}
+
+// HealthCheck reports whether the pipeline is processing its events: it fails when the pipeline
+// loop is not running, or when an event has been processed for more than its stuckEventTimeout.
+// The pipeline is considered healthy while it is closing.
+func (p Pipeline) HealthCheck(_ context.Context) error {
+	if p.shutdown.isDraining() {
+		return nil
+	}
+	if !p.shutdown.isRunning() {
+		return ErrPipelineNotRunning
+	}
+	if elapsed := p.inFlight.oldest(); elapsed > p.stuckEventTimeout() {
+		return fmt.Errorf("%w for %s", ErrPipelineStuck, elapsed.Round(time.Second))
+	}
+	return nil
+}
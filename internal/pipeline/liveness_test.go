@@ -0,0 +1,104 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+	"github.com/mia-platform/integration-connector-agent/internal/processors"
+	fakesink "github.com/mia-platform/integration-connector-agent/internal/sinks/fake"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineHealthCheck(t *testing.T) {
+	t.Run("pipeline not started is not healthy", func(t *testing.T) {
+		log, _ := test.NewNullLogger()
+		p, err := New(log, &processors.Processors{}, newBlockingSink())
+		require.NoError(t, err)
+
+		require.ErrorIs(t, p.(*Pipeline).HealthCheck(t.Context()), ErrPipelineNotRunning)
+	})
+
+	t.Run("pipeline stuck on an event is not healthy", func(t *testing.T) {
+		previousTimeout := stuckAttemptTimeout
+		stuckAttemptTimeout = 20 * time.Millisecond
+		t.Cleanup(func() { stuckAttemptTimeout = previousTimeout })
+
+		log, _ := test.NewNullLogger()
+		sink := newBlockingSink()
+		p, err := New(log, &processors.Processors{}, sink)
+		require.NoError(t, err)
+		runPipeline(t, p)
+
+		checker := p.(*Pipeline)
+		require.Eventually(t, func() bool { return checker.HealthCheck(t.Context()) == nil }, time.Second, 5*time.Millisecond)
+
+		p.AddMessage(&entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "key", Value: "1"}},
+			OperationType: entities.Write,
+			OriginalRaw:   []byte(`{}`),
+		})
+		require.Eventually(t, func() bool { return sink.started() == 1 }, time.Second, 5*time.Millisecond)
+		require.Eventually(t, func() bool {
+			return errors.Is(checker.HealthCheck(t.Context()), ErrPipelineStuck)
+		}, time.Second, 5*time.Millisecond)
+
+		sink.release()
+		require.Eventually(t, func() bool { return checker.HealthCheck(t.Context()) == nil }, time.Second, 5*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+		defer cancel()
+		require.NoError(t, p.Close(ctx))
+	})
+
+	t.Run("pipeline retrying an event with a long retry policy is healthy", func(t *testing.T) {
+		previousTimeout := stuckAttemptTimeout
+		stuckAttemptTimeout = 20 * time.Millisecond
+		t.Cleanup(func() { stuckAttemptTimeout = previousTimeout })
+
+		log, _ := test.NewNullLogger()
+		sink := fakesink.New(&fakesink.Config{Mocks: fakesink.Mocks{{Error: errors.New("fake error")}, {Error: errors.New("fake error")}}}, log)
+		retry := &RetryPolicy{MaxAttempts: 3, InitialBackoff: config.Duration(200 * time.Millisecond)}
+		require.NoError(t, retry.Validate())
+		p, err := NewWithSinks(log, &processors.Processors{}, []Sink{{Type: "fake", Sink: sink, Retry: retry}})
+		require.NoError(t, err)
+		runPipeline(t, p)
+
+		checker := p.(*Pipeline)
+		require.Eventually(t, func() bool { return checker.HealthCheck(t.Context()) == nil }, time.Second, 5*time.Millisecond)
+
+		p.AddMessage(&entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "key", Value: "1"}},
+			OperationType: entities.Write,
+			OriginalRaw:   []byte(`{}`),
+		})
+		require.Eventually(t, func() bool { return len(sink.Calls()) == 1 }, time.Second, 5*time.Millisecond)
+		require.Never(t, func() bool {
+			return checker.HealthCheck(t.Context()) != nil
+		}, 100*time.Millisecond, 5*time.Millisecond, "the event is waiting for its retry")
+		require.Eventually(t, func() bool { return len(sink.Calls()) == 3 }, 2*time.Second, 5*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+		defer cancel()
+		require.NoError(t, p.Close(ctx))
+	})
+}
+
+func TestStuckEventTimeout(t *testing.T) {
+	retry := &RetryPolicy{MaxAttempts: 4, InitialBackoff: config.Duration(time.Second), MaxBackoff: config.Duration(3 * time.Second), Jitter: 0.5}
+	require.NoError(t, retry.Validate())
+
+	require.Equal(t, stuckAttemptTimeout, Pipeline{}.stuckEventTimeout())
+	require.Equal(t, stuckAttemptTimeout, Pipeline{sinks: []Sink{{}}}.stuckEventTimeout())
+	// 4 attempts, and the backoffs of 1s, 2s and 3s, with the highest jitter, up to the max backoff
+	require.Equal(t, 4*stuckAttemptTimeout+7500*time.Millisecond, Pipeline{sinks: []Sink{{Retry: retry}}}.stuckEventTimeout())
+	require.Equal(t, 5*stuckAttemptTimeout+7500*time.Millisecond, Pipeline{sinks: []Sink{{}, {Retry: retry}}}.stuckEventTimeout())
+}
@@ -6,8 +6,13 @@ package pipeline
 import (
 	"context"
 	"errors"
+	"fmt"
+	"sync"
 
 	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/deadletter"
+	"github.com/mia-platform/integration-connector-agent/internal/metrics"
+	"github.com/mia-platform/integration-connector-agent/internal/pipeline/queue"
 	"github.com/mia-platform/integration-connector-agent/internal/processors"
 	"github.com/mia-platform/integration-connector-agent/internal/sinks"
 	"github.com/mia-platform/integration-connector-agent/internal/utils"
@@ -17,14 +22,71 @@ import (
 
 var (
 	ErrWriterNotDefined = errors.New("writer not defined")
+
+	// errEventBuffered is returned by processEvent for the events kept by the processors, which
+	// are acknowledged once the events they are aggregated in are written.
+	errEventBuffered = errors.New("event buffered by processors")
 )
 
+// processorsStage replaces the sink type in the dead letter records of the events failed by the processors.
+const processorsStage = "processors"
+
 type Pipeline struct {
-	sinks      sinks.Sink[entities.PipelineEvent]
-	processors *processors.Processors
-	logger     *logrus.Logger
+	sinks       []Sink
+	sinksPolicy SinksPolicy
+	processors  *processors.Processors
+	logger      *logrus.Logger
+
+	deadLetterQueue deadletter.Queue
+
+	queue   queue.Queue
+	workers int
+
+	metrics *metrics.Pipeline
+
+	shutdown *shutdown
+	inFlight *inFlight
+}
+
+// Option customizes the Pipeline created by New.
+type Option func(*Pipeline)
+
+// WithSinksPolicy sets how the events are written to the sinks of the pipeline. When not set,
+// an event is considered written only if all the sinks succeed.
+func WithSinksPolicy(policy SinksPolicy) Option {
+	return func(p *Pipeline) {
+		p.sinksPolicy = policy
+	}
+}
 
-	eventChan chan entities.PipelineEvent
+// WithMetrics sets the metrics recording the events elaborated by the pipeline.
+func WithMetrics(m *metrics.Pipeline) Option {
+	return func(p *Pipeline) {
+		p.metrics = m
+	}
+}
+
+// WithQueue sets the queue buffering the events waiting to be processed, replacing the
+// default in-memory one.
+func WithQueue(q queue.Queue) Option {
+	return func(p *Pipeline) {
+		p.queue = q
+	}
+}
+
+// WithWorkers sets the number of events processed concurrently. The events are partitioned by
+// their primary keys, so that the events of the same entity are still processed in order.
+func WithWorkers(workers int) Option {
+	return func(p *Pipeline) {
+		p.workers = workers
+	}
+}
+
+// WithDeadLetterQueue sets the queue where the events that fail to be written to the sink are sent.
+func WithDeadLetterQueue(queue deadletter.Queue) Option {
+	return func(p *Pipeline) {
+		p.deadLetterQueue = queue
+	}
 }
 
 func (p Pipeline) AddMessage(data entities.PipelineEvent) {
@@ -33,13 +95,33 @@ func (p Pipeline) AddMessage(data entities.PipelineEvent) {
 		"primaryKeys": data.GetPrimaryKeys().Map(),
 		"operation":   data.Operation(),
 	}).Debug("adding event to pipeline")
-	p.eventChan <- data
+	p.metrics.EventReceived()
+
+	// Push blocks while the queue is full, applying backpressure to the source
+	if err := p.queue.Push(context.Background(), data); err != nil {
+		p.logger.WithError(err).WithFields(logrus.Fields{
+			"eventType":   data.GetType(),
+			"primaryKeys": data.GetPrimaryKeys().Map(),
+		}).Error("error adding event to pipeline")
+		data.Ack(err)
+	}
 }
 
+// Start processes the events in the queue until ctx is cancelled, or the pipeline is closed.
 func (p Pipeline) Start(ctx context.Context) error {
-	if utils.IsNil(p.sinks) {
+	if len(p.sinks) == 0 {
 		return ErrWriterNotDefined
 	}
+	for _, sink := range p.sinks {
+		if utils.IsNil(sink.Sink) {
+			return ErrWriterNotDefined
+		}
+	}
+
+	if !p.shutdown.start() {
+		return nil
+	}
+	defer p.shutdown.stop()
 
 	err := p.runPipeline(ctx)
 	if err != nil {
@@ -50,99 +132,265 @@ func (p Pipeline) Start(ctx context.Context) error {
 	return nil
 }
 
+// Close stops the pipeline gracefully: the events already in the queue are processed until
+// ctx is done, then the queue, the sinks and the processors are closed. Every step is run even
+// if a previous one fails, and the returned error joins their failures. If some events are
+// left in the queue, the returned error wraps ErrUnprocessedEvents.
 func (p Pipeline) Close(ctx context.Context) error {
-	if err := p.sinks.Close(ctx); err != nil {
-		return err
+	p.shutdown.drain(ctx)
+
+	var errs []error
+	unprocessed := p.queue.Len()
+	if err := p.queue.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := p.closeSinks(ctx); err != nil {
+		errs = append(errs, err)
 	}
 
-	return p.processors.Close()
+	if p.deadLetterQueue != nil {
+		if err := p.deadLetterQueue.Close(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := p.processors.Close(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if unprocessed > 0 {
+		p.logger.WithField("unprocessedEvents", unprocessed).Warn("pipeline closed before processing all the queued events")
+		errs = append(errs, fmt.Errorf("%w: %d", ErrUnprocessedEvents, unprocessed))
+	}
+	return errors.Join(errs...)
 }
 
 func (p Pipeline) runPipeline(ctx context.Context) error {
-loop:
+	// the elaboration is interrupted when the pipeline is aborted during the shutdown
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(p.shutdown.aborted, cancel)()
+
+	// waitCtx stops waiting for new events once the pipeline starts draining
+	waitCtx, stopWaiting := context.WithCancel(ctx)
+	defer stopWaiting()
+	defer context.AfterFunc(p.shutdown.draining, stopWaiting)()
+
+	if interval := p.processors.FlushInterval(); interval > 0 {
+		// registered before the workers are stopped, so that the last flush happens after them
+		stopFlushing := p.startFlushing(ctx, interval)
+		defer func() {
+			stopFlushing()
+			if p.shutdown.isDraining() && ctx.Err() == nil {
+				// the pipeline is closing: the events still buffered by the processors are written
+				p.flushProcessors(ctx, true)
+				return
+			}
+			p.releaseProcessors(ErrUnprocessedEvents)
+		}()
+	}
+
+	var workers *workers
+	process := func(item *queue.Item) { p.processItem(ctx, item) }
+	if p.workers > 1 {
+		// the workers are stopped before ctx is cancelled, once they processed the dispatched events
+		workers = startWorkers(p.workers, process)
+		defer workers.stop()
+		process = workers.dispatch
+	}
+
 	for {
-		select {
-		case message, open := <-p.eventChan:
-			if !open {
-				// the channel has been closed, break the loop
-				break loop
+		if ctx.Err() != nil {
+			if p.shutdown.isAborted() {
+				// the shutdown timed out, the events left are not processed
+				return nil
 			}
+			// context has been cancelled close the queue
+			_ = p.queue.Close()
+			return ctx.Err()
+		}
 
-			p.logger.WithFields(logrus.Fields{
-				"eventType":   message.GetType(),
-				"primaryKeys": message.GetPrimaryKeys().Map(),
-				"operation":   message.Operation(),
-			}).Debug("starting pipeline elaboration for event")
-
-			processedMessage, err := p.processors.Process(ctx, message)
-			if err != nil {
-				if errors.Is(err, entities.ErrDiscardEvent) {
-					// the message has been filtered out
-					originalBody, decodedBody, wasDecoded := utils.TryDecodeBase64Body(message.Data())
-					logFields := logrus.Fields{
-						"eventType":    message.GetType(),
-						"primaryKeys":  message.GetPrimaryKeys().Map(),
-						"reason":       "filtered_by_processor",
-						"originalBody": originalBody,
-					}
-					if wasDecoded {
-						logFields["decodedBody"] = decodedBody
-						logFields["wasBase64"] = true
-					}
-					p.logger.WithError(err).WithFields(logFields).Debug("event discarded by pipeline processor")
-					continue
-				}
-				p.logger.WithError(err).WithFields(logrus.Fields{
-					"eventType":   message.GetType(),
-					"primaryKeys": message.GetPrimaryKeys().Map(),
-					"message":     message.Data(),
-				}).Error("error processing data")
-				continue
+		popCtx, stopPop := waitCtx, context.CancelFunc(func() {})
+		if p.shutdown.isDraining() {
+			if p.queue.Len() == 0 {
+				return nil
 			}
+			// the events being processed by the workers can be still counted in the queue length:
+			// stop waiting once they are done, to check the length again
+			popCtx, stopPop = workers.untilIdle(ctx)
+		}
 
-			p.logger.WithFields(logrus.Fields{
-				"eventType":   processedMessage.GetType(),
-				"primaryKeys": processedMessage.GetPrimaryKeys().Map(),
-				"operation":   processedMessage.Operation(),
-			}).Debug("pipeline elaboration completed, sending to sink")
-
-			if err := p.sinks.WriteData(ctx, processedMessage); err != nil {
-				// TODO: manage failure in writing message. DLQ?
-				p.logger.WithError(err).WithFields(logrus.Fields{
-					"eventType":        processedMessage.GetType(),
-					"primaryKeys":      processedMessage.GetPrimaryKeys().Map(),
-					"id":               processedMessage.GetPrimaryKeys().Map(),
-					"data":             string(processedMessage.Data()),
-					"messageOperation": processedMessage.Operation(),
-				}).Error("error writing data to sink")
-			} else {
-				p.logger.WithFields(logrus.Fields{
-					"eventType":        processedMessage.GetType(),
-					"primaryKeys":      processedMessage.GetPrimaryKeys().Map(),
-					"messageOperation": processedMessage.Operation(),
-				}).Debug("event successfully written to sink")
+		item, err := p.queue.Pop(popCtx)
+		stopPop()
+		if err != nil {
+			if errors.Is(err, queue.ErrQueueClosed) {
+				// the queue has been closed, stop the pipeline
+				return nil
 			}
+			if popCtx.Err() != nil {
+				// the pipeline started draining, process the remaining events
+				continue
+			}
+			p.logger.WithError(err).Error("error reading event from pipeline queue")
+			continue
+		}
 
-		case <-ctx.Done():
-			// context has been cancelled close the channel
-			close(p.eventChan)
-			return ctx.Err()
+		process(item)
+	}
+}
+
+// processItem processes the event popped from the queue, and acks it.
+func (p Pipeline) processItem(ctx context.Context, item *queue.Item) {
+	p.inFlight.begin(item)
+	defer p.inFlight.end(item)
+
+	// processors and sinks work on a copy of the event, whose ack is passed on to the events
+	// created from it: the event is acknowledged at most once, either by them or here
+	message := item.Event.Clone()
+	var once sync.Once
+	message.WithAck(func(err error) {
+		once.Do(func() { item.Event.Ack(err) })
+	})
+	err := p.processEvent(ctx, message)
+
+	if ctx.Err() != nil {
+		// the elaboration has been interrupted: keep the event in the queue so that,
+		// if persisted, it is processed again on restart, and let the source deliver it again
+		p.logger.WithFields(logrus.Fields{
+			"eventType":   item.Event.GetType(),
+			"primaryKeys": item.Event.GetPrimaryKeys().Map(),
+		}).Warn("event elaboration interrupted by the pipeline stop")
+		message.Ack(ctx.Err())
+		return
+	}
+	// a buffered event is acknowledged once the events it is aggregated in are written, while it
+	// is removed from the queue right away, not to hold its slot for the whole aggregation window
+	if !errors.Is(err, errEventBuffered) {
+		message.Ack(err)
+	}
+	if err := item.Done(); err != nil {
+		p.logger.WithError(err).WithFields(logrus.Fields{
+			"eventType":   item.Event.GetType(),
+			"primaryKeys": item.Event.GetPrimaryKeys().Map(),
+		}).Error("error removing event from pipeline queue")
+	}
+}
+
+// processEvent runs the processors and writes the resulting events to the sinks. It returns an
+// error only when an event has not been written and should be delivered again by the source:
+// events discarded or failed by the processors are not retried, since they would fail again,
+// unless the failure wraps entities.ErrRetryable. It returns errEventBuffered when the event
+// has been buffered by a processor, and it is not acknowledged yet.
+func (p Pipeline) processEvent(ctx context.Context, message entities.PipelineEvent) error {
+	p.logger.WithFields(logrus.Fields{
+		"eventType":   message.GetType(),
+		"primaryKeys": message.GetPrimaryKeys().Map(),
+		"operation":   message.Operation(),
+	}).Debug("starting pipeline elaboration for event")
+
+	// the processors can change the event: a copy of the original one is saved in the dead letter queue
+	original := message.Clone()
+	processedMessages, err := p.processors.Process(ctx, message)
+	if err != nil {
+		if errors.Is(err, entities.ErrDiscardEvent) {
+			// the message has been filtered out
+			originalBody, decodedBody, wasDecoded := utils.TryDecodeBase64Body(message.Data())
+			logFields := logrus.Fields{
+				"eventType":    message.GetType(),
+				"primaryKeys":  message.GetPrimaryKeys().Map(),
+				"reason":       "filtered_by_processor",
+				"originalBody": originalBody,
+			}
+			if wasDecoded {
+				logFields["decodedBody"] = decodedBody
+				logFields["wasBase64"] = true
+			}
+			p.logger.WithError(err).WithFields(logFields).Debug("event discarded by pipeline processor")
+			return nil
 		}
+		p.logger.WithError(err).WithFields(logrus.Fields{
+			"eventType":   message.GetType(),
+			"primaryKeys": message.GetPrimaryKeys().Map(),
+			"message":     message.Data(),
+		}).Error("error processing data")
+		if errors.Is(err, entities.ErrRetryable) {
+			// the failure is transient, so the event is not dropped: it is saved in the dead
+			// letter queue or, if not possible, delivered again by the source
+			if p.sendToDeadLetterQueue(ctx, original, processorsStage, 1, err) {
+				return nil
+			}
+			return err
+		}
+		return nil
 	}
 
-	return nil
+	if len(processedMessages) == 0 {
+		p.logger.WithFields(logrus.Fields{
+			"eventType":   message.GetType(),
+			"primaryKeys": message.GetPrimaryKeys().Map(),
+		}).Debug("event buffered by pipeline processor")
+		return errEventBuffered
+	}
+
+	return p.writeEvents(ctx, processedMessages)
 }
 
-func New(logger *logrus.Logger, p *processors.Processors, sinks sinks.Sink[entities.PipelineEvent]) (IPipeline, error) {
-	// TODO: here instead to use a buffer size it should be used a proper queue
-	messageChan := make(chan entities.PipelineEvent, 1000000)
+// writeEvents writes the processed events to the sinks, and returns an error joining the
+// failures of the events not delivered.
+func (p Pipeline) writeEvents(ctx context.Context, events []entities.PipelineEvent) error {
+	var undelivered []error
+	for _, event := range events {
+		p.logger.WithFields(logrus.Fields{
+			"eventType":   event.GetType(),
+			"primaryKeys": event.GetPrimaryKeys().Map(),
+			"operation":   event.Operation(),
+		}).Debug("pipeline elaboration completed, sending to sinks")
 
+		// failures are already reported for each sink
+		err := undeliveredError(p.writeToSinks(ctx, event))
+		// the processors can set the ack of the processed event to be notified if it is not delivered
+		event.Ack(err)
+		if err != nil {
+			undelivered = append(undelivered, err)
+		}
+	}
+	return errors.Join(undelivered...)
+}
+
+// New returns a pipeline writing the processed events to a single sink.
+func New(logger *logrus.Logger, p *processors.Processors, sink sinks.Sink[entities.PipelineEvent], opts ...Option) (IPipeline, error) {
+	return NewWithSinks(logger, p, []Sink{{Sink: sink}}, opts...)
+}
+
+// NewWithSinks returns a pipeline writing the processed events to all the given sinks,
+// following the configured sinks policy.
+func NewWithSinks(logger *logrus.Logger, p *processors.Processors, targets []Sink, opts ...Option) (IPipeline, error) {
 	pipeline := &Pipeline{
-		sinks:      sinks,
-		processors: p,
+		sinks:       targets,
+		sinksPolicy: AllSinks,
+		processors:  p,
+
+		logger:   logger,
+		shutdown: newShutdown(),
+		inFlight: newInFlight(),
+	}
 
-		eventChan: messageChan,
-		logger:    logger,
+	for _, opt := range opts {
+		opt(pipeline)
+	}
+
+	if err := pipeline.sinksPolicy.Validate(); err != nil {
+		return nil, err
+	}
+	if pipeline.queue == nil {
+		pipeline.queue = queue.NewMemoryQueue(queue.DefaultMaxEvents)
+	}
+	if pipeline.metrics != nil {
+		pipeline.metrics.WatchQueue(pipeline.queue.Len)
+		if pipeline.processors != nil {
+			pipeline.processors.SetObserver(pipeline.metrics)
+		}
 	}
 
 	return pipeline, nil
@@ -6,11 +6,19 @@ package pipeline
 import (
 	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/mia-platform/integration-connector-agent/entities"
 	"github.com/mia-platform/integration-connector-agent/internal/config"
+	"github.com/mia-platform/integration-connector-agent/internal/deadletter"
+	"github.com/mia-platform/integration-connector-agent/internal/metrics"
+	"github.com/mia-platform/integration-connector-agent/internal/pipeline/queue"
 	"github.com/mia-platform/integration-connector-agent/internal/processors"
 	fakesink "github.com/mia-platform/integration-connector-agent/internal/sinks/fake"
 
@@ -83,7 +91,7 @@ func TestPipeline(t *testing.T) {
 		}
 	})
 
-	t.Run("on channel closed, the pipeline stops", func(t *testing.T) {
+	t.Run("on queue closed, the pipeline stops", func(t *testing.T) {
 		w := fakesink.New(model, log)
 		p, err := New(log, proc, w)
 		require.NoError(t, err)
@@ -93,8 +101,7 @@ func TestPipeline(t *testing.T) {
 			time.Sleep(10 * time.Millisecond)
 
 			if pipeline, ok := p.(*Pipeline); ok {
-				eventChannel := pipeline.eventChan
-				close(eventChannel)
+				pipeline.queue.Close()
 			}
 		}(t)
 
@@ -188,6 +195,439 @@ func TestPipeline(t *testing.T) {
 		assert.Equal(t, "error writing data to sink", hook.LastEntry().Message)
 	})
 
+	t.Run("on sink error, the event is sent to the dead letter queue", func(t *testing.T) {
+		w := fakesink.New(&fakesink.Config{
+			Mocks: []fakesink.Mock{
+				{Error: errors.New("fake error")},
+			},
+		}, log)
+		dlq := &deadletter.QueueMock{}
+
+		p, err := NewWithSinks(log, proc, []Sink{{Type: "fake", Sink: w}}, WithDeadLetterQueue(dlq))
+		require.NoError(t, err)
+
+		runPipeline(t, p)
+
+		p.AddMessage(&entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "key", Value: "fake event"}},
+			Type:          "event-type",
+			OperationType: entities.Write,
+			OriginalRaw:   []byte(`{"key":"fake event"}`),
+		})
+
+		assert.Eventually(t, func() bool {
+			return len(dlq.Records()) == 1
+		}, 1*time.Second, 10*time.Millisecond)
+
+		record := dlq.Records()[0]
+		assert.Equal(t, "fake error", record.Error)
+		assert.Equal(t, "fake", record.SinkType)
+		assert.Equal(t, 1, record.Attempts)
+		assert.Equal(t, "event-type", record.EventType)
+		assert.Equal(t, entities.PkFields{{Key: "key", Value: "fake event"}}, record.PrimaryKeys)
+		assert.JSONEq(t, `{"key":"fake event"}`, string(record.Data))
+
+		require.NoError(t, p.Close(t.Context()))
+		assert.True(t, dlq.CloseInvoked())
+	})
+
+	t.Run("on sink error, the write is retried following the retry policy", func(t *testing.T) {
+		w := fakesink.New(&fakesink.Config{
+			Mocks: []fakesink.Mock{
+				{Error: errors.New("fake error")},
+				{Error: errors.New("fake error")},
+			},
+		}, log)
+		dlq := &deadletter.QueueMock{}
+		policy := &RetryPolicy{MaxAttempts: 3, InitialBackoff: config.Duration(time.Millisecond)}
+		require.NoError(t, policy.Validate())
+
+		p, err := NewWithSinks(log, proc, []Sink{{Sink: w, Retry: policy}}, WithDeadLetterQueue(dlq))
+		require.NoError(t, err)
+
+		runPipeline(t, p)
+
+		p.AddMessage(&entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "key", Value: "fake event"}},
+			OperationType: entities.Write,
+			OriginalRaw:   []byte(`{}`),
+		})
+
+		assert.Eventually(t, func() bool {
+			return len(w.Calls()) == 3
+		}, 1*time.Second, 10*time.Millisecond)
+		assert.Empty(t, dlq.Records())
+	})
+
+	t.Run("when retries are exhausted, the event is sent to the dead letter queue with the attempts", func(t *testing.T) {
+		w := fakesink.New(&fakesink.Config{
+			Mocks: []fakesink.Mock{
+				{Error: errors.New("fake error")},
+				{Error: errors.New("fake error")},
+			},
+		}, log)
+		dlq := &deadletter.QueueMock{}
+		policy := &RetryPolicy{MaxAttempts: 2, InitialBackoff: config.Duration(time.Millisecond)}
+		require.NoError(t, policy.Validate())
+
+		p, err := NewWithSinks(log, proc, []Sink{{Sink: w, Retry: policy}}, WithDeadLetterQueue(dlq))
+		require.NoError(t, err)
+
+		runPipeline(t, p)
+
+		p.AddMessage(&entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "key", Value: "fake event"}},
+			OperationType: entities.Write,
+			OriginalRaw:   []byte(`{}`),
+		})
+
+		assert.Eventually(t, func() bool {
+			return len(dlq.Records()) == 1
+		}, 1*time.Second, 10*time.Millisecond)
+		assert.Len(t, w.Calls(), 2)
+		assert.Equal(t, 2, dlq.Records()[0].Attempts)
+	})
+
+	t.Run("events processed from a disk queue are removed from it", func(t *testing.T) {
+		w := fakesink.New(model, log)
+		q, err := queue.NewDiskQueue(&queue.DiskConfig{Path: filepath.Join(t.TempDir(), "queue.db"), MaxEvents: 10})
+		require.NoError(t, err)
+
+		p, err := New(log, proc, w, WithQueue(q))
+		require.NoError(t, err)
+
+		runPipeline(t, p)
+
+		p.AddMessage(&entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "key", Value: "fake event"}},
+			OperationType: entities.Write,
+			OriginalRaw:   []byte(`{}`),
+		})
+
+		assert.Eventually(t, func() bool {
+			return len(w.Calls()) == 1 && q.Len() == 0
+		}, 1*time.Second, 10*time.Millisecond)
+		require.NoError(t, p.Close(t.Context()))
+	})
+
+	t.Run("the event is acked once processed", func(t *testing.T) {
+		testCases := map[string]struct {
+			mocks []fakesink.Mock
+			dlq   *deadletter.QueueMock
+
+			expectedError string
+		}{
+			"written to the sink": {},
+			"failed to be written to the sink": {
+				mocks:         []fakesink.Mock{{Error: errors.New("fake error")}},
+				expectedError: "sink 0 (fake): fake error",
+			},
+			"failed to be written to the sink and saved in the dead letter queue": {
+				mocks: []fakesink.Mock{{Error: errors.New("fake error")}},
+				dlq:   &deadletter.QueueMock{},
+			},
+			"failed to be written both to the sink and to the dead letter queue": {
+				mocks:         []fakesink.Mock{{Error: errors.New("fake error")}},
+				dlq:           &deadletter.QueueMock{PublishError: errors.New("dlq error")},
+				expectedError: "sink 0 (fake): fake error",
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				w := fakesink.New(&fakesink.Config{Mocks: tc.mocks}, log)
+				opts := []Option{}
+				if tc.dlq != nil {
+					opts = append(opts, WithDeadLetterQueue(tc.dlq))
+				}
+				p, err := NewWithSinks(log, proc, []Sink{{Type: "fake", Sink: w}}, opts...)
+				require.NoError(t, err)
+
+				runPipeline(t, p)
+
+				acks := make(chan error, 1)
+				event := &entities.Event{
+					PrimaryKeys:   entities.PkFields{{Key: "key", Value: "fake event"}},
+					OperationType: entities.Write,
+					OriginalRaw:   []byte(`{}`),
+				}
+				event.WithAck(func(err error) { acks <- err })
+				p.AddMessage(event)
+
+				select {
+				case err := <-acks:
+					if tc.expectedError != "" {
+						require.EqualError(t, err, tc.expectedError)
+					} else {
+						require.NoError(t, err)
+					}
+				case <-time.After(time.Second):
+					require.Fail(t, "event not acked")
+				}
+			})
+		}
+	})
+
+	t.Run("the event failed by a processor with a transient error is not dropped", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		testCases := map[string]struct {
+			dlq *deadletter.QueueMock
+
+			expectedError string
+		}{
+			"saved in the dead letter queue": {
+				dlq: &deadletter.QueueMock{},
+			},
+			"delivered again by the source": {
+				expectedError: "retryable: unexpected response status: 503 from " + server.URL + "/issue",
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				w := fakesink.New(nil, log)
+				proc, err := processors.New(log, config.Processors{
+					{
+						Type: processors.HTTPEnrich,
+						Raw:  []byte(`{"type":"http-enrich","url":"` + server.URL + `/issue"}`),
+					},
+				})
+				require.NoError(t, err)
+
+				opts := []Option{}
+				if tc.dlq != nil {
+					opts = append(opts, WithDeadLetterQueue(tc.dlq))
+				}
+				p, err := New(log, proc, w, opts...)
+				require.NoError(t, err)
+				runPipeline(t, p)
+
+				acks := make(chan error, 1)
+				event := &entities.Event{
+					PrimaryKeys:   entities.PkFields{{Key: "key", Value: "fake event"}},
+					OperationType: entities.Write,
+					OriginalRaw:   []byte(`{"id":"1"}`),
+				}
+				event.WithAck(func(err error) { acks <- err })
+				p.AddMessage(event)
+
+				select {
+				case err := <-acks:
+					if tc.expectedError != "" {
+						require.EqualError(t, err, tc.expectedError)
+					} else {
+						require.NoError(t, err)
+					}
+				case <-time.After(time.Second):
+					require.Fail(t, "event not acked")
+				}
+				require.Empty(t, w.Calls())
+
+				if tc.dlq != nil {
+					records := tc.dlq.Records()
+					require.Len(t, records, 1)
+					require.Equal(t, "processors", records[0].SinkType)
+					require.JSONEq(t, `{"id":"1"}`, string(records[0].Data))
+				}
+			})
+		}
+	})
+
+	t.Run("the processed event is acked once written to the sinks", func(t *testing.T) {
+		w := fakesink.New(&fakesink.Config{Mocks: []fakesink.Mock{{Error: errors.New("fake error")}}}, log)
+		proc, err := processors.New(log, config.Processors{
+			{
+				Type: processors.Dedupe,
+				Raw:  []byte(`{"type":"dedupe","window":"1m"}`),
+			},
+		})
+		require.NoError(t, err)
+
+		p, err := New(log, proc, w)
+		require.NoError(t, err)
+		runPipeline(t, p)
+
+		// the event not delivered is not a duplicate when delivered again
+		for range 3 {
+			p.AddMessage(&entities.Event{
+				PrimaryKeys:   entities.PkFields{{Key: "key", Value: "fake event"}},
+				OperationType: entities.Write,
+				OriginalRaw:   []byte(`{}`),
+			})
+		}
+
+		require.Eventually(t, func() bool {
+			return len(w.Calls()) == 2
+		}, 1*time.Second, 10*time.Millisecond)
+		require.Never(t, func() bool {
+			return len(w.Calls()) > 2
+		}, 100*time.Millisecond, 10*time.Millisecond)
+	})
+
+	t.Run("each event returned by the processors is written to the sinks", func(t *testing.T) {
+		w := fakesink.New(nil, log)
+		proc, err := processors.New(log, config.Processors{
+			{
+				Type: processors.Split,
+				Raw:  []byte(`{"type":"split","path":"assets","primaryKeys":[{"key":"name","value":"{{ name }}"}]}`),
+			},
+			{
+				Type: processors.Filter,
+				Raw:  []byte(`{"type":"filter","celExpression":"data.name != 'skipped'"}`),
+			},
+		})
+		require.NoError(t, err)
+
+		p, err := New(log, proc, w)
+		require.NoError(t, err)
+		runPipeline(t, p)
+
+		p.AddMessage(&entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "key", Value: "fake event"}},
+			OperationType: entities.Write,
+			OriginalRaw:   []byte(`{"assets":[{"name":"first"},{"name":"skipped"},{"name":"second"}]}`),
+		})
+
+		require.Eventually(t, func() bool {
+			return len(w.Calls()) == 2
+		}, 1*time.Second, 10*time.Millisecond)
+		require.Equal(t, entities.PkFields{{Key: "name", Value: "first"}}, w.Calls()[0].Data.GetPrimaryKeys())
+		require.Equal(t, entities.PkFields{{Key: "name", Value: "second"}}, w.Calls()[1].Data.GetPrimaryKeys())
+	})
+
+	t.Run("events buffered by the processors are flushed", func(t *testing.T) {
+		w := fakesink.New(nil, log)
+		proc, err := processors.New(log, config.Processors{
+			{
+				Type: processors.Aggregate,
+				Raw:  []byte(`{"type":"aggregate","window":"1h"}`),
+			},
+		})
+		require.NoError(t, err)
+
+		p, err := New(log, proc, w)
+		require.NoError(t, err)
+		runPipeline(t, p)
+
+		var acks atomic.Int32
+		for range 3 {
+			event := &entities.Event{
+				PrimaryKeys:   entities.PkFields{{Key: "key", Value: "fake event"}},
+				OperationType: entities.Write,
+				OriginalRaw:   []byte(`{}`),
+			}
+			event.WithAck(func(err error) {
+				assert.NoError(t, err)
+				acks.Add(1)
+			})
+			p.AddMessage(event)
+		}
+		require.Never(t, func() bool {
+			return len(w.Calls()) > 0 || acks.Load() > 0
+		}, 100*time.Millisecond, 10*time.Millisecond, "the buffered events are acknowledged once written")
+
+		// the events still buffered are written when the pipeline is closed
+		require.NoError(t, p.Close(t.Context()))
+		require.Len(t, w.Calls(), 1)
+		data, err := w.Calls()[0].Data.JSON()
+		require.NoError(t, err)
+		require.Equal(t, float64(3), data["count"])
+		require.Equal(t, int32(3), acks.Load())
+	})
+
+	t.Run("close runs every step even if some of them fail", func(t *testing.T) {
+		w := &closeFailingSink{Writer: fakesink.New(nil, log)}
+		dlq := &deadletter.QueueMock{CloseError: errors.New("dlq error")}
+
+		p, err := NewWithSinks(log, &processors.Processors{}, []Sink{{Type: "fake", Sink: w}}, WithDeadLetterQueue(dlq))
+		require.NoError(t, err)
+		runPipeline(t, p)
+		require.Eventually(t, p.(*Pipeline).shutdown.isRunning, 1*time.Second, 10*time.Millisecond)
+
+		err = p.Close(t.Context())
+		require.EqualError(t, err, "sink 0 (fake): sink close error\ndlq error")
+		require.True(t, dlq.CloseInvoked(), "the dead letter queue is closed after the failure of the sinks")
+	})
+
+	t.Run("events buffered by the processors are not acknowledged if the flushed event is not written", func(t *testing.T) {
+		w := fakesink.New(&fakesink.Config{Mocks: []fakesink.Mock{{Error: errors.New("fake error")}}}, log)
+		proc, err := processors.New(log, config.Processors{
+			{
+				Type: processors.Aggregate,
+				Raw:  []byte(`{"type":"aggregate","window":"1h"}`),
+			},
+		})
+		require.NoError(t, err)
+
+		p, err := New(log, proc, w)
+		require.NoError(t, err)
+		runPipeline(t, p)
+		require.Eventually(t, p.(*Pipeline).shutdown.isRunning, 1*time.Second, 10*time.Millisecond)
+
+		var failedAcks atomic.Int32
+		for range 2 {
+			event := &entities.Event{
+				PrimaryKeys:   entities.PkFields{{Key: "key", Value: "fake event"}},
+				OperationType: entities.Write,
+				OriginalRaw:   []byte(`{}`),
+			}
+			event.WithAck(func(err error) {
+				if err != nil {
+					failedAcks.Add(1)
+				}
+			})
+			p.AddMessage(event)
+		}
+		require.NoError(t, p.Close(t.Context()))
+		require.Len(t, w.Calls(), 1)
+		require.Equal(t, int32(2), failedAcks.Load())
+	})
+
+	t.Run("metrics are recorded", func(t *testing.T) {
+		w := fakesink.New(&fakesink.Config{Mocks: []fakesink.Mock{{Error: errors.New("fake error")}}}, log)
+		proc, err := processors.New(log, config.Processors{
+			{
+				Type: processors.Filter,
+				Raw:  []byte(`{"type":"filter","celExpression":"eventType != 'discarded'"}`),
+			},
+		})
+		require.NoError(t, err)
+
+		m := metrics.New()
+		p, err := NewWithSinks(log, proc, []Sink{{Type: "fake", Sink: w}}, WithMetrics(m.Pipeline("integration", 0, "test")))
+		require.NoError(t, err)
+		runPipeline(t, p)
+
+		for _, eventType := range []string{"discarded", "failed", "written"} {
+			p.AddMessage(&entities.Event{
+				PrimaryKeys:   entities.PkFields{{Key: "key", Value: eventType}},
+				Type:          eventType,
+				OperationType: entities.Write,
+				OriginalRaw:   []byte(`{}`),
+			})
+		}
+
+		require.Eventually(t, func() bool {
+			return len(w.Calls()) == 2
+		}, 1*time.Second, 10*time.Millisecond)
+
+		require.Eventually(t, func() bool {
+			recorder := httptest.NewRecorder()
+			m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/-/metrics", nil))
+			body := recorder.Body.String()
+
+			return strings.Contains(body, `integration_connector_agent_events_received_total{integration="integration",pipeline="0",source_type="test"} 3`) &&
+				strings.Contains(body, `integration_connector_agent_events_discarded_total{integration="integration",pipeline="0",processor_index="0",processor_type="filter"} 1`) &&
+				strings.Contains(body, `integration_connector_agent_sink_writes_total{integration="integration",pipeline="0",result="failure",sink_index="0",sink_type="fake"} 1`) &&
+				strings.Contains(body, `integration_connector_agent_sink_writes_total{integration="integration",pipeline="0",result="success",sink_index="0",sink_type="fake"} 1`) &&
+				strings.Contains(body, `integration_connector_agent_queue_depth{integration="integration",pipeline="0"} 0`)
+		}, 1*time.Second, 10*time.Millisecond)
+	})
+
 	t.Run("filter event when filter returns false", func(t *testing.T) {
 		log, hook := test.NewNullLogger()
 		w := fakesink.New(model, log)
@@ -227,6 +667,15 @@ func TestPipeline(t *testing.T) {
 	})
 }
 
+// closeFailingSink is a sink failing to close.
+type closeFailingSink struct {
+	*fakesink.Writer
+}
+
+func (s *closeFailingSink) Close(context.Context) error {
+	return errors.New("sink close error")
+}
+
 func runPipeline(t *testing.T, p IPipeline) {
 	t.Helper()
 
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/mia-platform/integration-connector-agent/entities"
 
@@ -45,15 +46,33 @@ func (pg *Group) Start(ctx context.Context) {
 	}
 }
 
+// AddMessage sends a copy of the event to each pipeline. The event is acknowledged once all
+// the pipelines have processed their copy, and it is not acknowledged if any of them failed.
 func (pg *Group) AddMessage(event entities.PipelineEvent) {
-	for _, p := range pg.pipelines {
-		p.AddMessage(event.Clone())
+	acks := entities.SplitAck(event.Ack, len(pg.pipelines))
+	for i, p := range pg.pipelines {
+		pipelineEvent := event.Clone()
+		pipelineEvent.WithAck(acks[i])
+		p.AddMessage(pipelineEvent)
 	}
 }
 
+// Close closes all the pipelines concurrently, so that they drain their queues within
+// the same deadline set by ctx.
 func (pg *Group) Close(ctx context.Context) error {
-	for _, p := range pg.pipelines {
-		if err := p.Close(ctx); err != nil {
+	var wg sync.WaitGroup
+	closeErrors := make([]error, len(pg.pipelines))
+	for i, p := range pg.pipelines {
+		wg.Add(1)
+		go func(i int, p IPipeline) {
+			defer wg.Done()
+			closeErrors[i] = p.Close(ctx)
+		}(i, p)
+	}
+	wg.Wait()
+
+	for _, err := range closeErrors {
+		if err != nil {
 			pg.errors = append(pg.errors, err)
 		}
 	}
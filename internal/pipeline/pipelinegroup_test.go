@@ -4,6 +4,7 @@
 package pipeline
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -61,4 +62,34 @@ func TestPipelineGroup(t *testing.T) {
 		require.JSONEq(t, `{"field":"some"}`, string(sink1.Calls().LastCall().Data.Data()))
 		require.JSONEq(t, `{"field":"other"}`, string(sink2.Calls().LastCall().Data.Data()))
 	})
+	t.Run("the event is acked once all the pipelines have processed it", func(t *testing.T) {
+		sink1 := fakesink.New(&fakesink.Config{}, logger)
+		sink2 := fakesink.New(&fakesink.Config{Mocks: fakesink.Mocks{{Error: errors.New("fake error")}}}, logger)
+
+		p1, err := New(logger, proc1, sink1)
+		require.NoError(t, err)
+		p2, err := New(logger, proc2, sink2)
+		require.NoError(t, err)
+
+		pg := NewGroup(logger, p1, p2)
+		pg.Start(t.Context())
+
+		acks := make(chan error, 2)
+		event := &entities.Event{
+			PrimaryKeys: entities.PkFields{{Key: "id", Value: "123"}},
+			OriginalRaw: []byte(`{"id":"123"}`),
+		}
+		event.WithAck(func(err error) { acks <- err })
+		pg.AddMessage(event)
+
+		select {
+		case err := <-acks:
+			require.EqualError(t, err, "sink 0 (): fake error")
+		case <-time.After(time.Second):
+			require.Fail(t, "event not acked")
+		}
+		require.Len(t, sink1.Calls(), 1)
+		require.Len(t, sink2.Calls(), 1)
+		require.Empty(t, acks)
+	})
 }
@@ -0,0 +1,263 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package queue
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	defaultDiskMaxEvents = 100000
+	openTimeout          = 5 * time.Second
+)
+
+var eventsBucket = []byte("events")
+
+type DiskConfig struct {
+	// Path is the file where the queue is stored. Each pipeline must use a different file.
+	Path      string `json:"path"`
+	MaxEvents int    `json:"maxEvents,omitempty"`
+}
+
+func (c *DiskConfig) Validate() error {
+	if c.Path == "" {
+		return errors.New("path is required")
+	}
+	if c.MaxEvents < 0 {
+		return errors.New("maxEvents must not be negative")
+	}
+	if c.MaxEvents == 0 {
+		c.MaxEvents = defaultDiskMaxEvents
+	}
+	return nil
+}
+
+// storedEvent is the representation of an event saved on disk.
+type storedEvent struct {
+	PrimaryKeys entities.PkFields  `json:"primaryKeys"`
+	Type        string             `json:"type"`
+	Operation   entities.Operation `json:"operation"`
+	Data        []byte             `json:"data"`
+}
+
+// DiskQueue is a bounded queue persisted in a bbolt database. Events are removed from
+// the file only when done, so the ones not yet processed when the process stops are
+// popped again once the queue is reopened.
+type DiskQueue struct {
+	db *bolt.DB
+
+	// slots has a buffered element for each event in the queue, used to block Push when full.
+	slots chan struct{}
+	// notify is signaled when a new event is pushed.
+	notify chan struct{}
+
+	mtx sync.Mutex
+	// next is the key of the first event not yet popped.
+	next uint64
+	// acks holds the ack callbacks of the events pushed by this process, which cannot be stored on disk.
+	acks map[uint64]entities.AckFunc
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func NewDiskQueue(cfg *DiskConfig) (*DiskQueue, error) {
+	db, err := bolt.Open(cfg.Path, 0o600, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open disk queue: %w", err)
+	}
+
+	pending := 0
+	err = db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(eventsBucket)
+		if err != nil {
+			return err
+		}
+		pending = bucket.Stats().KeyN
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open disk queue: %w", err)
+	}
+
+	q := &DiskQueue{
+		db:     db,
+		slots:  make(chan struct{}, max(cfg.MaxEvents, pending)),
+		notify: make(chan struct{}, 1),
+		acks:   make(map[uint64]entities.AckFunc),
+		closed: make(chan struct{}),
+	}
+	for range pending {
+		q.slots <- struct{}{}
+	}
+
+	return q, nil
+}
+
+func (q *DiskQueue) Push(ctx context.Context, event entities.PipelineEvent) error {
+	value, err := json.Marshal(storedEvent{
+		PrimaryKeys: event.GetPrimaryKeys(),
+		Type:        event.GetType(),
+		Operation:   event.Operation(),
+		Data:        event.Data(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	select {
+	case <-q.closed:
+		return ErrQueueClosed
+	default:
+	}
+
+	select {
+	case q.slots <- struct{}{}:
+	case <-q.closed:
+		return ErrQueueClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	// the lock prevents the event from being popped before its ack is registered
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	var seq uint64
+	err = q.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(eventsBucket)
+		seq, err = bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		return bucket.Put(encodeKey(seq), value)
+	})
+	if err != nil {
+		<-q.slots
+		return fmt.Errorf("failed to save event: %w", err)
+	}
+	q.acks[seq] = event.Ack
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (q *DiskQueue) Pop(ctx context.Context) (*Item, error) {
+	for {
+		select {
+		case <-q.closed:
+			return nil, ErrQueueClosed
+		default:
+		}
+
+		item, err := q.popNext()
+		if err != nil || item != nil {
+			return item, err
+		}
+
+		select {
+		case <-q.notify:
+		case <-q.closed:
+			return nil, ErrQueueClosed
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (q *DiskQueue) popNext() (*Item, error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	var key, value []byte
+	err := q.db.View(func(tx *bolt.Tx) error {
+		k, v := tx.Bucket(eventsBucket).Cursor().Seek(encodeKey(q.next))
+		if k != nil {
+			key = append([]byte{}, k...)
+			value = append([]byte{}, v...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event: %w", err)
+	}
+	if key == nil {
+		return nil, nil
+	}
+	seq := binary.BigEndian.Uint64(key)
+	q.next = seq + 1
+
+	ack, hasAck := q.acks[seq]
+	delete(q.acks, seq)
+
+	var stored storedEvent
+	if err := json.Unmarshal(value, &stored); err != nil {
+		// a corrupted entry cannot be processed: drop it and move on
+		decodeErr := fmt.Errorf("failed to decode event: %w", err)
+		if hasAck {
+			ack(decodeErr)
+		}
+		return nil, errors.Join(decodeErr, q.remove(key))
+	}
+
+	event := &entities.Event{
+		PrimaryKeys:   stored.PrimaryKeys,
+		Type:          stored.Type,
+		OperationType: stored.Operation,
+		OriginalRaw:   stored.Data,
+	}
+	if hasAck {
+		event.WithAck(ack)
+	}
+
+	return &Item{
+		Event: event,
+		Done:  func() error { return q.remove(key) },
+	}, nil
+}
+
+func (q *DiskQueue) remove(key []byte) error {
+	err := q.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(eventsBucket).Delete(key)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove event: %w", err)
+	}
+
+	<-q.slots
+	return nil
+}
+
+func (q *DiskQueue) Len() int {
+	return len(q.slots)
+}
+
+func (q *DiskQueue) Close() error {
+	var err error
+	q.closeOnce.Do(func() {
+		close(q.closed)
+		err = q.db.Close()
+	})
+	return err
+}
+
+func encodeKey(seq uint64) []byte {
+	key := make([]byte, 8) //nolint: mnd
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
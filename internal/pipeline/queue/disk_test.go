@@ -0,0 +1,175 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskQueue(t *testing.T) {
+	t.Run("pop returns the events in order", func(t *testing.T) {
+		q := newDiskQueue(t, filepath.Join(t.TempDir(), "queue.db"), 10)
+
+		require.NoError(t, q.Push(t.Context(), newEvent("1")))
+		require.NoError(t, q.Push(t.Context(), newEvent("2")))
+		require.Equal(t, 2, q.Len())
+
+		item, err := q.Pop(t.Context())
+		require.NoError(t, err)
+		requireEvent(t, newEvent("1"), item.Event)
+		require.NoError(t, item.Done())
+		require.Equal(t, 1, q.Len())
+
+		item, err = q.Pop(t.Context())
+		require.NoError(t, err)
+		requireEvent(t, newEvent("2"), item.Event)
+		require.NoError(t, item.Done())
+		require.Equal(t, 0, q.Len())
+	})
+
+	t.Run("pop waits for a new event", func(t *testing.T) {
+		q := newDiskQueue(t, filepath.Join(t.TempDir(), "queue.db"), 10)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			q.Push(t.Context(), newEvent("1"))
+		}()
+
+		item, err := q.Pop(t.Context())
+		require.NoError(t, err)
+		requireEvent(t, newEvent("1"), item.Event)
+	})
+
+	t.Run("popped events keep the ack of the pushed ones", func(t *testing.T) {
+		q := newDiskQueue(t, filepath.Join(t.TempDir(), "queue.db"), 10)
+
+		var ackErr error
+		event := newEvent("1")
+		event.WithAck(func(err error) { ackErr = err })
+		require.NoError(t, q.Push(t.Context(), event))
+
+		item, err := q.Pop(t.Context())
+		require.NoError(t, err)
+		item.Event.Ack(errors.New("some error"))
+		require.EqualError(t, ackErr, "some error")
+	})
+
+	t.Run("push blocks until an event is done", func(t *testing.T) {
+		q := newDiskQueue(t, filepath.Join(t.TempDir(), "queue.db"), 1)
+		require.NoError(t, q.Push(t.Context(), newEvent("1")))
+
+		item, err := q.Pop(t.Context())
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, q.Push(ctx, newEvent("2")), context.DeadlineExceeded)
+
+		require.NoError(t, item.Done())
+		require.NoError(t, q.Push(t.Context(), newEvent("2")))
+	})
+
+	t.Run("events not done are popped again after reopening the queue", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "queue.db")
+
+		q, err := NewDiskQueue(&DiskConfig{Path: path, MaxEvents: 10})
+		require.NoError(t, err)
+		require.NoError(t, q.Push(t.Context(), newEvent("1")))
+		require.NoError(t, q.Push(t.Context(), newEvent("2")))
+		require.NoError(t, q.Push(t.Context(), &entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "id", Value: "3"}},
+			Type:          "event-type",
+			OperationType: entities.Delete,
+		}))
+
+		item, err := q.Pop(t.Context())
+		require.NoError(t, err)
+		require.NoError(t, item.Done())
+
+		// popped but not done, it must be returned again
+		_, err = q.Pop(t.Context())
+		require.NoError(t, err)
+		require.NoError(t, q.Close())
+
+		q = newDiskQueue(t, path, 10)
+		require.Equal(t, 2, q.Len())
+
+		item, err = q.Pop(t.Context())
+		require.NoError(t, err)
+		requireEvent(t, newEvent("2"), item.Event)
+
+		item, err = q.Pop(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, entities.Delete, item.Event.Operation())
+		require.Equal(t, "3", item.Event.GetPrimaryKeys()[0].Value)
+	})
+
+	t.Run("closed queue", func(t *testing.T) {
+		q, err := NewDiskQueue(&DiskConfig{Path: filepath.Join(t.TempDir(), "queue.db"), MaxEvents: 1})
+		require.NoError(t, err)
+		require.NoError(t, q.Close())
+		require.NoError(t, q.Close())
+
+		require.ErrorIs(t, q.Push(t.Context(), newEvent("1")), ErrQueueClosed)
+		_, err = q.Pop(t.Context())
+		require.ErrorIs(t, err, ErrQueueClosed)
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("default queue is in memory", func(t *testing.T) {
+		q, err := New(nil)
+		require.NoError(t, err)
+		require.IsType(t, &MemoryQueue{}, q)
+	})
+
+	t.Run("memory queue", func(t *testing.T) {
+		q, err := New(&config.GenericConfig{Type: Memory, Raw: []byte(`{"type":"memory","maxEvents":5}`)})
+		require.NoError(t, err)
+		require.Equal(t, 5, cap(q.(*MemoryQueue).events))
+	})
+
+	t.Run("disk queue", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "queue.db")
+		q, err := New(&config.GenericConfig{Type: Disk, Raw: []byte(`{"type":"disk","path":"` + path + `"}`)})
+		require.NoError(t, err)
+		require.IsType(t, &DiskQueue{}, q)
+		require.Equal(t, defaultDiskMaxEvents, cap(q.(*DiskQueue).slots))
+		require.NoError(t, q.Close())
+	})
+
+	t.Run("disk queue without path", func(t *testing.T) {
+		_, err := New(&config.GenericConfig{Type: Disk, Raw: []byte(`{"type":"disk"}`)})
+		require.EqualError(t, err, "configuration not valid: path is required")
+	})
+
+	t.Run("unsupported queue", func(t *testing.T) {
+		_, err := New(&config.GenericConfig{Type: "unknown", Raw: []byte(`{}`)})
+		require.EqualError(t, err, "unsupported queue type: unknown")
+	})
+}
+
+func newDiskQueue(t *testing.T, path string, maxEvents int) *DiskQueue {
+	t.Helper()
+
+	q, err := NewDiskQueue(&DiskConfig{Path: path, MaxEvents: maxEvents})
+	require.NoError(t, err)
+	t.Cleanup(func() { q.Close() })
+	return q
+}
+
+// requireEvent compares the events ignoring their ack callbacks, which cannot be compared.
+func requireEvent(t *testing.T, expected, actual entities.PipelineEvent) {
+	t.Helper()
+	require.Equal(t, expected.Clone(), actual.Clone())
+}
@@ -0,0 +1,81 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+)
+
+type MemoryConfig struct {
+	MaxEvents int `json:"maxEvents,omitempty"`
+}
+
+func (c *MemoryConfig) Validate() error {
+	if c.MaxEvents < 0 {
+		return errors.New("maxEvents must not be negative")
+	}
+	if c.MaxEvents == 0 {
+		c.MaxEvents = DefaultMaxEvents
+	}
+	return nil
+}
+
+// MemoryQueue is a bounded queue backed by a buffered channel. Its content is lost
+// when the process stops.
+type MemoryQueue struct {
+	events chan entities.PipelineEvent
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func NewMemoryQueue(maxEvents int) *MemoryQueue {
+	return &MemoryQueue{
+		events: make(chan entities.PipelineEvent, maxEvents),
+		closed: make(chan struct{}),
+	}
+}
+
+func (q *MemoryQueue) Push(ctx context.Context, event entities.PipelineEvent) error {
+	select {
+	case <-q.closed:
+		return ErrQueueClosed
+	default:
+	}
+
+	select {
+	case q.events <- event:
+		return nil
+	case <-q.closed:
+		return ErrQueueClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (q *MemoryQueue) Pop(ctx context.Context) (*Item, error) {
+	select {
+	case event := <-q.events:
+		return &Item{Event: event, Done: noop}, nil
+	case <-q.closed:
+		return nil, ErrQueueClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (q *MemoryQueue) Len() int {
+	return len(q.events)
+}
+
+func (q *MemoryQueue) Close() error {
+	q.closeOnce.Do(func() { close(q.closed) })
+	return nil
+}
+
+func noop() error { return nil }
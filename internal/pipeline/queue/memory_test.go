@@ -0,0 +1,78 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package queue
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryQueue(t *testing.T) {
+	t.Run("pop returns the events in order", func(t *testing.T) {
+		q := NewMemoryQueue(10)
+
+		require.NoError(t, q.Push(t.Context(), newEvent("1")))
+		require.NoError(t, q.Push(t.Context(), newEvent("2")))
+		require.Equal(t, 2, q.Len())
+
+		item, err := q.Pop(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, newEvent("1"), item.Event)
+		require.NoError(t, item.Done())
+
+		item, err = q.Pop(t.Context())
+		require.NoError(t, err)
+		require.Equal(t, newEvent("2"), item.Event)
+		require.Equal(t, 0, q.Len())
+	})
+
+	t.Run("push blocks while the queue is full", func(t *testing.T) {
+		q := NewMemoryQueue(1)
+		require.NoError(t, q.Push(t.Context(), newEvent("1")))
+
+		ctx, cancel := context.WithTimeout(t.Context(), 20*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, q.Push(ctx, newEvent("2")), context.DeadlineExceeded)
+
+		pushed := make(chan error)
+		go func() { pushed <- q.Push(t.Context(), newEvent("2")) }()
+
+		_, err := q.Pop(t.Context())
+		require.NoError(t, err)
+		require.NoError(t, <-pushed)
+	})
+
+	t.Run("closed queue", func(t *testing.T) {
+		q := NewMemoryQueue(1)
+		require.NoError(t, q.Close())
+		require.NoError(t, q.Close())
+
+		require.ErrorIs(t, q.Push(t.Context(), newEvent("1")), ErrQueueClosed)
+		_, err := q.Pop(t.Context())
+		require.ErrorIs(t, err, ErrQueueClosed)
+	})
+
+	t.Run("pop stops on context done", func(t *testing.T) {
+		q := NewMemoryQueue(1)
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		_, err := q.Pop(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func newEvent(id string) entities.PipelineEvent {
+	return &entities.Event{
+		PrimaryKeys:   entities.PkFields{{Key: "id", Value: id}},
+		Type:          "event-type",
+		OperationType: entities.Write,
+		OriginalRaw:   []byte(`{"id":"` + id + `"}`),
+	}
+}
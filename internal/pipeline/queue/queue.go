@@ -0,0 +1,70 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+)
+
+var (
+	ErrQueueClosed      = errors.New("queue closed")
+	ErrUnsupportedQueue = errors.New("unsupported queue type")
+)
+
+const (
+	Memory = "memory"
+	Disk   = "disk"
+
+	// DefaultMaxEvents is the number of events that a queue can hold when not configured.
+	DefaultMaxEvents = 1000000
+)
+
+// Item is an event popped from the queue. Done must be called once the event has been
+// processed, so that the queue can forget it and free its slot.
+type Item struct {
+	Event entities.PipelineEvent
+	Done  func() error
+}
+
+// Queue buffers the events waiting to be processed by a pipeline.
+type Queue interface {
+	// Push adds the event to the queue, blocking while the queue is full.
+	Push(ctx context.Context, event entities.PipelineEvent) error
+	// Pop returns the next event, blocking until one is available. It returns ErrQueueClosed
+	// once the queue has been closed.
+	Pop(ctx context.Context) (*Item, error)
+	// Len returns the number of events in the queue not yet done.
+	Len() int
+	Close() error
+}
+
+// New returns the Queue described by the given configuration, or an in-memory queue
+// with the default size if no configuration is provided.
+func New(cfg *config.GenericConfig) (Queue, error) {
+	if cfg == nil {
+		return NewMemoryQueue(DefaultMaxEvents), nil
+	}
+
+	switch cfg.Type {
+	case Memory:
+		memoryConfig, err := config.GetConfig[*MemoryConfig](*cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewMemoryQueue(memoryConfig.MaxEvents), nil
+	case Disk:
+		diskConfig, err := config.GetConfig[*DiskConfig](*cfg)
+		if err != nil {
+			return nil, err
+		}
+		return NewDiskQueue(diskConfig)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedQueue, cfg.Type)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"regexp"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+	"github.com/mia-platform/integration-connector-agent/internal/sinks"
+)
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+	backoffMultiplier     = 2
+)
+
+// RetryPolicy describes how many times, and how often, the write of an event to a sink
+// is tried before considering it failed.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts    int             `json:"maxAttempts,omitempty"`
+	InitialBackoff config.Duration `json:"initialBackoff,omitempty"`
+	MaxBackoff     config.Duration `json:"maxBackoff,omitempty"`
+	// Jitter is the fraction, between 0 and 1, of each backoff that is randomized.
+	Jitter float64 `json:"jitter,omitempty"`
+	// RetryableErrors are regular expressions matched against the error message. When
+	// empty, every error is considered retryable.
+	RetryableErrors []string `json:"retryableErrors,omitempty"`
+
+	retryableErrors []*regexp.Regexp
+}
+
+func (p *RetryPolicy) Validate() error {
+	if p.MaxAttempts < 0 {
+		return errors.New("retry maxAttempts must not be negative")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return errors.New("retry jitter must be between 0 and 1")
+	}
+	if p.InitialBackoff == 0 {
+		p.InitialBackoff = config.Duration(defaultInitialBackoff)
+	}
+	if p.MaxBackoff == 0 {
+		p.MaxBackoff = config.Duration(defaultMaxBackoff)
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		return errors.New("retry maxBackoff must be greater than initialBackoff")
+	}
+
+	p.retryableErrors = make([]*regexp.Regexp, 0, len(p.RetryableErrors))
+	for _, expr := range p.RetryableErrors {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("invalid retryable error expression %q: %w", expr, err)
+		}
+		p.retryableErrors = append(p.retryableErrors, re)
+	}
+
+	return nil
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, sinks.ErrNotRetryable) {
+		return false
+	}
+	if len(p.retryableErrors) == 0 {
+		return true
+	}
+
+	for _, re := range p.retryableErrors {
+		if re.MatchString(err.Error()) {
+			return true
+		}
+	}
+	return false
+}
+
+// exponentialBackoff returns the backoff after the given failed attempt, starting from 1, without jitter.
+func (p *RetryPolicy) exponentialBackoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff.Duration()
+	for i := 1; i < attempt && backoff < p.MaxBackoff.Duration(); i++ {
+		backoff *= backoffMultiplier
+	}
+	return min(backoff, p.MaxBackoff.Duration())
+}
+
+// backoff returns the time to wait after the given failed attempt, starting from 1. The jitter is
+// applied before clamping the wait, so that it never exceeds MaxBackoff.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := p.exponentialBackoff(attempt)
+	if p.Jitter > 0 {
+		delta := float64(backoff) * p.Jitter
+		backoff = time.Duration(float64(backoff) - delta + rand.Float64()*2*delta) //nolint: gosec
+	}
+	return min(backoff, p.MaxBackoff.Duration())
+}
+
+// maxWait returns the longest time spent waiting between the attempts, with the highest jitter.
+func (p *RetryPolicy) maxWait() time.Duration {
+	var wait time.Duration
+	for attempt := 1; attempt < p.maxAttempts(); attempt++ {
+		wait += min(time.Duration(float64(p.exponentialBackoff(attempt))*(1+p.Jitter)), p.MaxBackoff.Duration())
+	}
+	return wait
+}
+
+// retry calls fn until it succeeds, the error is not retryable, the attempts are exhausted or
+// the context is done. It returns the number of attempts made and the last error.
+func (p *RetryPolicy) retry(ctx context.Context, fn func() error, onRetry func(attempt int, wait time.Duration, err error)) (int, error) {
+	maxAttempts := p.maxAttempts()
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return attempt, nil
+		}
+		if attempt >= maxAttempts || !p.isRetryable(err) {
+			return attempt, err
+		}
+
+		wait := p.backoff(attempt)
+		if onRetry != nil {
+			onRetry(attempt, wait, err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+	"github.com/mia-platform/integration-connector-agent/internal/sinks"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyValidate(t *testing.T) {
+	testCases := map[string]struct {
+		policy RetryPolicy
+
+		expectedError  string
+		expectedPolicy RetryPolicy
+	}{
+		"set defaults": {
+			policy: RetryPolicy{MaxAttempts: 3},
+			expectedPolicy: RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: config.Duration(defaultInitialBackoff),
+				MaxBackoff:     config.Duration(defaultMaxBackoff),
+			},
+		},
+		"negative attempts": {
+			policy:        RetryPolicy{MaxAttempts: -1},
+			expectedError: "retry maxAttempts must not be negative",
+		},
+		"invalid jitter": {
+			policy:        RetryPolicy{Jitter: 1.5},
+			expectedError: "retry jitter must be between 0 and 1",
+		},
+		"max backoff lower than initial backoff": {
+			policy: RetryPolicy{
+				InitialBackoff: config.Duration(time.Second),
+				MaxBackoff:     config.Duration(time.Millisecond),
+			},
+			expectedError: "retry maxBackoff must be greater than initialBackoff",
+		},
+		"invalid retryable error expression": {
+			policy:        RetryPolicy{RetryableErrors: []string{"("}},
+			expectedError: "invalid retryable error expression \"(\": error parsing regexp: missing closing ): `(`",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.policy.Validate()
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			tc.policy.retryableErrors = nil
+			require.Equal(t, tc.expectedPolicy, tc.policy)
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := &RetryPolicy{
+		InitialBackoff: config.Duration(100 * time.Millisecond),
+		MaxBackoff:     config.Duration(time.Second),
+	}
+	require.NoError(t, policy.Validate())
+
+	require.Equal(t, 100*time.Millisecond, policy.backoff(1))
+	require.Equal(t, 200*time.Millisecond, policy.backoff(2))
+	require.Equal(t, 800*time.Millisecond, policy.backoff(4))
+	require.Equal(t, time.Second, policy.backoff(5))
+	require.Equal(t, time.Second, policy.backoff(100))
+
+	policy.Jitter = 0.5
+	for range 100 {
+		backoff := policy.backoff(1)
+		require.GreaterOrEqual(t, backoff, 50*time.Millisecond)
+		require.LessOrEqual(t, backoff, 150*time.Millisecond)
+
+		backoff = policy.backoff(100)
+		require.GreaterOrEqual(t, backoff, 500*time.Millisecond)
+		require.LessOrEqual(t, backoff, time.Second, "the jitter does not exceed the max backoff")
+	}
+}
+
+func TestRetryPolicyRetry(t *testing.T) {
+	errTransient := errors.New("503 service unavailable")
+	errPermanent := errors.New("400 bad request")
+
+	newPolicy := func(t *testing.T, policy *RetryPolicy) *RetryPolicy {
+		t.Helper()
+		policy.InitialBackoff = config.Duration(time.Millisecond)
+		require.NoError(t, policy.Validate())
+		return policy
+	}
+
+	t.Run("nil policy tries only once", func(t *testing.T) {
+		var policy *RetryPolicy
+		calls := 0
+		attempts, err := policy.retry(t.Context(), func() error {
+			calls++
+			return errTransient
+		}, nil)
+		require.ErrorIs(t, err, errTransient)
+		require.Equal(t, 1, attempts)
+		require.Equal(t, 1, calls)
+	})
+
+	t.Run("retries until success", func(t *testing.T) {
+		policy := newPolicy(t, &RetryPolicy{MaxAttempts: 5})
+		calls := 0
+		retried := 0
+		attempts, err := policy.retry(t.Context(), func() error {
+			calls++
+			if calls < 3 {
+				return errTransient
+			}
+			return nil
+		}, func(int, time.Duration, error) { retried++ })
+		require.NoError(t, err)
+		require.Equal(t, 3, attempts)
+		require.Equal(t, 2, retried)
+	})
+
+	t.Run("stops when attempts are exhausted", func(t *testing.T) {
+		policy := newPolicy(t, &RetryPolicy{MaxAttempts: 3})
+		attempts, err := policy.retry(t.Context(), func() error { return errTransient }, nil)
+		require.ErrorIs(t, err, errTransient)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("does not retry errors not matching the retryable ones", func(t *testing.T) {
+		policy := newPolicy(t, &RetryPolicy{MaxAttempts: 3, RetryableErrors: []string{"^5\\d\\d"}})
+		attempts, err := policy.retry(t.Context(), func() error { return errPermanent }, nil)
+		require.ErrorIs(t, err, errPermanent)
+		require.Equal(t, 1, attempts)
+
+		calls := 0
+		attempts, err = policy.retry(t.Context(), func() error {
+			calls++
+			return errTransient
+		}, nil)
+		require.ErrorIs(t, err, errTransient)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("does not retry errors marked as not retryable by the sink", func(t *testing.T) {
+		policy := newPolicy(t, &RetryPolicy{MaxAttempts: 3, RetryableErrors: []string{"400"}})
+		errNotRetryable := fmt.Errorf("%w: %w", sinks.ErrNotRetryable, errPermanent)
+		attempts, err := policy.retry(t.Context(), func() error { return errNotRetryable }, nil)
+		require.ErrorIs(t, err, errPermanent)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("stops when context is done", func(t *testing.T) {
+		policy := &RetryPolicy{MaxAttempts: 10, InitialBackoff: config.Duration(time.Hour), MaxBackoff: config.Duration(time.Hour)}
+		require.NoError(t, policy.Validate())
+
+		ctx, cancel := context.WithCancel(t.Context())
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+
+		attempts, err := policy.retry(ctx, func() error { return errTransient }, nil)
+		require.ErrorIs(t, err, errTransient)
+		require.Equal(t, 1, attempts)
+	})
+}
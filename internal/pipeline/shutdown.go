@@ -0,0 +1,99 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrUnprocessedEvents = errors.New("pipeline closed with unprocessed events")
+
+// shutdown coordinates the graceful stop of a running pipeline: once draining, the pipeline
+// processes the events left in the queue and stops when it is empty, or when aborted.
+type shutdown struct {
+	mtx     sync.Mutex
+	running bool
+	stopped chan struct{}
+
+	draining     context.Context
+	stopDraining context.CancelFunc
+	aborted      context.Context
+	abort        context.CancelFunc
+}
+
+func newShutdown() *shutdown {
+	draining, stopDraining := context.WithCancel(context.Background())
+	aborted, abort := context.WithCancel(context.Background())
+	return &shutdown{
+		stopped:      make(chan struct{}),
+		draining:     draining,
+		stopDraining: stopDraining,
+		aborted:      aborted,
+		abort:        abort,
+	}
+}
+
+// start marks the pipeline as running. It returns false if the pipeline is already running,
+// or if it has been already stopped.
+func (s *shutdown) start() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.running || s.draining.Err() != nil {
+		return false
+	}
+	s.running = true
+	return true
+}
+
+func (s *shutdown) stop() {
+	close(s.stopped)
+}
+
+// isRunning reports whether the pipeline has been started and its loop has not returned yet.
+func (s *shutdown) isRunning() bool {
+	s.mtx.Lock()
+	running := s.running
+	s.mtx.Unlock()
+
+	if !running {
+		return false
+	}
+	select {
+	case <-s.stopped:
+		return false
+	default:
+		return true
+	}
+}
+
+func (s *shutdown) isDraining() bool {
+	return s.draining.Err() != nil
+}
+
+func (s *shutdown) isAborted() bool {
+	return s.aborted.Err() != nil
+}
+
+// drain asks the pipeline to stop once the queue is empty, and waits for it until ctx is done:
+// then the elaboration of the current event is interrupted.
+func (s *shutdown) drain(ctx context.Context) {
+	s.mtx.Lock()
+	running := s.running
+	s.stopDraining()
+	s.mtx.Unlock()
+
+	if !running {
+		return
+	}
+
+	select {
+	case <-s.stopped:
+	case <-ctx.Done():
+		s.abort()
+		<-s.stopped
+	}
+}
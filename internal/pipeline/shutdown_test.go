@@ -0,0 +1,157 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/processors"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipelineClose(t *testing.T) {
+	newEvent := func(id string) *entities.Event {
+		return &entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "key", Value: id}},
+			OperationType: entities.Write,
+			OriginalRaw:   []byte(`{}`),
+		}
+	}
+
+	t.Run("queued events are processed before closing", func(t *testing.T) {
+		log, _ := test.NewNullLogger()
+		sink := newBlockingSink()
+		p, err := New(log, &processors.Processors{}, sink)
+		require.NoError(t, err)
+		runPipeline(t, p)
+
+		for _, id := range []string{"1", "2", "3"} {
+			p.AddMessage(newEvent(id))
+		}
+		require.Eventually(t, func() bool { return sink.started() == 1 }, time.Second, 10*time.Millisecond)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			sink.release()
+		}()
+
+		ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+		defer cancel()
+		require.NoError(t, p.Close(ctx))
+		require.Equal(t, 3, sink.written())
+		require.True(t, sink.closed())
+	})
+
+	t.Run("events not processed before the deadline are reported", func(t *testing.T) {
+		log, hook := test.NewNullLogger()
+		sink := newBlockingSink()
+		p, err := New(log, &processors.Processors{}, sink)
+		require.NoError(t, err)
+		runPipeline(t, p)
+
+		var ackErr error
+		acked := make(chan struct{})
+		first := newEvent("1")
+		first.WithAck(func(err error) {
+			ackErr = err
+			close(acked)
+		})
+		p.AddMessage(first)
+		p.AddMessage(newEvent("2"))
+		p.AddMessage(newEvent("3"))
+		require.Eventually(t, func() bool { return sink.started() == 1 }, time.Second, 10*time.Millisecond)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		defer cancel()
+		err = p.Close(ctx)
+		require.ErrorIs(t, err, ErrUnprocessedEvents)
+		require.EqualError(t, err, "pipeline closed with unprocessed events: 2")
+		require.Equal(t, 0, sink.written())
+		require.True(t, sink.closed())
+
+		// the interrupted event is acked with an error, so that the source delivers it again
+		<-acked
+		require.ErrorIs(t, ackErr, context.Canceled)
+		require.Equal(t, "pipeline closed before processing all the queued events", hook.LastEntry().Message)
+		require.Equal(t, 2, hook.LastEntry().Data["unprocessedEvents"])
+	})
+
+	t.Run("pipeline not started is closed", func(t *testing.T) {
+		log, _ := test.NewNullLogger()
+		sink := newBlockingSink()
+		p, err := New(log, &processors.Processors{}, sink)
+		require.NoError(t, err)
+
+		require.NoError(t, p.Close(t.Context()))
+		require.True(t, sink.closed())
+		require.NoError(t, p.Start(t.Context()), "a closed pipeline does not start")
+	})
+}
+
+// blockingSink blocks each write until released, or until the write context is done.
+type blockingSink struct {
+	mtx          sync.Mutex
+	startedCount int
+	writtenCount int
+	isClosed     bool
+
+	releaseOnce sync.Once
+	released    chan struct{}
+}
+
+func newBlockingSink() *blockingSink {
+	return &blockingSink{released: make(chan struct{})}
+}
+
+func (s *blockingSink) WriteData(ctx context.Context, _ entities.PipelineEvent) error {
+	s.mtx.Lock()
+	s.startedCount++
+	s.mtx.Unlock()
+
+	select {
+	case <-s.released:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.writtenCount++
+	return nil
+}
+
+func (s *blockingSink) Close(_ context.Context) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.isClosed = true
+	return nil
+}
+
+func (s *blockingSink) release() {
+	s.releaseOnce.Do(func() { close(s.released) })
+}
+
+func (s *blockingSink) started() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.startedCount
+}
+
+func (s *blockingSink) written() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.writtenCount
+}
+
+func (s *blockingSink) closed() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.isClosed
+}
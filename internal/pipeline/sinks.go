@@ -0,0 +1,240 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/deadletter"
+	"github.com/mia-platform/integration-connector-agent/internal/sinks"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ErrSinkSkipped is the failure of the sinks not written with the ordered policy, because
+// a previous sink failed.
+var ErrSinkSkipped = errors.New("event not written because a previous sink failed")
+
+// SinksPolicy defines how the writes of an event to the sinks of a pipeline are performed,
+// and when the event is considered successfully written.
+type SinksPolicy string
+
+const (
+	// AllSinks writes the event to all the sinks concurrently, and succeeds only if every write succeeds.
+	AllSinks SinksPolicy = "all"
+	// BestEffortSinks writes the event to all the sinks concurrently, and always succeeds: the failures
+	// are only reported.
+	BestEffortSinks SinksPolicy = "best-effort"
+	// OrderedSinks writes the event to a sink at a time, in the configured order, and stops at the first
+	// failure, so that a sink is written only if all the previous ones succeeded.
+	OrderedSinks SinksPolicy = "ordered"
+)
+
+func (p SinksPolicy) Validate() error {
+	switch p {
+	case "", AllSinks, BestEffortSinks, OrderedSinks:
+		return nil
+	default:
+		return fmt.Errorf("unsupported sinks policy: %s", p)
+	}
+}
+
+// Sink is a destination of the events processed by the pipeline.
+type Sink struct {
+	// Type is the sink type, used to report where an event failed to be written.
+	Type  string
+	Sink  sinks.Sink[entities.PipelineEvent]
+	Retry *RetryPolicy
+}
+
+// SinkError is the failure of writing an event to one of the sinks of the pipeline.
+type SinkError struct {
+	Index    int
+	Type     string
+	Attempts int
+	Err      error
+
+	// DeadLettered is true when the event has been saved in the dead letter queue, so it is
+	// not lost even if the write failed.
+	DeadLettered bool
+}
+
+func (e *SinkError) Error() string {
+	return fmt.Sprintf("sink %d (%s): %s", e.Index, e.Type, e.Err)
+}
+
+func (e *SinkError) Unwrap() error {
+	return e.Err
+}
+
+// writeToSinks writes the event to the sinks following the sinks policy. The returned error
+// joins a SinkError for each failed sink, and is nil when the event is considered written.
+func (p Pipeline) writeToSinks(ctx context.Context, event entities.PipelineEvent) error {
+	var sinkErrors []error
+	if p.sinksPolicy == OrderedSinks {
+		sinkErrors = p.writeToSinksInOrder(ctx, event)
+	} else {
+		sinkErrors = p.writeToSinksConcurrently(ctx, event)
+	}
+
+	if p.sinksPolicy == BestEffortSinks {
+		return nil
+	}
+	return errors.Join(sinkErrors...)
+}
+
+// writeToSinksInOrder writes the event to a sink at a time. When a sink fails, the following
+// sinks are reported as failed with ErrSinkSkipped, so that the event is not considered delivered
+// even if the failed sink saved it in the dead letter queue.
+func (p Pipeline) writeToSinksInOrder(ctx context.Context, event entities.PipelineEvent) []error {
+	for i := range p.sinks {
+		if err := p.writeToSink(ctx, i, event); err != nil {
+			sinkErrors := []error{err}
+			for skipped := i + 1; skipped < len(p.sinks); skipped++ {
+				p.logger.WithFields(logrus.Fields{
+					"eventType":   event.GetType(),
+					"primaryKeys": event.GetPrimaryKeys().Map(),
+					"sinkIndex":   skipped,
+					"sinkType":    p.sinks[skipped].Type,
+				}).Warn("event not written to sink because a previous sink failed")
+				sinkErrors = append(sinkErrors, &SinkError{Index: skipped, Type: p.sinks[skipped].Type, Err: ErrSinkSkipped})
+			}
+			return sinkErrors
+		}
+	}
+	return nil
+}
+
+func (p Pipeline) writeToSinksConcurrently(ctx context.Context, event entities.PipelineEvent) []error {
+	if len(p.sinks) == 1 {
+		if err := p.writeToSink(ctx, 0, event); err != nil {
+			return []error{err}
+		}
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	sinkErrors := make([]error, len(p.sinks))
+	for i := range p.sinks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// each sink receives its own copy of the event, as sinks may read it concurrently
+			sinkErrors[i] = p.writeToSink(ctx, i, event.Clone())
+		}(i)
+	}
+	wg.Wait()
+
+	failed := make([]error, 0, len(sinkErrors))
+	for _, err := range sinkErrors {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	return failed
+}
+
+// writeToSink writes the event to the sink at the given index, retrying it as described by its
+// retry policy. When all the attempts fail, the event is sent to the dead letter queue.
+func (p Pipeline) writeToSink(ctx context.Context, index int, event entities.PipelineEvent) error {
+	sink := p.sinks[index]
+
+	start := time.Now()
+	attempts, err := sink.Retry.retry(ctx, func() error {
+		return sink.Sink.WriteData(ctx, event)
+	}, func(attempt int, wait time.Duration, err error) {
+		p.logger.WithError(err).WithFields(logrus.Fields{
+			"eventType":   event.GetType(),
+			"primaryKeys": event.GetPrimaryKeys().Map(),
+			"sinkIndex":   index,
+			"sinkType":    sink.Type,
+			"attempt":     attempt,
+			"backoff":     wait.String(),
+		}).Warn("error writing data to sink, retrying")
+	})
+	p.metrics.ObserveSinkWrite(index, sink.Type, time.Since(start), err)
+	if err != nil {
+		p.logger.WithError(err).WithFields(logrus.Fields{
+			"eventType":        event.GetType(),
+			"primaryKeys":      event.GetPrimaryKeys().Map(),
+			"id":               event.GetPrimaryKeys().Map(),
+			"data":             string(event.Data()),
+			"messageOperation": event.Operation(),
+			"sinkIndex":        index,
+			"sinkType":         sink.Type,
+			"attempts":         attempts,
+		}).Error("error writing data to sink")
+		deadLettered := p.sendToDeadLetterQueue(ctx, event, sink.Type, attempts, err)
+
+		return &SinkError{Index: index, Type: sink.Type, Attempts: attempts, Err: err, DeadLettered: deadLettered}
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"eventType":        event.GetType(),
+		"primaryKeys":      event.GetPrimaryKeys().Map(),
+		"messageOperation": event.Operation(),
+		"sinkIndex":        index,
+		"sinkType":         sink.Type,
+	}).Debug("event successfully written to sink")
+	return nil
+}
+
+// sendToDeadLetterQueue saves the event in the dead letter queue, if configured, and returns
+// whether it has been saved.
+func (p Pipeline) sendToDeadLetterQueue(ctx context.Context, event entities.PipelineEvent, sinkType string, attempts int, writeErr error) bool {
+	if p.deadLetterQueue == nil {
+		return false
+	}
+
+	record := deadletter.NewRecord(event, sinkType, attempts, writeErr)
+	if err := p.deadLetterQueue.Publish(ctx, record); err != nil {
+		p.logger.WithError(err).WithFields(logrus.Fields{
+			"eventType":   event.GetType(),
+			"primaryKeys": event.GetPrimaryKeys().Map(),
+			"sinkType":    sinkType,
+		}).Error("error sending event to dead letter queue")
+		return false
+	}
+
+	p.logger.WithFields(logrus.Fields{
+		"eventType":   event.GetType(),
+		"primaryKeys": event.GetPrimaryKeys().Map(),
+		"sinkType":    sinkType,
+	}).Debug("event sent to dead letter queue")
+	return true
+}
+
+// undeliveredError returns the errors of the sinks that failed to write the event, ignoring
+// the failures for which the event has been saved in the dead letter queue.
+func undeliveredError(err error) error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return err
+	}
+
+	undelivered := make([]error, 0)
+	for _, sinkErr := range joined.Unwrap() {
+		var target *SinkError
+		if errors.As(sinkErr, &target) && target.DeadLettered {
+			continue
+		}
+		undelivered = append(undelivered, sinkErr)
+	}
+	return errors.Join(undelivered...)
+}
+
+func (p Pipeline) closeSinks(ctx context.Context) error {
+	var closeErrors []error
+	for i, sink := range p.sinks {
+		if err := sink.Sink.Close(ctx); err != nil {
+			closeErrors = append(closeErrors, &SinkError{Index: i, Type: sink.Type, Err: err})
+		}
+	}
+	return errors.Join(closeErrors...)
+}
@@ -0,0 +1,143 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/deadletter"
+	"github.com/mia-platform/integration-connector-agent/internal/processors"
+	fakesink "github.com/mia-platform/integration-connector-agent/internal/sinks/fake"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteToSinks(t *testing.T) {
+	event := &entities.Event{
+		PrimaryKeys:   entities.PkFields{{Key: "key", Value: "fake event"}},
+		OperationType: entities.Write,
+		OriginalRaw:   []byte(`{}`),
+	}
+
+	testCases := map[string]struct {
+		policy     SinksPolicy
+		sinkErrors []error
+
+		expectedCalls      []int
+		expectedSinkErrors []int
+		expectedDLQ        []string
+	}{
+		"all sinks succeed": {
+			policy:        AllSinks,
+			sinkErrors:    []error{nil, nil, nil},
+			expectedCalls: []int{1, 1, 1},
+		},
+		"all policy fails if a sink fails": {
+			policy:             AllSinks,
+			sinkErrors:         []error{nil, errors.New("fake error"), errors.New("fake error")},
+			expectedCalls:      []int{1, 1, 1},
+			expectedSinkErrors: []int{1, 2},
+			expectedDLQ:        []string{"sink-1", "sink-2"},
+		},
+		"best-effort policy succeeds if a sink fails": {
+			policy:        BestEffortSinks,
+			sinkErrors:    []error{errors.New("fake error"), nil, nil},
+			expectedCalls: []int{1, 1, 1},
+			expectedDLQ:   []string{"sink-0"},
+		},
+		"ordered policy stops at the first failure": {
+			policy:             OrderedSinks,
+			sinkErrors:         []error{nil, errors.New("fake error"), nil},
+			expectedCalls:      []int{1, 1, 0},
+			expectedSinkErrors: []int{1},
+			expectedDLQ:        []string{"sink-1"},
+		},
+		"ordered policy writes all the sinks": {
+			policy:        OrderedSinks,
+			sinkErrors:    []error{nil, nil, nil},
+			expectedCalls: []int{1, 1, 1},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			log, _ := test.NewNullLogger()
+			dlq := &deadletter.QueueMock{}
+
+			writers := make([]*fakesink.Writer, 0, len(tc.sinkErrors))
+			targets := make([]Sink, 0, len(tc.sinkErrors))
+			for i, err := range tc.sinkErrors {
+				w := fakesink.New(&fakesink.Config{Mocks: fakesink.Mocks{{Error: err}}}, log)
+				writers = append(writers, w)
+				targets = append(targets, Sink{Type: fmt.Sprintf("sink-%d", i), Sink: w})
+			}
+
+			p, err := NewWithSinks(log, &processors.Processors{}, targets, WithSinksPolicy(tc.policy), WithDeadLetterQueue(dlq))
+			require.NoError(t, err)
+
+			err = p.(*Pipeline).writeToSinks(t.Context(), event)
+			if len(tc.expectedSinkErrors) == 0 {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				for _, index := range tc.expectedSinkErrors {
+					require.ErrorContains(t, err, (&SinkError{Index: index, Type: targets[index].Type, Err: tc.sinkErrors[index]}).Error())
+				}
+
+				var sinkErr *SinkError
+				require.ErrorAs(t, err, &sinkErr)
+				require.Equal(t, 1, sinkErr.Attempts)
+			}
+
+			for i, w := range writers {
+				require.Len(t, w.Calls(), tc.expectedCalls[i], "sink %d", i)
+			}
+
+			dlqSinks := make([]string, 0)
+			for _, record := range dlq.Records() {
+				dlqSinks = append(dlqSinks, record.SinkType)
+			}
+			require.ElementsMatch(t, tc.expectedDLQ, dlqSinks)
+		})
+	}
+
+	t.Run("ordered policy reports the sinks skipped after a dead lettered failure", func(t *testing.T) {
+		log, _ := test.NewNullLogger()
+		dlq := &deadletter.QueueMock{}
+		writers := []*fakesink.Writer{
+			fakesink.New(nil, log),
+			fakesink.New(&fakesink.Config{Mocks: fakesink.Mocks{{Error: errors.New("fake error")}}}, log),
+			fakesink.New(nil, log),
+		}
+		targets := []Sink{{Type: "sink-0", Sink: writers[0]}, {Type: "sink-1", Sink: writers[1]}, {Type: "sink-2", Sink: writers[2]}}
+
+		p, err := NewWithSinks(log, &processors.Processors{}, targets, WithSinksPolicy(OrderedSinks), WithDeadLetterQueue(dlq))
+		require.NoError(t, err)
+
+		err = undeliveredError(p.(*Pipeline).writeToSinks(t.Context(), event))
+		require.EqualError(t, err, "sink 2 (sink-2): event not written because a previous sink failed")
+		require.ErrorIs(t, err, ErrSinkSkipped)
+
+		require.Len(t, writers[2].Calls(), 0)
+		require.Len(t, dlq.Records(), 1)
+		require.Equal(t, "sink-1", dlq.Records()[0].SinkType)
+	})
+
+	t.Run("unsupported sinks policy", func(t *testing.T) {
+		log, _ := test.NewNullLogger()
+		_, err := NewWithSinks(log, &processors.Processors{}, []Sink{{Sink: fakesink.New(nil, log)}}, WithSinksPolicy("unsupported"))
+		require.EqualError(t, err, "unsupported sinks policy: unsupported")
+	})
+
+	t.Run("pipeline does not start if a sink is not defined", func(t *testing.T) {
+		log, _ := test.NewNullLogger()
+		p, err := NewWithSinks(log, &processors.Processors{}, []Sink{{Sink: fakesink.New(nil, log)}, {}})
+		require.NoError(t, err)
+		require.ErrorIs(t, p.Start(t.Context()), ErrWriterNotDefined)
+	})
+}
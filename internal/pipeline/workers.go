@@ -0,0 +1,106 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package pipeline
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/pipeline/queue"
+)
+
+// workerQueueSize is the number of events that can be dispatched to a busy worker, before
+// the dispatch blocks.
+const workerQueueSize = 16
+
+// workers process the events concurrently. Each event is dispatched to the worker chosen by
+// the hash of its primary keys, so that the events of the same entity are processed in order.
+type workers struct {
+	queues []chan *queue.Item
+	wg     sync.WaitGroup
+
+	mtx sync.Mutex
+	// pending is the number of events dispatched and not yet processed.
+	pending     int
+	idleWaiters []context.CancelFunc
+}
+
+func startWorkers(count int, process func(item *queue.Item)) *workers {
+	w := &workers{
+		queues: make([]chan *queue.Item, count),
+	}
+	for i := range w.queues {
+		w.queues[i] = make(chan *queue.Item, workerQueueSize)
+		w.wg.Add(1)
+		go func(items <-chan *queue.Item) {
+			defer w.wg.Done()
+			for item := range items {
+				process(item)
+				w.done()
+			}
+		}(w.queues[i])
+	}
+	return w
+}
+
+func (w *workers) dispatch(item *queue.Item) {
+	w.mtx.Lock()
+	w.pending++
+	w.mtx.Unlock()
+
+	w.queues[partition(item.Event.GetPrimaryKeys(), len(w.queues))] <- item
+}
+
+func (w *workers) done() {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	w.pending--
+	if w.pending == 0 {
+		for _, cancel := range w.idleWaiters {
+			cancel()
+		}
+		w.idleWaiters = nil
+	}
+}
+
+// untilIdle returns a context derived from ctx, that is cancelled once all the dispatched
+// events have been processed. With nil workers the events are processed synchronously,
+// so the returned context is never cancelled because of them.
+func (w *workers) untilIdle(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	if w == nil {
+		return ctx, cancel
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.pending == 0 {
+		return ctx, cancel
+	}
+	w.idleWaiters = append(w.idleWaiters, cancel)
+	return ctx, cancel
+}
+
+// stop waits for the workers to process the events already dispatched.
+func (w *workers) stop() {
+	for _, items := range w.queues {
+		close(items)
+	}
+	w.wg.Wait()
+}
+
+// partition returns the index in [0, count) of the worker processing the events with the given primary keys.
+func partition(keys entities.PkFields, count int) int {
+	hash := fnv.New32a()
+	for _, key := range keys {
+		hash.Write([]byte(key.Key))
+		hash.Write([]byte{0})
+		hash.Write([]byte(key.Value))
+		hash.Write([]byte{0})
+	}
+	return int(hash.Sum32() % uint32(count)) //nolint: gosec
+}
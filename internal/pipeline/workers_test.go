@@ -0,0 +1,121 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/pipeline/queue"
+	"github.com/mia-platform/integration-connector-agent/internal/processors"
+	fakesink "github.com/mia-platform/integration-connector-agent/internal/sinks/fake"
+
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartition(t *testing.T) {
+	keys := entities.PkFields{{Key: "id", Value: "1"}, {Key: "type", Value: "issue"}}
+	require.Equal(t, partition(keys, 8), partition(keys, 8))
+
+	for i := range 100 {
+		index := partition(entities.PkFields{{Key: "id", Value: strconv.Itoa(i)}}, 8)
+		require.GreaterOrEqual(t, index, 0)
+		require.Less(t, index, 8)
+	}
+
+	require.NotEqual(t,
+		partition(entities.PkFields{{Key: "a", Value: "bc"}}, 1<<20),
+		partition(entities.PkFields{{Key: "ab", Value: "c"}}, 1<<20),
+		"keys and values are separated in the hash",
+	)
+}
+
+func TestPipelineWorkers(t *testing.T) {
+	newEvent := func(id string, sequence int) *entities.Event {
+		return &entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "id", Value: id}},
+			OperationType: entities.Write,
+			OriginalRaw:   fmt.Appendf(nil, `{"sequence":%d}`, sequence),
+		}
+	}
+
+	t.Run("events of the same entity are written in order", func(t *testing.T) {
+		log, _ := test.NewNullLogger()
+		sink := fakesink.New(nil, log)
+		p, err := New(log, &processors.Processors{}, sink, WithWorkers(4))
+		require.NoError(t, err)
+		runPipeline(t, p)
+
+		for sequence := range 100 {
+			p.AddMessage(newEvent(strconv.Itoa(sequence%5), sequence))
+		}
+		require.Eventually(t, func() bool { return len(sink.Calls()) == 100 }, time.Second, 10*time.Millisecond)
+
+		lastSequence := map[string]float64{}
+		for _, call := range sink.Calls() {
+			data, err := call.Data.JSON()
+			require.NoError(t, err)
+			id := call.Data.GetPrimaryKeys().Map()["id"]
+			if last, ok := lastSequence[id]; ok {
+				require.Greater(t, data["sequence"], last, "events of %s not in order", id)
+			}
+			lastSequence[id] = data["sequence"].(float64)
+		}
+	})
+
+	t.Run("events of different entities are processed concurrently", func(t *testing.T) {
+		log, _ := test.NewNullLogger()
+		sink := newBlockingSink()
+		p, err := New(log, &processors.Processors{}, sink, WithWorkers(2))
+		require.NoError(t, err)
+		runPipeline(t, p)
+
+		first := "0"
+		second := ""
+		for i := 1; second == ""; i++ {
+			if id := strconv.Itoa(i); partition(entities.PkFields{{Key: "id", Value: id}}, 2) != partition(entities.PkFields{{Key: "id", Value: first}}, 2) {
+				second = id
+			}
+		}
+		p.AddMessage(newEvent(first, 0))
+		p.AddMessage(newEvent(second, 1))
+
+		require.Eventually(t, func() bool { return sink.started() == 2 }, time.Second, 10*time.Millisecond)
+		sink.release()
+		require.NoError(t, p.Close(t.Context()))
+		require.Equal(t, 2, sink.written())
+	})
+
+	t.Run("events in a disk queue are processed before closing", func(t *testing.T) {
+		log, _ := test.NewNullLogger()
+		q, err := queue.NewDiskQueue(&queue.DiskConfig{Path: filepath.Join(t.TempDir(), "queue.db"), MaxEvents: 100})
+		require.NoError(t, err)
+		sink := newBlockingSink()
+		p, err := New(log, &processors.Processors{}, sink, WithQueue(q), WithWorkers(3))
+		require.NoError(t, err)
+		runPipeline(t, p)
+
+		for sequence := range 10 {
+			p.AddMessage(newEvent(strconv.Itoa(sequence), sequence))
+		}
+		require.Eventually(t, func() bool { return sink.started() > 0 }, time.Second, 10*time.Millisecond)
+
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			sink.release()
+		}()
+
+		ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+		defer cancel()
+		require.NoError(t, p.Close(ctx))
+		require.NoError(t, ctx.Err(), "the pipeline stops once the queue is empty")
+		require.Equal(t, 10, sink.written())
+	})
+}
@@ -0,0 +1,211 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package aggregate
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// maxFlushInterval is the longest delay between the end of a window and the emission of its
+// aggregated events.
+const maxFlushInterval = time.Second
+
+// Aggregate buffers the events in time windows, and emits the aggregated events of each group
+// once its window ends. The windows are aligned to the epoch and based on the time the events
+// are processed.
+type Aggregate struct {
+	window    time.Duration
+	mode      Mode
+	groupBy   []GroupField
+	eventType string
+
+	mtx    sync.Mutex
+	groups map[string]*group
+	// now returns the current time, and it is replaced in tests.
+	now func() time.Time
+}
+
+// group is the aggregation of the events with the same group fields.
+type group struct {
+	fields      entities.PkFields
+	windowStart time.Time
+	count       int
+	last        entities.PipelineEvent
+	// acks are the callbacks of the buffered events, invoked once the aggregated event is handled.
+	acks []entities.AckFunc
+}
+
+func (g *group) ack(err error) {
+	for _, ack := range g.acks {
+		ack(err)
+	}
+}
+
+func (a *Aggregate) Process(event entities.PipelineEvent) (entities.PipelineEvent, error) {
+	return entities.ProcessOne(a, event)
+}
+
+// ProcessEvents buffers the event: no events are returned until the window ends. The ack of the
+// event is kept, and invoked with the one of the aggregated event.
+func (a *Aggregate) ProcessEvents(event entities.PipelineEvent) ([]entities.PipelineEvent, error) {
+	fields := a.groupFields(event)
+	windowStart := a.now().Truncate(a.window)
+	// each window of a group is aggregated separately, also when the previous one is not flushed yet
+	key := windowStart.Format(time.RFC3339Nano) + "\x00" + groupKey(fields)
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	g, ok := a.groups[key]
+	if !ok {
+		g = &group{fields: fields, windowStart: windowStart}
+		a.groups[key] = g
+	}
+	g.count++
+	g.last = event
+	if ack := event.GetAck(); ack != nil {
+		g.acks = append(g.acks, ack)
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"eventType":   event.GetType(),
+		"primaryKeys": event.GetPrimaryKeys().Map(),
+		"group":       fields.Map(),
+	}).Trace("event buffered by aggregate")
+	return nil, nil
+}
+
+// Flush returns the aggregated events of the windows ended at now, or of all the windows when final
+// is set. The events are sorted by window and group, and their ack invokes the ones of the events
+// they aggregate. The groups failing to be aggregated are reported in the returned error, and their
+// events are notified of the failure.
+func (a *Aggregate) Flush(now time.Time, final bool) ([]entities.PipelineEvent, error) {
+	a.mtx.Lock()
+	var ended []*group
+	for key, g := range a.groups {
+		if final || !now.Before(g.windowStart.Add(a.window)) {
+			ended = append(ended, g)
+			delete(a.groups, key)
+		}
+	}
+	a.mtx.Unlock()
+
+	slices.SortFunc(ended, func(a, b *group) int {
+		if c := a.windowStart.Compare(b.windowStart); c != 0 {
+			return c
+		}
+		return strings.Compare(groupKey(a.fields), groupKey(b.fields))
+	})
+
+	events := make([]entities.PipelineEvent, 0, len(ended))
+	var errs []error
+	for _, g := range ended {
+		event := g.last
+		if a.mode == Count {
+			var err error
+			if event, err = a.countEvent(g); err != nil {
+				g.ack(err)
+				errs = append(errs, err)
+				continue
+			}
+		}
+		if len(g.acks) > 0 {
+			event.WithAck(g.ack)
+		}
+		events = append(events, event)
+	}
+	return events, errors.Join(errs...)
+}
+
+// FlushInterval returns how often the ended windows are checked.
+func (a *Aggregate) FlushInterval() time.Duration {
+	return min(a.window, maxFlushInterval)
+}
+
+// countEvent returns the event with the number of events of the group in its window. Its primary
+// keys are the group fields and the start of the window.
+func (a *Aggregate) countEvent(g *group) (entities.PipelineEvent, error) {
+	windowStart := g.windowStart.UTC().Format(time.RFC3339)
+	data := []byte("{}")
+	var err error
+	for _, field := range g.fields {
+		if data, err = sjson.SetBytes(data, escapeKey(field.Key), field.Value); err != nil {
+			return nil, fmt.Errorf("error creating aggregated event: %w", err)
+		}
+	}
+	if data, err = sjson.SetBytes(data, countField, g.count); err != nil {
+		return nil, fmt.Errorf("error creating aggregated event: %w", err)
+	}
+	if data, err = sjson.SetBytes(data, windowStartField, windowStart); err != nil {
+		return nil, fmt.Errorf("error creating aggregated event: %w", err)
+	}
+	if data, err = sjson.SetBytes(data, windowEndField, g.windowStart.Add(a.window).UTC().Format(time.RFC3339)); err != nil {
+		return nil, fmt.Errorf("error creating aggregated event: %w", err)
+	}
+
+	primaryKeys := append(slices.Clone(g.fields), entities.PkField{Key: windowStartField, Value: windowStart})
+	return &entities.Event{
+		PrimaryKeys:   primaryKeys,
+		Type:          a.eventType,
+		OperationType: entities.Write,
+		OriginalRaw:   data,
+	}, nil
+}
+
+// groupFields returns the values of the group fields of the event, or its primary keys if the
+// group fields are not set.
+func (a *Aggregate) groupFields(event entities.PipelineEvent) entities.PkFields {
+	if len(a.groupBy) == 0 {
+		return slices.Clone(event.GetPrimaryKeys())
+	}
+
+	fields := make(entities.PkFields, 0, len(a.groupBy))
+	for _, field := range a.groupBy {
+		fields = append(fields, entities.PkField{
+			Key:   field.Key,
+			Value: gjson.GetBytes(event.Data(), field.Path).String(),
+		})
+	}
+	return fields
+}
+
+func groupKey(fields entities.PkFields) string {
+	var key strings.Builder
+	for _, field := range fields {
+		key.WriteString(field.Key)
+		key.WriteByte(0)
+		key.WriteString(field.Value)
+		key.WriteByte(0)
+	}
+	return key.String()
+}
+
+// pathEscaper escapes the characters having a special meaning in the sjson paths.
+var pathEscaper = strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`, "|", `\|`, "#", `\#`, "@", `\@`, ":", `\:`)
+
+func escapeKey(key string) string {
+	return pathEscaper.Replace(key)
+}
+
+func New(cfg Config) (*Aggregate, error) {
+	return &Aggregate{
+		window:    cfg.Window.Duration(),
+		mode:      cfg.mode(),
+		groupBy:   cfg.GroupBy,
+		eventType: cfg.eventType(),
+		groups:    map[string]*group{},
+		now:       time.Now,
+	}, nil
+}
@@ -0,0 +1,177 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package aggregate
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregate(t *testing.T) {
+	start := time.Date(2025, 3, 10, 10, 0, 0, 0, time.UTC)
+	newAggregate := func(t *testing.T, cfg Config) (*Aggregate, *time.Time) {
+		t.Helper()
+
+		cfg.Window = config.Duration(5 * time.Minute)
+		require.NoError(t, cfg.Validate())
+		a, err := New(cfg)
+		require.NoError(t, err)
+
+		now := start
+		a.now = func() time.Time { return now }
+		return a, &now
+	}
+	workflowRun := func(repository, status string) entities.PipelineEvent {
+		return &entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "repository", Value: repository}},
+			Type:          "workflow_run",
+			OperationType: entities.Write,
+			OriginalRaw:   []byte(`{"repository":{"full_name":"` + repository + `"},"status":"` + status + `"}`),
+		}
+	}
+
+	t.Run("count the events of each group per window", func(t *testing.T) {
+		a, now := newAggregate(t, Config{
+			GroupBy:   []GroupField{{Key: "repository", Path: "repository.full_name"}},
+			EventType: "workflow_runs",
+		})
+
+		for _, event := range []entities.PipelineEvent{
+			workflowRun("org/api", "queued"),
+			workflowRun("org/web", "queued"),
+			workflowRun("org/api", "completed"),
+		} {
+			events, err := a.ProcessEvents(event)
+			require.NoError(t, err)
+			require.Empty(t, events)
+		}
+		*now = start.Add(6 * time.Minute)
+		_, err := a.ProcessEvents(workflowRun("org/api", "queued"))
+		require.NoError(t, err)
+
+		events, err := a.Flush(start.Add(4*time.Minute), false)
+		require.NoError(t, err)
+		require.Empty(t, events, "the window is not ended")
+
+		events, err = a.Flush(start.Add(5*time.Minute), false)
+		require.NoError(t, err)
+		require.Equal(t, []entities.PipelineEvent{
+			&entities.Event{
+				PrimaryKeys:   entities.PkFields{{Key: "repository", Value: "org/api"}, {Key: "windowStart", Value: "2025-03-10T10:00:00Z"}},
+				Type:          "workflow_runs",
+				OperationType: entities.Write,
+				OriginalRaw:   []byte(`{"repository":"org/api","count":2,"windowStart":"2025-03-10T10:00:00Z","windowEnd":"2025-03-10T10:05:00Z"}`),
+			},
+			&entities.Event{
+				PrimaryKeys:   entities.PkFields{{Key: "repository", Value: "org/web"}, {Key: "windowStart", Value: "2025-03-10T10:00:00Z"}},
+				Type:          "workflow_runs",
+				OperationType: entities.Write,
+				OriginalRaw:   []byte(`{"repository":"org/web","count":1,"windowStart":"2025-03-10T10:00:00Z","windowEnd":"2025-03-10T10:05:00Z"}`),
+			},
+		}, events)
+
+		events, err = a.Flush(start.Add(5*time.Minute), true)
+		require.NoError(t, err)
+		require.Len(t, events, 1, "the final flush returns the windows not ended")
+		require.JSONEq(t, `{"repository":"org/api","count":1,"windowStart":"2025-03-10T10:05:00Z","windowEnd":"2025-03-10T10:10:00Z"}`, string(events[0].Data()))
+
+		events, err = a.Flush(start.Add(time.Hour), true)
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+
+	t.Run("groups by primary keys by default", func(t *testing.T) {
+		a, _ := newAggregate(t, Config{})
+
+		_, err := a.ProcessEvents(workflowRun("org/api", "queued"))
+		require.NoError(t, err)
+		events, err := a.Flush(start, true)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Equal(t, defaultEventType, events[0].GetType())
+		require.Equal(t, entities.PkFields{{Key: "repository", Value: "org/api"}, {Key: "windowStart", Value: "2025-03-10T10:00:00Z"}}, events[0].GetPrimaryKeys())
+		require.JSONEq(t, `{"repository":"org/api","count":1,"windowStart":"2025-03-10T10:00:00Z","windowEnd":"2025-03-10T10:05:00Z"}`, string(events[0].Data()))
+	})
+
+	t.Run("last event of each group", func(t *testing.T) {
+		a, now := newAggregate(t, Config{Mode: Last})
+
+		_, err := a.ProcessEvents(workflowRun("org/api", "queued"))
+		require.NoError(t, err)
+		_, err = a.ProcessEvents(workflowRun("org/web", "queued"))
+		require.NoError(t, err)
+		*now = start.Add(time.Minute)
+		_, err = a.ProcessEvents(workflowRun("org/api", "completed"))
+		require.NoError(t, err)
+
+		events, err := a.Flush(start.Add(5*time.Minute), false)
+		require.NoError(t, err)
+		require.Equal(t, []entities.PipelineEvent{
+			workflowRun("org/api", "completed"),
+			workflowRun("org/web", "queued"),
+		}, events)
+	})
+
+	t.Run("last event of each group per window", func(t *testing.T) {
+		a, now := newAggregate(t, Config{Mode: Last})
+
+		*now = start.Add(5*time.Minute - time.Millisecond)
+		_, err := a.ProcessEvents(workflowRun("org/api", "queued"))
+		require.NoError(t, err)
+		*now = start.Add(5 * time.Minute)
+		_, err = a.ProcessEvents(workflowRun("org/api", "completed"))
+		require.NoError(t, err)
+
+		events, err := a.Flush(start.Add(5*time.Minute), false)
+		require.NoError(t, err)
+		require.Equal(t, []entities.PipelineEvent{workflowRun("org/api", "queued")}, events, "the event after the boundary is in the next window")
+
+		events, err = a.Flush(start.Add(10*time.Minute), false)
+		require.NoError(t, err)
+		require.Equal(t, []entities.PipelineEvent{workflowRun("org/api", "completed")}, events)
+	})
+
+	t.Run("the aggregated event acks the buffered ones", func(t *testing.T) {
+		a, _ := newAggregate(t, Config{})
+
+		var acks []error
+		for range 2 {
+			event := workflowRun("org/api", "queued")
+			event.WithAck(func(err error) { acks = append(acks, err) })
+			_, err := a.ProcessEvents(event)
+			require.NoError(t, err)
+		}
+		require.Empty(t, acks)
+
+		events, err := a.Flush(start.Add(5*time.Minute), false)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		events[0].Ack(errors.New("sink error"))
+		require.Len(t, acks, 2)
+		for _, ack := range acks {
+			require.EqualError(t, ack, "sink error")
+		}
+	})
+
+	t.Run("process discards the buffered event", func(t *testing.T) {
+		a, _ := newAggregate(t, Config{})
+
+		_, err := a.Process(workflowRun("org/api", "queued"))
+		require.ErrorIs(t, err, entities.ErrDiscardEvent)
+	})
+
+	t.Run("flush interval", func(t *testing.T) {
+		a, _ := newAggregate(t, Config{})
+		require.Equal(t, time.Second, a.FlushInterval())
+
+		a.window = 100 * time.Millisecond
+		require.Equal(t, 100*time.Millisecond, a.FlushInterval())
+	})
+}
@@ -0,0 +1,89 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package aggregate
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+)
+
+var (
+	ErrInvalidWindow      = errors.New("window must be greater than zero")
+	ErrUnsupportedMode    = errors.New("unsupported mode")
+	ErrInvalidGroupField  = errors.New("group field requires key and path")
+	ErrReservedGroupField = errors.New("group field key is reserved")
+)
+
+type Mode string
+
+const (
+	// Count emits, for each group, the number of events received in the window.
+	Count Mode = "count"
+	// Last emits, for each group, the last event received in the window.
+	Last Mode = "last"
+)
+
+const (
+	defaultEventType = "aggregate"
+
+	countField       = "count"
+	windowStartField = "windowStart"
+	windowEndField   = "windowEnd"
+)
+
+type Config struct {
+	// Window is the duration of the time windows the events are aggregated in.
+	Window config.Duration `json:"window"`
+	// Mode is how the events of a group are aggregated, count by default.
+	Mode Mode `json:"mode,omitempty"`
+	// GroupBy are the fields of the payload the events are grouped by. If empty, the events
+	// are grouped by their primary keys.
+	GroupBy []GroupField `json:"groupBy,omitempty"`
+	// EventType is the type of the events emitted in count mode.
+	EventType string `json:"eventType,omitempty"`
+}
+
+type GroupField struct {
+	// Key is the name of the field in the aggregated events.
+	Key string `json:"key"`
+	// Path is the gjson path of the value in the payload of the events.
+	Path string `json:"path"`
+}
+
+func (c Config) Validate() error {
+	if c.Window <= 0 {
+		return ErrInvalidWindow
+	}
+	switch c.Mode {
+	case "", Count, Last:
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedMode, c.Mode)
+	}
+	for i, field := range c.GroupBy {
+		if field.Key == "" || field.Path == "" {
+			return fmt.Errorf("%w: group field %d", ErrInvalidGroupField, i)
+		}
+		switch field.Key {
+		case countField, windowStartField, windowEndField:
+			return fmt.Errorf("%w: %s", ErrReservedGroupField, field.Key)
+		}
+	}
+	return nil
+}
+
+func (c Config) mode() Mode {
+	if c.Mode == "" {
+		return Count
+	}
+	return c.Mode
+}
+
+func (c Config) eventType() string {
+	if c.EventType == "" {
+		return defaultEventType
+	}
+	return c.EventType
+}
@@ -0,0 +1,72 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package aggregate
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("unmarshal json", func(t *testing.T) {
+		cfg := Config{}
+		err := json.Unmarshal([]byte(`{
+			"window": "5m",
+			"mode": "count",
+			"groupBy": [{"key": "repository", "path": "repository.full_name"}],
+			"eventType": "workflow_runs"
+		}`), &cfg)
+		require.NoError(t, err)
+		require.Equal(t, Config{
+			Window:    config.Duration(5 * time.Minute),
+			Mode:      Count,
+			GroupBy:   []GroupField{{Key: "repository", Path: "repository.full_name"}},
+			EventType: "workflow_runs",
+		}, cfg)
+		require.Equal(t, Count, Config{}.mode())
+		require.Equal(t, defaultEventType, Config{}.eventType())
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		testCases := map[string]struct {
+			cfg           Config
+			expectedError string
+		}{
+			"valid": {
+				cfg: Config{Window: config.Duration(time.Minute), Mode: Last},
+			},
+			"without window": {
+				expectedError: "window must be greater than zero",
+			},
+			"unsupported mode": {
+				cfg:           Config{Window: config.Duration(time.Minute), Mode: "sum"},
+				expectedError: "unsupported mode: sum",
+			},
+			"group field without path": {
+				cfg:           Config{Window: config.Duration(time.Minute), GroupBy: []GroupField{{Key: "repository"}}},
+				expectedError: "group field requires key and path: group field 0",
+			},
+			"reserved group field": {
+				cfg:           Config{Window: config.Duration(time.Minute), GroupBy: []GroupField{{Key: "count", Path: "count"}}},
+				expectedError: "group field key is reserved: count",
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				err := tc.cfg.Validate()
+				if tc.expectedError != "" {
+					require.EqualError(t, err, tc.expectedError)
+					return
+				}
+				require.NoError(t, err)
+			})
+		}
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package dedupe
+
+import (
+	"errors"
+
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+var (
+	ErrInvalidWindow         = errors.New("window must be greater than zero")
+	ErrMongoURLNotSet        = errors.New("mongo url is required")
+	ErrMongoCollectionNotSet = errors.New("mongo collection is required")
+	ErrMongoDatabaseNotSet   = errors.New("mongo database is required, in the database field or in the url")
+)
+
+const defaultMaxEntries = 10000
+
+type Config struct {
+	// Fields are the gjson paths of the payload compared to find the duplicates. If empty,
+	// the whole payload is compared.
+	Fields []string `json:"fields,omitempty"`
+	// Window is how long an event is remembered to drop its repeats.
+	Window config.Duration `json:"window"`
+	// MaxEntries is the maximum number of entities remembered in memory.
+	MaxEntries int `json:"maxEntries,omitempty"`
+	// Mongo stores the seen events in a MongoDB collection, shared by all the replicas,
+	// instead of in memory.
+	Mongo *MongoConfig `json:"mongo,omitempty"`
+}
+
+type MongoConfig struct {
+	URL config.SecretSource `json:"url"`
+	// Database is the database of the collection. If empty, the one of the connection string is used.
+	Database   string `json:"database,omitempty"`
+	Collection string `json:"collection"`
+}
+
+// database returns the configured database, or the one of the connection string.
+func (c MongoConfig) database() string {
+	if c.Database != "" {
+		return c.Database
+	}
+	if cs, err := connstring.ParseAndValidate(c.URL.String()); err == nil {
+		return cs.Database
+	}
+	return ""
+}
+
+func (c Config) Validate() error {
+	if c.Window <= 0 {
+		return ErrInvalidWindow
+	}
+	if c.Mongo == nil {
+		return nil
+	}
+	if c.Mongo.URL == "" {
+		return ErrMongoURLNotSet
+	}
+	if c.Mongo.Collection == "" {
+		return ErrMongoCollectionNotSet
+	}
+	if c.Mongo.database() == "" {
+		return ErrMongoDatabaseNotSet
+	}
+	return nil
+}
+
+func (c Config) maxEntries() int {
+	if c.MaxEntries <= 0 {
+		return defaultMaxEntries
+	}
+	return c.MaxEntries
+}
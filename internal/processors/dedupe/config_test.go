@@ -0,0 +1,80 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package dedupe
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("unmarshal json", func(t *testing.T) {
+		t.Setenv("MONGO_URL", "mongodb://localhost:27017/db")
+
+		cfg := Config{}
+		err := json.Unmarshal([]byte(`{
+			"fields": ["issue.fields.status", "issue.fields.summary"],
+			"window": "10m",
+			"maxEntries": 100,
+			"mongo": {"url": {"fromEnv": "MONGO_URL"}, "collection": "dedupe"}
+		}`), &cfg)
+		require.NoError(t, err)
+		require.Equal(t, Config{
+			Fields:     []string{"issue.fields.status", "issue.fields.summary"},
+			Window:     config.Duration(10 * time.Minute),
+			MaxEntries: 100,
+			Mongo:      &MongoConfig{URL: "mongodb://localhost:27017/db", Collection: "dedupe"},
+		}, cfg)
+		require.Equal(t, 100, cfg.maxEntries())
+		require.Equal(t, defaultMaxEntries, Config{}.maxEntries())
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		testCases := map[string]struct {
+			cfg           Config
+			expectedError string
+		}{
+			"valid": {
+				cfg: Config{Window: config.Duration(time.Minute)},
+			},
+			"without window": {
+				expectedError: "window must be greater than zero",
+			},
+			"mongo without url": {
+				cfg:           Config{Window: config.Duration(time.Minute), Mongo: &MongoConfig{Collection: "dedupe"}},
+				expectedError: "mongo url is required",
+			},
+			"mongo without collection": {
+				cfg:           Config{Window: config.Duration(time.Minute), Mongo: &MongoConfig{URL: "mongodb://localhost"}},
+				expectedError: "mongo collection is required",
+			},
+			"mongo without database": {
+				cfg:           Config{Window: config.Duration(time.Minute), Mongo: &MongoConfig{URL: "mongodb://localhost", Collection: "dedupe"}},
+				expectedError: "mongo database is required, in the database field or in the url",
+			},
+			"mongo with database in url": {
+				cfg: Config{Window: config.Duration(time.Minute), Mongo: &MongoConfig{URL: "mongodb://localhost/db", Collection: "dedupe"}},
+			},
+			"mongo with database field": {
+				cfg: Config{Window: config.Duration(time.Minute), Mongo: &MongoConfig{URL: "mongodb://localhost", Database: "db", Collection: "dedupe"}},
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				err := tc.cfg.Validate()
+				if tc.expectedError != "" {
+					require.EqualError(t, err, tc.expectedError)
+					return
+				}
+				require.NoError(t, err)
+			})
+		}
+	})
+}
@@ -0,0 +1,119 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package dedupe
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"strconv"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+// Dedupe discards the events equal to the last one of the same entity, if received within
+// the window. Two events are equal if they have the same primary keys, operation and payload,
+// or the same values of the configured fields.
+type Dedupe struct {
+	fields []string
+	store  store
+}
+
+func (d *Dedupe) Process(event entities.PipelineEvent) (entities.PipelineEvent, error) {
+	primaryKeys := event.GetPrimaryKeys()
+	logger := logrus.WithFields(logrus.Fields{
+		"eventType":   event.GetType(),
+		"primaryKeys": primaryKeys.Map(),
+	})
+	if primaryKeys.IsEmpty() {
+		logger.Debug("event without primary keys not deduplicated")
+		return event, nil
+	}
+
+	key := entityKey(primaryKeys)
+	hash := d.eventHash(event)
+
+	ctx := context.Background()
+	seen, err := d.store.seen(ctx, key, hash)
+	if err != nil {
+		// the store can be unavailable for a while: the event is delivered again instead of dropped
+		return nil, fmt.Errorf("%w: error reading dedupe store: %w", entities.ErrRetryable, err)
+	}
+	if seen {
+		logger.WithField("reason", "duplicate_event").Debug("event skipped by dedupe")
+		return nil, entities.ErrDiscardEvent
+	}
+
+	// the event is processed again if the source delivers it again after a failure
+	ack := event.GetAck()
+	event.WithAck(func(err error) {
+		if err != nil {
+			if err := d.store.forget(ctx, key, hash); err != nil {
+				logger.WithError(err).Warn("error removing undelivered event from dedupe store")
+			}
+		}
+		if ack != nil {
+			ack(err)
+		}
+	})
+	return event, nil
+}
+
+// Close closes the store of the seen events.
+func (d *Dedupe) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	return d.store.close(ctx)
+}
+
+func (d *Dedupe) eventHash(event entities.PipelineEvent) string {
+	h := sha256.New()
+	writeField(h, strconv.Itoa(int(event.Operation())))
+	if len(d.fields) == 0 {
+		h.Write(event.Data())
+		return hex.EncodeToString(h.Sum(nil))
+	}
+
+	for _, field := range d.fields {
+		writeField(h, field)
+		writeField(h, gjson.GetBytes(event.Data(), field).Raw)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func entityKey(primaryKeys entities.PkFields) string {
+	h := sha256.New()
+	for _, field := range primaryKeys {
+		writeField(h, field.Key)
+		writeField(h, field.Value)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeField writes value followed by a separator, so that adjacent values are not confused.
+func writeField(h hash.Hash, value string) {
+	h.Write([]byte(value))
+	h.Write([]byte{0})
+}
+
+func New(cfg Config) (*Dedupe, error) {
+	var s store = newMemoryStore(cfg.maxEntries(), cfg.Window.Duration())
+	if cfg.Mongo != nil {
+		mongoStore, err := newMongoStore(context.Background(), cfg.Mongo, cfg.Window.Duration())
+		if err != nil {
+			return nil, err
+		}
+		s = mongoStore
+	}
+
+	return &Dedupe{
+		fields: cfg.Fields,
+		store:  s,
+	}, nil
+}
@@ -0,0 +1,167 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package dedupe
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDedupe(t *testing.T) {
+	newEvent := func(id string, operation entities.Operation, data string) *entities.Event {
+		return &entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "id", Value: id}},
+			Type:          "issue_updated",
+			OperationType: operation,
+			OriginalRaw:   []byte(data),
+		}
+	}
+
+	type step struct {
+		event     *entities.Event
+		discarded bool
+	}
+
+	testCases := map[string]struct {
+		config Config
+		steps  []step
+	}{
+		"repeated payload is discarded": {
+			config: Config{Window: config.Duration(time.Minute)},
+			steps: []step{
+				{event: newEvent("1", entities.Write, `{"status": "open"}`)},
+				{event: newEvent("1", entities.Write, `{"status": "open"}`), discarded: true},
+				{event: newEvent("2", entities.Write, `{"status": "open"}`)},
+				{event: newEvent("1", entities.Delete, `{"status": "open"}`)},
+			},
+		},
+		"changed payload is not discarded": {
+			config: Config{Window: config.Duration(time.Minute)},
+			steps: []step{
+				{event: newEvent("1", entities.Write, `{"status": "open"}`)},
+				{event: newEvent("1", entities.Write, `{"status": "done"}`)},
+				{event: newEvent("1", entities.Write, `{"status": "open"}`)},
+			},
+		},
+		"only the selected fields are compared": {
+			config: Config{Window: config.Duration(time.Minute), Fields: []string{"status", "assignee"}},
+			steps: []step{
+				{event: newEvent("1", entities.Write, `{"status": "open", "updated": 1}`)},
+				{event: newEvent("1", entities.Write, `{"status": "open", "updated": 2}`), discarded: true},
+				{event: newEvent("1", entities.Write, `{"status": "open", "updated": 3, "assignee": "me"}`)},
+			},
+		},
+		"events without primary keys are not deduplicated": {
+			config: Config{Window: config.Duration(time.Minute)},
+			steps: []step{
+				{event: &entities.Event{OriginalRaw: []byte(`{}`)}},
+				{event: &entities.Event{OriginalRaw: []byte(`{}`)}},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			processor, err := New(tc.config)
+			require.NoError(t, err)
+			defer processor.Close()
+
+			for i, step := range tc.steps {
+				result, err := processor.Process(step.event)
+				if step.discarded {
+					require.ErrorIs(t, err, entities.ErrDiscardEvent, "step %d", i)
+					continue
+				}
+				require.NoError(t, err, "step %d", i)
+				require.Same(t, step.event, result)
+			}
+		})
+	}
+
+	t.Run("repeats are accepted after the window", func(t *testing.T) {
+		processor, err := New(Config{Window: config.Duration(50 * time.Millisecond)})
+		require.NoError(t, err)
+
+		_, err = processor.Process(newEvent("1", entities.Write, `{}`))
+		require.NoError(t, err)
+		_, err = processor.Process(newEvent("1", entities.Write, `{}`))
+		require.ErrorIs(t, err, entities.ErrDiscardEvent)
+
+		time.Sleep(60 * time.Millisecond)
+		_, err = processor.Process(newEvent("1", entities.Write, `{}`))
+		require.NoError(t, err)
+	})
+
+	t.Run("undelivered events are not discarded when delivered again", func(t *testing.T) {
+		processor, err := New(Config{Window: config.Duration(time.Minute)})
+		require.NoError(t, err)
+
+		result, err := processor.Process(newEvent("1", entities.Write, `{}`))
+		require.NoError(t, err)
+		result.Ack(errors.New("sink error"))
+
+		result, err = processor.Process(newEvent("1", entities.Write, `{}`))
+		require.NoError(t, err)
+		result.Ack(nil)
+
+		_, err = processor.Process(newEvent("1", entities.Write, `{}`))
+		require.ErrorIs(t, err, entities.ErrDiscardEvent)
+	})
+
+	t.Run("the ack of the event is still invoked", func(t *testing.T) {
+		processor, err := New(Config{Window: config.Duration(time.Minute)})
+		require.NoError(t, err)
+
+		var acks []error
+		event := newEvent("1", entities.Write, `{}`)
+		event.WithAck(func(err error) { acks = append(acks, err) })
+
+		result, err := processor.Process(event)
+		require.NoError(t, err)
+		result.Ack(errors.New("sink error"))
+		require.Len(t, acks, 1)
+		require.EqualError(t, acks[0], "sink error")
+
+		_, err = processor.Process(newEvent("1", entities.Write, `{}`))
+		require.NoError(t, err, "the undelivered event is forgotten")
+	})
+}
+
+type failingStore struct {
+	err error
+}
+
+func (s failingStore) seen(context.Context, string, string) (bool, error) { return false, s.err }
+func (s failingStore) forget(context.Context, string, string) error       { return s.err }
+func (s failingStore) close(context.Context) error                        { return nil }
+
+func TestDedupeStoreFailure(t *testing.T) {
+	d := &Dedupe{store: failingStore{err: errors.New("server selection timeout")}}
+
+	_, err := d.Process(&entities.Event{
+		PrimaryKeys:   entities.PkFields{{Key: "id", Value: "1"}},
+		OperationType: entities.Write,
+		OriginalRaw:   []byte(`{}`),
+	})
+	require.ErrorIs(t, err, entities.ErrRetryable, "the event is delivered again once the store is back")
+	require.EqualError(t, err, "retryable: error reading dedupe store: server selection timeout")
+}
+
+func TestEntityKey(t *testing.T) {
+	require.Equal(t,
+		entityKey(entities.PkFields{{Key: "id", Value: "1"}}),
+		entityKey(entities.PkFields{{Key: "id", Value: "1"}}),
+	)
+	require.NotEqual(t,
+		entityKey(entities.PkFields{{Key: "a", Value: "bc"}}),
+		entityKey(entities.PkFields{{Key: "ab", Value: "c"}}),
+	)
+}
@@ -0,0 +1,148 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package dedupe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/internal/cache"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	mongoTimeout = 5 * time.Second
+
+	ErrMongoInitialization = errors.New("failed to start dedupe mongo store")
+)
+
+// store remembers the hash of the last event of each entity for the dedupe window.
+type store interface {
+	// seen records hash as the last one of key, and returns true if it was already the last one.
+	seen(ctx context.Context, key, hash string) (bool, error)
+	// forget removes hash, if it is still the last one of key.
+	forget(ctx context.Context, key, hash string) error
+	close(ctx context.Context) error
+}
+
+type memoryStore struct {
+	mtx     sync.Mutex
+	entries *cache.LRU[string]
+}
+
+func newMemoryStore(maxEntries int, window time.Duration) *memoryStore {
+	return &memoryStore{entries: cache.NewLRU[string](maxEntries, window)}
+}
+
+func (s *memoryStore) seen(_ context.Context, key, hash string) (bool, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if last, ok := s.entries.Get(key); ok && last == hash {
+		return true, nil
+	}
+	s.entries.Add(key, hash)
+	return false, nil
+}
+
+func (s *memoryStore) forget(_ context.Context, key, hash string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if last, ok := s.entries.Get(key); ok && last == hash {
+		s.entries.Remove(key)
+	}
+	return nil
+}
+
+func (s *memoryStore) close(context.Context) error {
+	return nil
+}
+
+// mongoStore keeps a document for each entity, with the hash of its last event and when it
+// expires. Expired documents are removed by a TTL index.
+type mongoStore struct {
+	client     *mongo.Client
+	collection *mongo.Collection
+	window     time.Duration
+	now        func() time.Time
+}
+
+func newMongoStore(ctx context.Context, cfg *MongoConfig, window time.Duration) (*mongoStore, error) {
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.URL.String()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMongoInitialization, err)
+	}
+
+	s := &mongoStore{
+		client:     client,
+		collection: client.Database(cfg.database()).Collection(cfg.Collection),
+		window:     window,
+		now:        time.Now,
+	}
+	if err := s.init(ctx); err != nil {
+		_ = client.Disconnect(ctx)
+		return nil, fmt.Errorf("%w: %w", ErrMongoInitialization, err)
+	}
+	return s, nil
+}
+
+func (s *mongoStore) init(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, mongoTimeout)
+	defer cancel()
+
+	if err := s.client.Ping(ctx, nil); err != nil {
+		return err
+	}
+	_, err := s.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
+
+// seen updates the document of key unless it has the same hash and is not expired: in that
+// case, the upsert fails with a duplicate key error, since the document exists but does not
+// match the filter. This makes the check atomic across the replicas.
+func (s *mongoStore) seen(ctx context.Context, key, hash string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, mongoTimeout)
+	defer cancel()
+
+	now := s.now()
+	filter := bson.D{
+		{Key: "_id", Value: key},
+		{Key: "$or", Value: bson.A{
+			bson.D{{Key: "hash", Value: bson.D{{Key: "$ne", Value: hash}}}},
+			bson.D{{Key: "expiresAt", Value: bson.D{{Key: "$lte", Value: now}}}},
+		}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "hash", Value: hash},
+		{Key: "expiresAt", Value: now.Add(s.window)},
+	}}}
+
+	_, err := s.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return true, nil
+	}
+	return false, err
+}
+
+func (s *mongoStore) forget(ctx context.Context, key, hash string) error {
+	ctx, cancel := context.WithTimeout(ctx, mongoTimeout)
+	defer cancel()
+
+	_, err := s.collection.DeleteOne(ctx, bson.D{{Key: "_id", Value: key}, {Key: "hash", Value: hash}})
+	return err
+}
+
+func (s *mongoStore) close(ctx context.Context) error {
+	return s.client.Disconnect(ctx)
+}
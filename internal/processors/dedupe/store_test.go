@@ -0,0 +1,44 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package dedupe
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestMongoStore(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("seen", func(mt *mtest.T) {
+		s := &mongoStore{client: mt.Client, collection: mt.Coll, window: time.Minute, now: time.Now}
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "upserted", Value: bson.A{bson.D{{Key: "_id", Value: "key"}}}}))
+		seen, err := s.seen(mt.Context(), "key", "hash")
+		require.NoError(t, err)
+		require.False(t, seen)
+
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 11000, Message: "duplicate key error"}))
+		seen, err = s.seen(mt.Context(), "key", "hash")
+		require.NoError(t, err)
+		require.True(t, seen)
+
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Code: 2, Message: "bad value"}))
+		_, err = s.seen(mt.Context(), "key", "hash")
+		var commandErr mongo.CommandError
+		require.ErrorAs(t, err, &commandErr)
+	})
+
+	mt.Run("forget", func(mt *mtest.T) {
+		s := &mongoStore{client: mt.Client, collection: mt.Coll, window: time.Minute, now: time.Now}
+
+		mt.AddMockResponses(mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}))
+		require.NoError(t, s.forget(mt.Context(), "key", "hash"))
+	})
+}
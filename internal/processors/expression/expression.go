@@ -0,0 +1,70 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package expression
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// NewEnv returns the CEL environment shared by the processors: an expression can read the
+// payload of the event as `data` and its type as `eventType`, and use the string extensions
+// (e.g. `lowerAscii`, `split`, `join`).
+func NewEnv() (*cel.Env, error) {
+	return cel.NewEnv(
+		cel.Variable("eventType", cel.StringType),
+		cel.Variable("data", cel.MapType(cel.StringType, cel.AnyType)),
+		ext.Strings(),
+	)
+}
+
+// Compile returns the program evaluating expression in the processors environment.
+func Compile(expression string) (cel.Program, error) {
+	env, err := NewEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	ast, iss := env.Compile(expression)
+	if iss.Err() != nil {
+		return nil, iss.Err()
+	}
+
+	return env.Program(ast, cel.EvalOptions(cel.OptOptimize))
+}
+
+// Variables returns the variables to evaluate an expression against the event.
+func Variables(event entities.PipelineEvent) (map[string]any, error) {
+	data, err := event.JSON()
+	if err != nil {
+		return nil, err
+	}
+	return VariablesFromData(data, event.GetType()), nil
+}
+
+// VariablesFromData returns the variables to evaluate an expression against an event
+// already decoded.
+func VariablesFromData(data map[string]any, eventType string) map[string]any {
+	return map[string]any{
+		"data":      data,
+		"eventType": eventType,
+	}
+}
+
+// ToNative converts the result of an expression to a value that can be encoded in JSON:
+// lists and maps are converted recursively, and numbers become float64.
+func ToNative(value ref.Val) (any, error) {
+	native, err := value.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return nil, fmt.Errorf("unsupported expression result type %s: %w", value.Type().TypeName(), err)
+	}
+	return native.(*structpb.Value).AsInterface(), nil
+}
@@ -0,0 +1,67 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package expression
+
+import (
+	"testing"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	event := &entities.Event{
+		Type:        "issue_created",
+		OriginalRaw: []byte(`{"issue": {"key": "PRJ-1", "labels": ["a", "b"], "points": 3}}`),
+	}
+
+	testCases := map[string]struct {
+		expression string
+
+		expected any
+	}{
+		"string": {
+			expression: "data.issue.key.lowerAscii()",
+			expected:   "prj-1",
+		},
+		"boolean": {
+			expression: "eventType == 'issue_created'",
+			expected:   true,
+		},
+		"number": {
+			expression: "data.issue.points * 2.0",
+			expected:   float64(6),
+		},
+		"list": {
+			expression: "data.issue.labels.map(l, l.upperAscii())",
+			expected:   []any{"A", "B"},
+		},
+		"map": {
+			expression: "{'key': data.issue.key, 'size': size(data.issue.labels)}",
+			expected:   map[string]any{"key": "PRJ-1", "size": float64(2)},
+		},
+		"null": {
+			expression: "null",
+			expected:   nil,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			program, err := Compile(tc.expression)
+			require.NoError(t, err)
+
+			variables, err := Variables(event)
+			require.NoError(t, err)
+
+			result, _, err := program.Eval(variables)
+			require.NoError(t, err)
+
+			value, err := ToNative(result)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, value)
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 
 	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/processors/expression"
 	"github.com/mia-platform/integration-connector-agent/internal/utils"
 
 	"github.com/google/cel-go/cel"
@@ -20,16 +21,11 @@ type Filter struct {
 }
 
 func (m Filter) Process(input entities.PipelineEvent) (entities.PipelineEvent, error) {
-	data, err := input.JSON()
+	evalContext, err := expression.Variables(input)
 	if err != nil {
 		return nil, err
 	}
 
-	evalContext := map[string]any{
-		"data":      data,
-		"eventType": input.GetType(),
-	}
-
 	logrus.WithFields(logrus.Fields{
 		"eventType":   input.GetType(),
 		"primaryKeys": input.GetPrimaryKeys().Map(),
@@ -82,20 +78,7 @@ func (m Filter) Process(input entities.PipelineEvent) (entities.PipelineEvent, e
 }
 
 func New(cfg Config) (*Filter, error) {
-	env, err := cel.NewEnv(
-		cel.Variable("eventType", cel.StringType),
-		cel.Variable("data", cel.MapType(cel.StringType, cel.AnyType)),
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	ast, iss := env.Compile(cfg.CELExpression)
-	if iss.Err() != nil {
-		return nil, iss.Err()
-	}
-
-	prg, err := env.Program(ast, cel.EvalOptions(cel.OptOptimize))
+	prg, err := expression.Compile(cfg.CELExpression)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,97 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package httpenrich
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+)
+
+var (
+	ErrURLNotSet       = errors.New("url is required")
+	ErrInvalidAuth     = errors.New("invalid auth")
+	ErrInvalidCacheTTL = errors.New("cache ttl must be greater than zero")
+)
+
+const (
+	AuthBearer = "bearer"
+	AuthBasic  = "basic"
+
+	defaultTimeout         = 10 * time.Second
+	defaultCacheMaxEntries = 1000
+)
+
+type Config struct {
+	// URL and the values of Headers are mapper templates, interpolated with the event.
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Auth    *Auth             `json:"auth,omitempty"`
+
+	// ResponsePath is the gjson path of the part of the response to add to the event.
+	ResponsePath string `json:"responsePath,omitempty"`
+	// Target is the path where the response is set in the event. If empty, the fields of the
+	// response are merged with the ones of the event.
+	Target string `json:"target,omitempty"`
+	// IgnoreNotFound keeps the event unchanged when the response is 404 Not Found.
+	IgnoreNotFound bool `json:"ignoreNotFound,omitempty"`
+
+	Timeout config.Duration `json:"timeout,omitempty"`
+	Cache   *Cache          `json:"cache,omitempty"`
+}
+
+type Auth struct {
+	Type     string              `json:"type"`
+	Token    config.SecretSource `json:"token,omitempty"`
+	Username string              `json:"username,omitempty"`
+	Password config.SecretSource `json:"password,omitempty"`
+}
+
+// Cache keeps the responses of the requests with the same URL and headers for TTL.
+type Cache struct {
+	TTL        config.Duration `json:"ttl"`
+	MaxEntries int             `json:"maxEntries,omitempty"`
+}
+
+func (c Config) Validate() error {
+	if c.URL == "" {
+		return ErrURLNotSet
+	}
+	if c.Cache != nil && c.Cache.TTL <= 0 {
+		return ErrInvalidCacheTTL
+	}
+	if c.Auth == nil {
+		return nil
+	}
+
+	switch c.Auth.Type {
+	case AuthBearer:
+		if c.Auth.Token == "" {
+			return fmt.Errorf("%w: token is required with %s auth", ErrInvalidAuth, AuthBearer)
+		}
+	case AuthBasic:
+		if c.Auth.Username == "" {
+			return fmt.Errorf("%w: username is required with %s auth", ErrInvalidAuth, AuthBasic)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported type %s", ErrInvalidAuth, c.Auth.Type)
+	}
+	return nil
+}
+
+func (c Config) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return defaultTimeout
+	}
+	return c.Timeout.Duration()
+}
+
+func (c Cache) maxEntries() int {
+	if c.MaxEntries <= 0 {
+		return defaultCacheMaxEntries
+	}
+	return c.MaxEntries
+}
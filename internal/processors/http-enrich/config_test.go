@@ -0,0 +1,92 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package httpenrich
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("unmarshal json", func(t *testing.T) {
+		t.Setenv("JIRA_TOKEN", "my-token")
+
+		cfg := Config{}
+		err := json.Unmarshal([]byte(`{
+			"url": "https://jira/rest/api/2/issue/{{ issue.key }}",
+			"headers": {"X-Tenant": "{{ tenant }}"},
+			"auth": {"type": "bearer", "token": {"fromEnv": "JIRA_TOKEN"}},
+			"responsePath": "fields",
+			"target": "issue.fields",
+			"ignoreNotFound": true,
+			"timeout": "5s",
+			"cache": {"ttl": "1m", "maxEntries": 10}
+		}`), &cfg)
+		require.NoError(t, err)
+		require.Equal(t, Config{
+			URL:            "https://jira/rest/api/2/issue/{{ issue.key }}",
+			Headers:        map[string]string{"X-Tenant": "{{ tenant }}"},
+			Auth:           &Auth{Type: AuthBearer, Token: "my-token"},
+			ResponsePath:   "fields",
+			Target:         "issue.fields",
+			IgnoreNotFound: true,
+			Timeout:        config.Duration(5 * time.Second),
+			Cache:          &Cache{TTL: config.Duration(time.Minute), MaxEntries: 10},
+		}, cfg)
+		require.Equal(t, 5*time.Second, cfg.timeout())
+		require.Equal(t, 10, cfg.Cache.maxEntries())
+	})
+
+	t.Run("defaults", func(t *testing.T) {
+		cfg := Config{URL: "http://jira", Cache: &Cache{TTL: config.Duration(time.Minute)}}
+		require.Equal(t, defaultTimeout, cfg.timeout())
+		require.Equal(t, defaultCacheMaxEntries, cfg.Cache.maxEntries())
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		testCases := map[string]struct {
+			cfg           Config
+			expectedError string
+		}{
+			"valid": {
+				cfg: Config{URL: "http://jira", Auth: &Auth{Type: AuthBasic, Username: "user", Password: "pwd"}},
+			},
+			"without url": {
+				expectedError: "url is required",
+			},
+			"bearer auth without token": {
+				cfg:           Config{URL: "http://jira", Auth: &Auth{Type: AuthBearer}},
+				expectedError: "invalid auth: token is required with bearer auth",
+			},
+			"basic auth without username": {
+				cfg:           Config{URL: "http://jira", Auth: &Auth{Type: AuthBasic}},
+				expectedError: "invalid auth: username is required with basic auth",
+			},
+			"unsupported auth": {
+				cfg:           Config{URL: "http://jira", Auth: &Auth{Type: "oauth"}},
+				expectedError: "invalid auth: unsupported type oauth",
+			},
+			"cache without ttl": {
+				cfg:           Config{URL: "http://jira", Cache: &Cache{}},
+				expectedError: "cache ttl must be greater than zero",
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				err := tc.cfg.Validate()
+				if tc.expectedError != "" {
+					require.EqualError(t, err, tc.expectedError)
+					return
+				}
+				require.NoError(t, err)
+			})
+		}
+	})
+}
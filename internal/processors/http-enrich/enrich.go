@@ -0,0 +1,233 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package httpenrich
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/cache"
+	"github.com/mia-platform/integration-connector-agent/internal/processors/mapper"
+	"github.com/mia-platform/integration-connector-agent/internal/urltemplate"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+var (
+	ErrRequest           = errors.New("error requesting the enrichment")
+	ErrUnexpectedStatus  = errors.New("unexpected response status")
+	ErrInvalidResponse   = errors.New("invalid response")
+	ErrMergeNotSupported = errors.New("only objects can be merged")
+)
+
+const (
+	urlKeyPrefix    = "url"
+	headerKeyPrefix = "header"
+)
+
+// Enrich adds to each event the JSON response of an HTTP GET request, built from the event.
+type Enrich struct {
+	cfg         Config
+	client      *http.Client
+	url         *urltemplate.Template
+	headerNames []string
+	// request is the mapper building the values of the URL placeholders and of the headers.
+	request *mapper.Mapper
+	cache   *cache.LRU[[]byte]
+}
+
+func (e *Enrich) Process(event entities.PipelineEvent) (entities.PipelineEvent, error) {
+	request, err := e.request.Map(event)
+	if err != nil {
+		return nil, err
+	}
+
+	url := e.url.Build(request)
+	headers := make(http.Header, len(e.headerNames))
+	for i, name := range e.headerNames {
+		headers.Set(name, gjson.GetBytes(request, fmt.Sprintf("%s%d", headerKeyPrefix, i)).String())
+	}
+
+	logger := logrus.WithFields(logrus.Fields{
+		"eventType":   event.GetType(),
+		"primaryKeys": event.GetPrimaryKeys().Map(),
+		"url":         url,
+	})
+
+	response, found, err := e.fetch(url, headers, logger)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		logger.Debug("enrichment not found, event not changed")
+		return event, nil
+	}
+
+	data, err := e.addResponse(event.Data(), response)
+	if err != nil {
+		return nil, err
+	}
+	event.WithData(data)
+	return event, nil
+}
+
+// fetch returns the part of the response to add to the event, from the cache if present.
+// It returns false if the response is 404 Not Found and IgnoreNotFound is set.
+func (e *Enrich) fetch(url string, headers http.Header, logger *logrus.Entry) ([]byte, bool, error) {
+	key := cacheKey(url, headers)
+	if e.cache != nil {
+		if response, ok := e.cache.Get(key); ok {
+			logger.Trace("enrichment found in cache")
+			return response, true, nil
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.cfg.timeout())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %w", ErrRequest, err)
+	}
+	req.Header = headers
+	req.Header.Set("Accept", "application/json")
+	e.setAuth(req)
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		// the service is not reachable, or it did not respond in time
+		return nil, false, fmt.Errorf("%w: %w: %w", entities.ErrRetryable, ErrRequest, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound && e.cfg.IgnoreNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		err := fmt.Errorf("%w: %d from %s", ErrUnexpectedStatus, resp.StatusCode, url)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError {
+			return nil, false, fmt.Errorf("%w: %w", entities.ErrRetryable, err)
+		}
+		return nil, false, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("%w: %w: %w", entities.ErrRetryable, ErrRequest, err)
+	}
+	if !gjson.ValidBytes(body) {
+		return nil, false, fmt.Errorf("%w: response is not a JSON", ErrInvalidResponse)
+	}
+
+	response := body
+	if e.cfg.ResponsePath != "" {
+		response = []byte(gjson.GetBytes(body, e.cfg.ResponsePath).Raw)
+		if len(response) == 0 {
+			response = []byte("null")
+		}
+	}
+
+	if e.cache != nil {
+		e.cache.Add(key, response)
+	}
+	return response, true, nil
+}
+
+func (e *Enrich) setAuth(req *http.Request) {
+	if e.cfg.Auth == nil {
+		return
+	}
+
+	switch e.cfg.Auth.Type {
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+e.cfg.Auth.Token.String())
+	case AuthBasic:
+		req.SetBasicAuth(e.cfg.Auth.Username, e.cfg.Auth.Password.String())
+	}
+}
+
+// addResponse sets the response at the target path of data or, without a target, merges the
+// fields of the response with the ones of data, replacing the fields with the same name.
+func (e *Enrich) addResponse(data, response []byte) ([]byte, error) {
+	if e.cfg.Target != "" {
+		out, err := sjson.SetRawBytes(data, e.cfg.Target, response)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+		}
+		return out, nil
+	}
+
+	if !gjson.ParseBytes(response).IsObject() {
+		return nil, fmt.Errorf("%w: response is not an object", ErrMergeNotSupported)
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMergeNotSupported, err)
+	}
+	responseFields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(response, &responseFields); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMergeNotSupported, err)
+	}
+	for key, value := range responseFields {
+		fields[key] = value
+	}
+	return json.Marshal(fields)
+}
+
+func cacheKey(url string, headers http.Header) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	var key strings.Builder
+	key.WriteString(url)
+	for _, name := range names {
+		key.WriteString("\n" + name + ": " + strings.Join(headers.Values(name), ","))
+	}
+	return key.String()
+}
+
+func New(cfg Config) (*Enrich, error) {
+	headerNames := make([]string, 0, len(cfg.Headers))
+	for name := range cfg.Headers {
+		headerNames = append(headerNames, name)
+	}
+	slices.Sort(headerNames)
+
+	url, templates := urltemplate.Parse(cfg.URL, urlKeyPrefix)
+	for i, name := range headerNames {
+		templates[fmt.Sprintf("%s%d", headerKeyPrefix, i)] = cfg.Headers[name]
+	}
+	outputEvent, err := json.Marshal(templates)
+	if err != nil {
+		return nil, err
+	}
+	request, err := mapper.New(mapper.Config{OutputEvent: outputEvent})
+	if err != nil {
+		return nil, err
+	}
+
+	enrich := &Enrich{
+		cfg:         cfg,
+		client:      &http.Client{},
+		url:         url,
+		headerNames: headerNames,
+		request:     request,
+	}
+	if cfg.Cache != nil {
+		enrich.cache = cache.NewLRU[[]byte](cfg.Cache.maxEntries(), cfg.Cache.TTL.Duration())
+	}
+	return enrich, nil
+}
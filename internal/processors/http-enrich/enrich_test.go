@@ -0,0 +1,203 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package httpenrich
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnrich(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if strings.HasPrefix(r.URL.Path, "/search/") {
+			w.Write([]byte(`{"path": "` + r.URL.EscapedPath() + `", "query": "` + r.URL.Query().Get("q") + `"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/issue/PRJ-1":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"key": "PRJ-1", "fields": {"summary": "the summary", "status": "done"}}`))
+		case "/auth":
+			if r.Header.Get("Authorization") != "Bearer my-token" || r.Header.Get("X-Tenant") != "acme" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"authorized": true}`))
+		case "/basic":
+			if username, password, ok := r.BasicAuth(); !ok || username != "user" || password != "pwd" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"authorized": true}`))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]byte(`{}`))
+		case "/invalid":
+			w.Write([]byte(`not a json`))
+		case "/array":
+			w.Write([]byte(`[1, 2]`))
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/limited":
+			w.WriteHeader(http.StatusTooManyRequests)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	testCases := map[string]struct {
+		config Config
+		data   string
+
+		expectedData        string
+		expectedResultError string
+		retryable           bool
+	}{
+		"merge the response": {
+			config: Config{URL: server.URL + "/issue/{{ issue.key }}"},
+			data:   `{"issue": {"key": "PRJ-1"}, "key": "thin"}`,
+
+			expectedData: `{"issue": {"key": "PRJ-1"}, "key": "PRJ-1", "fields": {"summary": "the summary", "status": "done"}}`,
+		},
+		"nest the response": {
+			config: Config{URL: server.URL + "/issue/{{ issue.key }}", Target: "issue.details"},
+			data:   `{"issue": {"key": "PRJ-1"}}`,
+
+			expectedData: `{"issue": {"key": "PRJ-1", "details": {"key": "PRJ-1", "fields": {"summary": "the summary", "status": "done"}}}}`,
+		},
+		"part of the response": {
+			config: Config{URL: server.URL + "/issue/{{ issue.key }}", ResponsePath: "fields.status", Target: "status"},
+			data:   `{"issue": {"key": "PRJ-1"}}`,
+
+			expectedData: `{"issue": {"key": "PRJ-1"}, "status": "done"}`,
+		},
+		"headers and bearer auth": {
+			config: Config{
+				URL:     server.URL + "/auth",
+				Headers: map[string]string{"X-Tenant": "{{ tenant | lower }}"},
+				Auth:    &Auth{Type: AuthBearer, Token: "my-token"},
+			},
+			data: `{"tenant": "ACME"}`,
+
+			expectedData: `{"tenant": "ACME", "authorized": true}`,
+		},
+		"basic auth": {
+			config: Config{URL: server.URL + "/basic", Auth: &Auth{Type: AuthBasic, Username: "user", Password: "pwd"}},
+			data:   `{}`,
+
+			expectedData: `{"authorized": true}`,
+		},
+		"not found is ignored": {
+			config: Config{URL: server.URL + "/issue/{{ issue.key }}", IgnoreNotFound: true},
+			data:   `{"issue": {"key": "PRJ-2"}}`,
+
+			expectedData: `{"issue": {"key": "PRJ-2"}}`,
+		},
+		"fails on not found": {
+			config: Config{URL: server.URL + "/issue/{{ issue.key }}"},
+			data:   `{"issue": {"key": "PRJ-2"}}`,
+
+			expectedResultError: "unexpected response status: 404 from " + server.URL + "/issue/PRJ-2",
+		},
+		"fails on timeout": {
+			config: Config{URL: server.URL + "/slow", Timeout: config.Duration(10 * time.Millisecond)},
+			data:   `{}`,
+
+			expectedResultError: `retryable: error requesting the enrichment: Get "` + server.URL + `/slow": context deadline exceeded`,
+			retryable:           true,
+		},
+		"fails on unavailable service": {
+			config: Config{URL: server.URL + "/unavailable"},
+			data:   `{}`,
+
+			expectedResultError: "retryable: unexpected response status: 503 from " + server.URL + "/unavailable",
+			retryable:           true,
+		},
+		"fails on too many requests": {
+			config: Config{URL: server.URL + "/limited"},
+			data:   `{}`,
+
+			expectedResultError: "retryable: unexpected response status: 429 from " + server.URL + "/limited",
+			retryable:           true,
+		},
+		"placeholders are escaped": {
+			config: Config{URL: server.URL + "/search/{{ path }}?q={{ query }}", Target: "request"},
+			data:   `{"path": "a/b c", "query": "x&y=z"}`,
+
+			expectedData: `{"path": "a/b c", "query": "x&y=z", "request": {"path": "/search/a%2Fb%20c", "query": "x&y=z"}}`,
+		},
+		"placeholder at the start sets the base url": {
+			config: Config{URL: "{{ baseUrl }}/search/{{ path }}", ResponsePath: "path", Target: "path"},
+			data:   `{"baseUrl": "` + server.URL + `", "path": "a/b"}`,
+
+			expectedData: `{"baseUrl": "` + server.URL + `", "path": "/search/a%2Fb"}`,
+		},
+		"fails on invalid response": {
+			config: Config{URL: server.URL + "/invalid"},
+			data:   `{}`,
+
+			expectedResultError: "invalid response: response is not a JSON",
+		},
+		"fails to merge an array": {
+			config: Config{URL: server.URL + "/array"},
+			data:   `{}`,
+
+			expectedResultError: "only objects can be merged: response is not an object",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			processor, err := New(tc.config)
+			require.NoError(t, err)
+
+			result, err := processor.Process(&entities.Event{OriginalRaw: []byte(tc.data)})
+			if tc.expectedResultError != "" {
+				require.EqualError(t, err, tc.expectedResultError)
+				require.Equal(t, tc.retryable, errors.Is(err, entities.ErrRetryable))
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expectedData, string(result.Data()))
+		})
+	}
+
+	t.Run("responses are cached", func(t *testing.T) {
+		processor, err := New(Config{
+			URL:          server.URL + "/issue/{{ issue.key }}",
+			ResponsePath: "fields.summary",
+			Target:       "summary",
+			Cache:        &Cache{TTL: config.Duration(time.Minute)},
+		})
+		require.NoError(t, err)
+
+		requests.Store(0)
+		for range 3 {
+			result, err := processor.Process(&entities.Event{OriginalRaw: []byte(`{"issue": {"key": "PRJ-1"}}`)})
+			require.NoError(t, err)
+			require.JSONEq(t, `{"issue": {"key": "PRJ-1"}, "summary": "the summary"}`, string(result.Data()))
+		}
+		require.Equal(t, int32(1), requests.Load())
+
+		_, err = processor.Process(&entities.Event{OriginalRaw: []byte(`{"issue": {"key": "PRJ-2"}}`)})
+		require.Error(t, err)
+		_, err = processor.Process(&entities.Event{OriginalRaw: []byte(`{"issue": {"key": "PRJ-2"}}`)})
+		require.Error(t, err)
+		require.Equal(t, int32(3), requests.Load(), "errors are not cached")
+	})
+}
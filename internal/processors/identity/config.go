@@ -0,0 +1,42 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package identity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrEmptyConfig     = errors.New("at least one of primaryKeys, writeWhen or deleteWhen is required")
+	ErrPrimaryKeyField = errors.New("primary key requires key and value")
+)
+
+type Config struct {
+	PrimaryKeys []PrimaryKey `json:"primaryKeys,omitempty"`
+	// WriteWhen and DeleteWhen are CEL conditions setting the operation of the event to
+	// Write or Delete when true.
+	WriteWhen  string `json:"writeWhen,omitempty"`
+	DeleteWhen string `json:"deleteWhen,omitempty"`
+}
+
+// PrimaryKey is a primary key of the event, whose value is a mapper template, as
+// "{{ tenantId }}-{{ projectId }}", or a CEL expression, as {"$cel": "string(data.id)"}.
+type PrimaryKey struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (c Config) Validate() error {
+	if len(c.PrimaryKeys) == 0 && c.WriteWhen == "" && c.DeleteWhen == "" {
+		return ErrEmptyConfig
+	}
+	for i, primaryKey := range c.PrimaryKeys {
+		if primaryKey.Key == "" || len(primaryKey.Value) == 0 {
+			return fmt.Errorf("%w: primary key %d", ErrPrimaryKeyField, i)
+		}
+	}
+	return nil
+}
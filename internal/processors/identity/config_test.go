@@ -0,0 +1,63 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package identity
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("unmarshal json", func(t *testing.T) {
+		cfg := Config{}
+		err := json.Unmarshal([]byte(`{
+			"primaryKeys": [{"key": "id", "value": "{{ tenantId }}-{{ projectId }}"}, {"key": "tenant", "value": {"$cel": "data.tenantId"}}],
+			"writeWhen": "eventType == 'project_soft_deleted'",
+			"deleteWhen": "data.deleted"
+		}`), &cfg)
+		require.NoError(t, err)
+		require.Equal(t, Config{
+			PrimaryKeys: []PrimaryKey{
+				{Key: "id", Value: []byte(`"{{ tenantId }}-{{ projectId }}"`)},
+				{Key: "tenant", Value: []byte(`{"$cel": "data.tenantId"}`)},
+			},
+			WriteWhen:  "eventType == 'project_soft_deleted'",
+			DeleteWhen: "data.deleted",
+		}, cfg)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		testCases := map[string]struct {
+			cfg           Config
+			expectedError string
+		}{
+			"primary keys only": {
+				cfg: Config{PrimaryKeys: []PrimaryKey{{Key: "id", Value: []byte(`"{{ id }}"`)}}},
+			},
+			"condition only": {
+				cfg: Config{DeleteWhen: "true"},
+			},
+			"empty": {
+				expectedError: "at least one of primaryKeys, writeWhen or deleteWhen is required",
+			},
+			"primary key without value": {
+				cfg:           Config{PrimaryKeys: []PrimaryKey{{Key: "id", Value: []byte(`"{{ id }}"`)}, {Key: "type"}}},
+				expectedError: "primary key requires key and value: primary key 1",
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				err := tc.cfg.Validate()
+				if tc.expectedError != "" {
+					require.EqualError(t, err, tc.expectedError)
+					return
+				}
+				require.NoError(t, err)
+			})
+		}
+	})
+}
@@ -0,0 +1,162 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package identity
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/processors/expression"
+	"github.com/mia-platform/integration-connector-agent/internal/processors/mapper"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+var (
+	ErrEmptyPrimaryKey = errors.New("primary key value is empty")
+)
+
+// valueKey is the key of the output of the mapper building the value of a primary key.
+const valueKey = "value"
+
+// Identity sets the primary keys and the operation of the events, that are otherwise fixed
+// by the source.
+type Identity struct {
+	primaryKeys []primaryKey
+	writeWhen   condition
+	deleteWhen  condition
+}
+
+type primaryKey struct {
+	key    string
+	mapper *mapper.Mapper
+}
+
+type condition struct {
+	expression string
+	program    cel.Program
+}
+
+func (c condition) evaluate(variables map[string]any) (bool, error) {
+	out, _, err := c.program.Eval(variables)
+	if err != nil {
+		return false, fmt.Errorf("condition %s evaluation failed: %w", c.expression, err)
+	}
+	return out.Equal(types.True) == types.True, nil
+}
+
+func (i Identity) Process(event entities.PipelineEvent) (entities.PipelineEvent, error) {
+	primaryKeys, err := i.buildPrimaryKeys(event)
+	if err != nil {
+		return nil, err
+	}
+	operation, err := i.operation(event)
+	if err != nil {
+		return nil, err
+	}
+
+	if primaryKeys == nil {
+		primaryKeys = event.GetPrimaryKeys()
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"eventType":          event.GetType(),
+		"primaryKeys":        event.GetPrimaryKeys().Map(),
+		"operation":          event.Operation().String(),
+		"updatedPrimaryKeys": primaryKeys.Map(),
+		"updatedOperation":   operation.String(),
+	}).Debug("event identity updated")
+
+	event.WithPrimaryKeys(primaryKeys)
+	event.WithOperation(operation)
+	return event, nil
+}
+
+func (i Identity) buildPrimaryKeys(event entities.PipelineEvent) (entities.PkFields, error) {
+	if len(i.primaryKeys) == 0 {
+		return nil, nil
+	}
+
+	primaryKeys := make(entities.PkFields, 0, len(i.primaryKeys))
+	for _, primaryKey := range i.primaryKeys {
+		output, err := primaryKey.mapper.Map(event)
+		if err != nil {
+			return nil, err
+		}
+		value := gjson.GetBytes(output, valueKey).String()
+		if value == "" {
+			return nil, fmt.Errorf("%w: %s", ErrEmptyPrimaryKey, primaryKey.key)
+		}
+		primaryKeys = append(primaryKeys, entities.PkField{Key: primaryKey.key, Value: value})
+	}
+	return primaryKeys, nil
+}
+
+// operation returns the operation of the event: Delete if deleteWhen is true, otherwise Write
+// if writeWhen is true, otherwise the operation set by the source.
+func (i Identity) operation(event entities.PipelineEvent) (entities.Operation, error) {
+	if i.deleteWhen.program == nil && i.writeWhen.program == nil {
+		return event.Operation(), nil
+	}
+
+	variables, err := expression.Variables(event)
+	if err != nil {
+		return 0, err
+	}
+	if i.deleteWhen.program != nil {
+		isDelete, err := i.deleteWhen.evaluate(variables)
+		if err != nil || isDelete {
+			return entities.Delete, err
+		}
+	}
+	if i.writeWhen.program != nil {
+		isWrite, err := i.writeWhen.evaluate(variables)
+		if err != nil || isWrite {
+			return entities.Write, err
+		}
+	}
+	return event.Operation(), nil
+}
+
+func New(cfg Config) (*Identity, error) {
+	primaryKeys := make([]primaryKey, 0, len(cfg.PrimaryKeys))
+	for _, cfgPrimaryKey := range cfg.PrimaryKeys {
+		outputEvent := fmt.Appendf(nil, `{%q:%s}`, valueKey, cfgPrimaryKey.Value)
+		m, err := mapper.New(mapper.Config{OutputEvent: outputEvent})
+		if err != nil {
+			return nil, fmt.Errorf("invalid primary key %s: %w", cfgPrimaryKey.Key, err)
+		}
+		primaryKeys = append(primaryKeys, primaryKey{key: cfgPrimaryKey.Key, mapper: m})
+	}
+
+	writeWhen, err := newCondition(cfg.WriteWhen)
+	if err != nil {
+		return nil, err
+	}
+	deleteWhen, err := newCondition(cfg.DeleteWhen)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{
+		primaryKeys: primaryKeys,
+		writeWhen:   writeWhen,
+		deleteWhen:  deleteWhen,
+	}, nil
+}
+
+func newCondition(celExpression string) (condition, error) {
+	if celExpression == "" {
+		return condition{}, nil
+	}
+	program, err := expression.Compile(celExpression)
+	if err != nil {
+		return condition{}, err
+	}
+	return condition{expression: celExpression, program: program}, nil
+}
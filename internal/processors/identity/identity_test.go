@@ -0,0 +1,127 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package identity
+
+import (
+	"testing"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdentity(t *testing.T) {
+	newEvent := func(eventType string, operation entities.Operation, data string) *entities.Event {
+		return &entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "id", Value: "12"}},
+			Type:          eventType,
+			OperationType: operation,
+			OriginalRaw:   []byte(data),
+		}
+	}
+
+	testCases := map[string]struct {
+		config Config
+		event  *entities.Event
+
+		expectedPrimaryKeys entities.PkFields
+		expectedOperation   entities.Operation
+		expectedNewError    string
+		expectedResultError string
+	}{
+		"primary keys from templates": {
+			config: Config{PrimaryKeys: []PrimaryKey{
+				{Key: "id", Value: []byte(`"{{ tenantId }}-{{ projectId }}"`)},
+				{Key: "tenant", Value: []byte(`"{{ tenantId | lower }}"`)},
+			}},
+			event: newEvent("project_created", entities.Write, `{"tenantId": "ACME", "projectId": 12}`),
+
+			expectedPrimaryKeys: entities.PkFields{{Key: "id", Value: "ACME-12"}, {Key: "tenant", Value: "acme"}},
+			expectedOperation:   entities.Write,
+		},
+		"primary key from a number": {
+			config: Config{PrimaryKeys: []PrimaryKey{{Key: "id", Value: []byte(`"{{ project.id }}"`)}}},
+			event:  newEvent("project_created", entities.Write, `{"project": {"id": 42}}`),
+
+			expectedPrimaryKeys: entities.PkFields{{Key: "id", Value: "42"}},
+			expectedOperation:   entities.Write,
+		},
+		"primary key from CEL expression": {
+			config: Config{PrimaryKeys: []PrimaryKey{{Key: "id", Value: []byte(`{"$cel": "eventType.split('_')[0] + ':' + string(data.project.id)"}`)}}},
+			event:  newEvent("project_created", entities.Write, `{"project": {"id": 42}}`),
+
+			expectedPrimaryKeys: entities.PkFields{{Key: "id", Value: "project:42"}},
+			expectedOperation:   entities.Write,
+		},
+		"empty primary key": {
+			config: Config{PrimaryKeys: []PrimaryKey{{Key: "id", Value: []byte(`"{{ missing }}"`)}}},
+			event:  newEvent("project_created", entities.Write, `{"project": {"id": 42}}`),
+
+			expectedResultError: "primary key value is empty: id",
+		},
+		"soft delete is a write": {
+			config: Config{WriteWhen: `eventType == "project_soft_deleted"`},
+			event:  newEvent("project_soft_deleted", entities.Delete, `{"project": {"id": 12}}`),
+
+			expectedPrimaryKeys: entities.PkFields{{Key: "id", Value: "12"}},
+			expectedOperation:   entities.Write,
+		},
+		"delete on condition": {
+			config: Config{DeleteWhen: `has(data.archived) && data.archived`},
+			event:  newEvent("project_updated", entities.Write, `{"archived": true}`),
+
+			expectedPrimaryKeys: entities.PkFields{{Key: "id", Value: "12"}},
+			expectedOperation:   entities.Delete,
+		},
+		"delete condition has precedence": {
+			config: Config{WriteWhen: "true", DeleteWhen: "true"},
+			event:  newEvent("project_updated", entities.Write, `{}`),
+
+			expectedPrimaryKeys: entities.PkFields{{Key: "id", Value: "12"}},
+			expectedOperation:   entities.Delete,
+		},
+		"operation kept when conditions are false": {
+			config: Config{WriteWhen: "false", DeleteWhen: "false"},
+			event:  newEvent("project_deleted", entities.Delete, `{}`),
+
+			expectedPrimaryKeys: entities.PkFields{{Key: "id", Value: "12"}},
+			expectedOperation:   entities.Delete,
+		},
+		"fails if condition evaluation fails": {
+			config: Config{DeleteWhen: "data.archived"},
+			event:  newEvent("project_updated", entities.Write, `{}`),
+
+			expectedResultError: "condition data.archived evaluation failed: no such key: archived",
+		},
+		"fails to create with invalid condition": {
+			config:           Config{WriteWhen: "unknown.key"},
+			expectedNewError: "ERROR: <input>:1:1: undeclared reference to 'unknown' (in container '')\n | unknown.key\n | ^",
+		},
+		"fails to create with invalid primary key": {
+			config:           Config{PrimaryKeys: []PrimaryKey{{Key: "id", Value: []byte(`"{{ id | unknown }}"`)}}},
+			expectedNewError: "invalid primary key id: error creating operation: unknown function: unknown",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			processor, err := New(tc.config)
+			if tc.expectedNewError != "" {
+				require.EqualError(t, err, tc.expectedNewError)
+				return
+			}
+			require.NoError(t, err)
+
+			result, err := processor.Process(tc.event)
+			if tc.expectedResultError != "" {
+				require.EqualError(t, err, tc.expectedResultError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedPrimaryKeys, result.GetPrimaryKeys())
+			require.Equal(t, tc.expectedOperation, result.Operation())
+			require.Equal(t, tc.event.OriginalRaw, result.Data(), "the payload is not changed")
+		})
+	}
+}
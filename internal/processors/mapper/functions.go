@@ -0,0 +1,270 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package mapper
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// epochMillisThreshold is the smallest timestamp parsed as milliseconds instead of seconds
+// (i.e. year 2001 in milliseconds, year 33658 in seconds).
+const epochMillisThreshold = 1e12
+
+// timeLayouts are the layouts of the dates parsed by toRFC3339 when the layout is not set.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.000-0700",
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05",
+	time.DateTime,
+	time.DateOnly,
+	time.RFC1123Z,
+	time.RFC1123,
+}
+
+type functionDefinition struct {
+	minArgs int
+	maxArgs int
+	call    func(value any, args []string) (any, error)
+}
+
+// templateFunctions are the functions that can be applied to the value of a placeholder.
+// Except for default, the functions return null when the value is null or missing.
+var templateFunctions = map[string]functionDefinition{
+	"lower":        {call: stringFunction(strings.ToLower)},
+	"upper":        {call: stringFunction(strings.ToUpper)},
+	"trim":         {call: stringFunction(strings.TrimSpace)},
+	"toString":     {call: stringFunction(func(s string) string { return s })},
+	"default":      {minArgs: 1, maxArgs: 1, call: defaultValue},
+	"sha256":       {call: stringFunction(hashSHA256)},
+	"split":        {minArgs: 1, maxArgs: 1, call: split},
+	"toNumber":     {call: toNumber},
+	"base64encode": {call: stringFunction(func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) })},
+	"base64decode": {call: base64Decode},
+	"toRFC3339":    {maxArgs: 1, call: toRFC3339},
+}
+
+// templateFunction is a function applied to the value of a placeholder, with its arguments.
+type templateFunction struct {
+	name string
+	args []string
+}
+
+func (f templateFunction) call(value any) (any, error) {
+	result, err := templateFunctions[f.name].call(value, f.args)
+	if err != nil {
+		return nil, fmt.Errorf("%w: function %s failed: %w", errTransform, f.name, err)
+	}
+	return result, nil
+}
+
+// parsePlaceholder splits the content of a placeholder in the path of the value and the
+// functions to apply to it, e.g. `issue.fields.created | toRFC3339`.
+func parsePlaceholder(placeholder string) (string, []templateFunction, error) {
+	parts := splitPipeline(placeholder)
+
+	var functions []templateFunction
+	for _, part := range parts[1:] {
+		tokens, err := splitArguments(part)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: invalid function %s: %w", errOperation, part, err)
+		}
+		if len(tokens) == 0 {
+			return "", nil, fmt.Errorf("%w: empty function in placeholder: %s", errOperation, placeholder)
+		}
+
+		name, args := tokens[0], tokens[1:]
+		definition, ok := templateFunctions[name]
+		if !ok {
+			return "", nil, fmt.Errorf("%w: unknown function: %s", errOperation, name)
+		}
+		if len(args) < definition.minArgs || len(args) > definition.maxArgs {
+			return "", nil, fmt.Errorf("%w: function %s has %d arguments", errOperation, name, len(args))
+		}
+		functions = append(functions, templateFunction{name: name, args: args})
+	}
+	return parts[0], functions, nil
+}
+
+// splitPipeline splits the placeholder by the pipes outside quoted arguments. The spaces around
+// the pipe are required, since gjson paths can contain a pipe.
+func splitPipeline(placeholder string) []string {
+	var parts []string
+	var quote rune
+	escaped := false
+	start := 0
+	for i, char := range placeholder {
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && char == '\\':
+			escaped = true
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '"' || char == '\'':
+			quote = char
+		case char == '|' && i > 0 && i+1 < len(placeholder) &&
+			unicode.IsSpace(rune(placeholder[i-1])) && unicode.IsSpace(rune(placeholder[i+1])):
+			parts = append(parts, strings.TrimSpace(placeholder[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(placeholder[start:]))
+}
+
+// splitArguments splits s by spaces, keeping together the arguments quoted with double quotes,
+// that can contain escape sequences, or with single quotes.
+func splitArguments(s string) ([]string, error) {
+	var tokens []string
+	s = strings.TrimSpace(s)
+	for s != "" {
+		switch s[0] {
+		case '"':
+			quoted, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, err
+			}
+			value, err := strconv.Unquote(quoted)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, value)
+			s = s[len(quoted):]
+		case '\'':
+			end := strings.IndexByte(s[1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated quoted argument")
+			}
+			tokens = append(tokens, s[1:end+1])
+			s = s[end+2:]
+		default:
+			end := strings.IndexFunc(s, unicode.IsSpace)
+			if end < 0 {
+				end = len(s)
+			}
+			tokens = append(tokens, s[:end])
+			s = s[end:]
+		}
+		s = strings.TrimSpace(s)
+	}
+	return tokens, nil
+}
+
+// stringValue returns the representation of value in an interpolated template: objects and
+// arrays are written as JSON, and null as an empty string.
+func stringValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(encoded)
+	}
+}
+
+func stringFunction(fn func(string) string) func(value any, args []string) (any, error) {
+	return func(value any, _ []string) (any, error) {
+		if value == nil {
+			return nil, nil
+		}
+		return fn(stringValue(value)), nil
+	}
+}
+
+func hashSHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func defaultValue(value any, args []string) (any, error) {
+	if value == nil || value == "" {
+		return args[0], nil
+	}
+	return value, nil
+}
+
+func split(value any, args []string) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	parts := strings.Split(stringValue(value), args[0])
+	result := make([]any, 0, len(parts))
+	for _, part := range parts {
+		result = append(result, part)
+	}
+	return result, nil
+}
+
+func toNumber(value any, _ []string) (any, error) {
+	switch v := value.(type) {
+	case nil, float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	default:
+		return nil, fmt.Errorf("cannot convert %T to number", value)
+	}
+}
+
+func base64Decode(value any, _ []string) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	encoded := stringValue(value)
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		decoded, err = base64.RawStdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return string(decoded), nil
+}
+
+// toRFC3339 converts a date to RFC 3339 in UTC. The date can be a string, in the layout given as
+// argument or in one of the common ones, or a number of seconds or milliseconds since the epoch.
+func toRFC3339(value any, args []string) (any, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		if v >= epochMillisThreshold {
+			return time.UnixMilli(int64(v)).UTC().Format(time.RFC3339), nil
+		}
+		return time.Unix(int64(v), 0).UTC().Format(time.RFC3339), nil
+	case string:
+		layouts := timeLayouts
+		if len(args) > 0 {
+			layouts = args
+		}
+		for _, layout := range layouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UTC().Format(time.RFC3339), nil
+			}
+		}
+		return nil, fmt.Errorf("unsupported date format: %s", v)
+	default:
+		return nil, fmt.Errorf("cannot convert %T to date", value)
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package mapper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateFunctions(t *testing.T) {
+	testCases := map[string]struct {
+		placeholder string
+		value       any
+
+		expected      any
+		expectedError string
+	}{
+		"lower": {
+			placeholder: "key | lower",
+			value:       "In Progress",
+			expected:    "in progress",
+		},
+		"upper and trim": {
+			placeholder: "key | trim | upper",
+			value:       "  done ",
+			expected:    "DONE",
+		},
+		"string functions keep null": {
+			placeholder: "key | lower",
+			value:       nil,
+			expected:    nil,
+		},
+		"toString of a number": {
+			placeholder: "key | toString",
+			value:       float64(42),
+			expected:    "42",
+		},
+		"default of null": {
+			placeholder: `key | default "n/a"`,
+			value:       nil,
+			expected:    "n/a",
+		},
+		"default of empty string": {
+			placeholder: "key | default 'n/a'",
+			value:       "",
+			expected:    "n/a",
+		},
+		"default of a value": {
+			placeholder: `key | default "n/a"`,
+			value:       false,
+			expected:    false,
+		},
+		"sha256": {
+			placeholder: "key | sha256",
+			value:       "user@example.com",
+			expected:    "b4c9a289323b21a01c3e940f150eb9b8c542587f1abfd8f0e1cc1ffc5e475514",
+		},
+		"split": {
+			placeholder: `key | split ", "`,
+			value:       "a, b, c",
+			expected:    []any{"a", "b", "c"},
+		},
+		"toNumber": {
+			placeholder: "key | toNumber",
+			value:       " 3.14 ",
+			expected:    3.14,
+		},
+		"toNumber of invalid string": {
+			placeholder:   "key | toNumber",
+			value:         "three",
+			expectedError: `error transforming data: function toNumber failed: strconv.ParseFloat: parsing "three": invalid syntax`,
+		},
+		"toNumber of boolean": {
+			placeholder:   "key | toNumber",
+			value:         true,
+			expectedError: "error transforming data: function toNumber failed: cannot convert bool to number",
+		},
+		"base64 encode and decode": {
+			placeholder: "key | base64encode | base64decode",
+			value:       "secret value",
+			expected:    "secret value",
+		},
+		"base64decode without padding": {
+			placeholder: "key | base64decode",
+			value:       "dXNlcg",
+			expected:    "user",
+		},
+		"base64decode of invalid value": {
+			placeholder:   "key | base64decode",
+			value:         "not base64!",
+			expectedError: "error transforming data: function base64decode failed: illegal base64 data at input byte 3",
+		},
+		"toRFC3339 of Jira date": {
+			placeholder: "key | toRFC3339",
+			value:       "2024-11-06T10:15:30.000+0000",
+			expected:    "2024-11-06T10:15:30Z",
+		},
+		"toRFC3339 of date only": {
+			placeholder: "key | toRFC3339",
+			value:       "2024-11-06",
+			expected:    "2024-11-06T00:00:00Z",
+		},
+		"toRFC3339 with layout": {
+			placeholder: `key | toRFC3339 "02/01/2006 15:04"`,
+			value:       "06/11/2024 10:15",
+			expected:    "2024-11-06T10:15:00Z",
+		},
+		"toRFC3339 of seconds": {
+			placeholder: "key | toRFC3339",
+			value:       float64(1730888130),
+			expected:    "2024-11-06T10:15:30Z",
+		},
+		"toRFC3339 of milliseconds": {
+			placeholder: "key | toRFC3339",
+			value:       float64(1730888130000),
+			expected:    "2024-11-06T10:15:30Z",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			key, functions, err := parsePlaceholder(tc.placeholder)
+			require.NoError(t, err)
+			require.Equal(t, "key", key)
+
+			value := tc.value
+			for _, function := range functions {
+				value, err = function.call(value)
+				if err != nil {
+					break
+				}
+			}
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, value)
+		})
+	}
+}
+
+func TestParsePlaceholder(t *testing.T) {
+	testCases := map[string]struct {
+		placeholder string
+
+		expectedKey       string
+		expectedFunctions []templateFunction
+		expectedError     string
+	}{
+		"path only": {
+			placeholder: "issue.fields.created",
+			expectedKey: "issue.fields.created",
+		},
+		"gjson pipe without spaces is part of the path": {
+			placeholder: "issue.fields|@keys",
+			expectedKey: "issue.fields|@keys",
+		},
+		"functions with arguments": {
+			placeholder: `key | default "a \"quoted\" | value" | split ','`,
+			expectedKey: "key",
+			expectedFunctions: []templateFunction{
+				{name: "default", args: []string{`a "quoted" | value`}},
+				{name: "split", args: []string{","}},
+			},
+		},
+		"unterminated argument": {
+			placeholder:   "key | default 'n/a",
+			expectedError: "error creating operation: invalid function default 'n/a: unterminated quoted argument",
+		},
+		"too many arguments": {
+			placeholder:   "key | lower now",
+			expectedError: "error creating operation: function lower has 1 arguments",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			key, functions, err := parsePlaceholder(tc.placeholder)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedKey, key)
+			require.Equal(t, tc.expectedFunctions, functions)
+		})
+	}
+}
@@ -24,6 +24,24 @@ func (m Mapper) Process(event entities.PipelineEvent) (entities.PipelineEvent, e
 		"operationCount": len(m.operations),
 	}).Debug("starting mapper processing")
 
+	output, err := m.Map(event)
+	if err != nil {
+		return nil, err
+	}
+	event.WithData(output)
+
+	logrus.WithFields(logrus.Fields{
+		"eventType":   event.GetType(),
+		"primaryKeys": event.GetPrimaryKeys().Map(),
+		"outputSize":  len(output),
+	}).Debug("mapper processing completed successfully")
+
+	return event, nil
+}
+
+// Map returns the output event built from event, without changing it.
+func (m Mapper) Map(event entities.PipelineEvent) ([]byte, error) {
+	input := &eventInput{raw: event.Data(), eventType: event.GetType()}
 	output := []byte("{}")
 	var err error
 	for i, operation := range m.operations {
@@ -33,7 +51,7 @@ func (m Mapper) Process(event entities.PipelineEvent) (entities.PipelineEvent, e
 			"operationIndex": i,
 		}).Trace("applying mapper operation")
 
-		output, err = operation.apply(event.Data(), output)
+		output, err = operation.apply(input, output)
 		if err != nil {
 			logrus.WithFields(logrus.Fields{
 				"eventType":      event.GetType(),
@@ -43,15 +61,7 @@ func (m Mapper) Process(event entities.PipelineEvent) (entities.PipelineEvent, e
 			return nil, err
 		}
 	}
-	event.WithData(output)
-
-	logrus.WithFields(logrus.Fields{
-		"eventType":   event.GetType(),
-		"primaryKeys": event.GetPrimaryKeys().Map(),
-		"outputSize":  len(output),
-	}).Debug("mapper processing completed successfully")
-
-	return event, nil
+	return output, nil
 }
 
 func New(cfg Config) (*Mapper, error) {
@@ -82,6 +92,26 @@ func generateOperations(jsonData gjson.Result) ([]operation, error) {
 				keyToUpdate = strings.Join([]string{keyPrefix, key.String()}, ".")
 			}
 
+			if celExpression, ok := expressionOf(value); ok {
+				operation, err := newExpressionOperation(keyToUpdate, celExpression)
+				if err != nil {
+					resError = err
+					return false
+				}
+				result = append(result, operation)
+				return true
+			}
+
+			if sourceKey, template, ok := iterationOf(value); ok {
+				operation, err := newIterationOperation(keyToUpdate, sourceKey, template)
+				if err != nil {
+					resError = err
+					return false
+				}
+				result = append(result, operation)
+				return true
+			}
+
 			if value.IsObject() || value.IsArray() {
 				walk(value, keyToUpdate)
 				return true
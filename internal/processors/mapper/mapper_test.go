@@ -92,11 +92,122 @@ func TestMapper(t *testing.T) {
 				"data":  []any{float64(1), float64(2), float64(3)},
 			},
 		},
-		"throws if create operation fails - combined operation": {
+		"combined placeholders": {
 			model: `{
-	"key": "{{combined}}-{{key}}"
+	"id": "{{ key }}-{{ fields.created }}",
+	"title": "[{{ key }}] {{ fields.summary }}"
 }`,
-			expectNewError: "error creating operation: unsupported combine template: {{combined}}-{{key}}",
+			dataToTransform: inputData,
+			expectedTransformedData: map[string]any{
+				"id":    "123-2021-01-01",
+				"title": "[123] this is the summary",
+			},
+		},
+		"CEL expressions": {
+			model: `{
+	"key": "{{ key }}",
+	"summary": {"$cel": "data.fields.summary.upperAscii()"},
+	"isUpdate": {"$cel": "eventType == 'issue_updated'"},
+	"next": {"$cel": "int(data.key) + 1"},
+	"state": {"$cel": "has(data.fields.history) ? 'changed' : 'new'"},
+	"labels": {"$cel": "data.fields.summary.split(' ').filter(w, size(w) > 3)"},
+	"nested": {
+		"description": {"$cel": "data.fields.description"}
+	},
+	"notAnExpression": {"$cel": "data.key", "other": "static"}
+}`,
+			dataToTransform: inputData,
+			expectedTransformedData: map[string]any{
+				"key":      "123",
+				"summary":  "THIS IS THE SUMMARY",
+				"isUpdate": true,
+				"next":     float64(124),
+				"state":    "changed",
+				"labels":   []any{"this", "summary"},
+				"nested": map[string]any{
+					"description": "this is the description",
+				},
+				"notAnExpression": map[string]any{
+					"$cel":  "data.key",
+					"other": "static",
+				},
+			},
+		},
+		"throws if create operation fails - invalid CEL expression": {
+			model: `{
+	"key": {"$cel": "unknown.key"}
+}`,
+			expectNewError: "error creating operation: invalid expression unknown.key: ERROR: <input>:1:1: undeclared reference to 'unknown' (in container '')\n | unknown.key\n | ^",
+		},
+		"throws if CEL expression evaluation fails": {
+			model: `{
+	"key": {"$cel": "data.missing.field"}
+}`,
+			dataToTransform:      inputData,
+			expectTransformError: "error transforming data: expression data.missing.field evaluation failed: no such key: missing",
+		},
+		"array iteration": {
+			model: `{
+	"labels": {
+		"$forEach": "labels",
+		"$template": {
+			"name": "{{ name }}",
+			"color": "#{{ color }}",
+			"isDefault": {"$cel": "data.name == 'bug'"}
+		}
+	},
+	"labelNames": {"$forEach": "labels", "$template": "{{ name }}"},
+	"reviewers": {
+		"$forEach": "reviews",
+		"$template": {
+			"user": "{{ user.login }}",
+			"comments": {"$forEach": "comments", "$template": "{{ @this }}"}
+		}
+	},
+	"missing": {"$forEach": "missing", "$template": "{{ @this }}"},
+	"nested": {
+		"tags": {"$forEach": "tags", "$template": {"key": "{{ key }}", "value": "{{ value }}"}}
+	}
+}`,
+			dataToTransform: `{
+	"labels": [{"name": "bug", "color": "ff0000"}, {"name": "feature", "color": "00ff00"}],
+	"reviews": [{"user": {"login": "alice"}, "comments": ["lgtm", "nit"]}, {"user": {"login": "bob"}}],
+	"tags": [{"key": "env", "value": "prod"}]
+}`,
+			expectedTransformedData: map[string]any{
+				"labels": []any{
+					map[string]any{"name": "bug", "color": "#ff0000", "isDefault": true},
+					map[string]any{"name": "feature", "color": "#00ff00", "isDefault": false},
+				},
+				"labelNames": []any{"bug", "feature"},
+				"reviewers": []any{
+					map[string]any{"user": "alice", "comments": []any{"lgtm", "nit"}},
+					map[string]any{"user": "bob", "comments": []any{}},
+				},
+				"missing": []any{},
+				"nested": map[string]any{
+					"tags": []any{map[string]any{"key": "env", "value": "prod"}},
+				},
+			},
+		},
+		"throws if iterated field is not an array": {
+			model: `{
+	"labels": {"$forEach": "key", "$template": "{{ @this }}"}
+}`,
+			dataToTransform:      inputData,
+			expectTransformError: "error transforming data: key is not an array",
+		},
+		"throws if create operation fails - iteration without template": {
+			model: `{
+	"labels": {"$forEach": "labels"}
+}`,
+			expectNewError: "error creating operation: $template is required with $forEach",
+		},
+		"throws if create operation fails - empty placeholder": {
+			model: `{
+	"key": "{{}}-{{key}}"
+}`,
+			expectNewError: "error creating operation: empty placeholder in template: {{}}-{{key}}",
 		},
 		"all event in a subfield": {
 			model: `{
@@ -135,6 +246,7 @@ func TestMapper(t *testing.T) {
 			require.NoError(t, err)
 
 			event := entities.PipelineEvent(&entities.Event{
+				Type:        "issue_updated",
 				OriginalRaw: []byte(tc.dataToTransform),
 			})
 
@@ -9,6 +9,9 @@ import (
 	"fmt"
 	"regexp"
 
+	"github.com/mia-platform/integration-connector-agent/internal/processors/expression"
+
+	"github.com/google/cel-go/cel"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -24,32 +27,115 @@ type operationType int
 
 const (
 	set operationType = iota
+	evaluate
+	iterate
+)
+
+const (
+	// celKey is the key of the object setting the output field with the result of a CEL expression.
+	celKey = "$cel"
+	// forEachKey and templateKey are the keys of the object mapping each element of an input array
+	// with a template.
+	forEachKey  = "$forEach"
+	templateKey = "$template"
+	// elementKey is the key where the template of an element is applied.
+	elementKey = "element"
 )
 
+// eventInput is the event being mapped. Its payload is decoded only if needed to evaluate
+// a CEL expression, and once for all the operations.
+type eventInput struct {
+	raw       []byte
+	eventType string
+	variables map[string]any
+}
+
+func (i *eventInput) expressionVariables() (map[string]any, error) {
+	if i.variables == nil {
+		data := map[string]any{}
+		if err := json.Unmarshal(i.raw, &data); err != nil {
+			return nil, err
+		}
+		i.variables = expression.VariablesFromData(data, i.eventType)
+	}
+	return i.variables, nil
+}
+
 type operation struct {
 	keyToUpdate   string
 	valueKeys     []string
 	operationType operationType
 	templateValue any
+	// interpolate is set when the template is not a single placeholder: the value to set is the
+	// template string, with each placeholder replaced by the string representation of its value.
+	interpolate bool
+	// program is the CEL expression of the evaluate operations.
+	program cel.Program
+	// elementOperations are the operations applied to each element by the iterate operations.
+	elementOperations []operation
+	// functions are the functions applied to the value of each placeholder, if any.
+	functions [][]templateFunction
 }
 
-func (o operation) getValueToSet(input []byte) any {
+func (o operation) getValueToSet(input []byte) (any, error) {
 	if len(o.valueKeys) == 0 {
-		return o.templateValue
+		return o.templateValue, nil
+	}
+	if o.interpolate {
+		return o.interpolateTemplate(input)
 	}
-	key := o.valueKeys[0]
-	value := gjson.GetBytes(input, key)
-	return value.Value()
+	return o.placeholderValue(input, 0)
 }
 
-func (o operation) apply(input, output []byte) ([]byte, error) {
+// placeholderValue returns the value of the placeholder at index, with its functions applied.
+func (o operation) placeholderValue(input []byte, index int) (any, error) {
+	value := gjson.GetBytes(input, o.valueKeys[index]).Value()
+	if index >= len(o.functions) {
+		return value, nil
+	}
+
+	var err error
+	for _, function := range o.functions[index] {
+		if value, err = function.call(value); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
+
+func (o operation) interpolateTemplate(input []byte) (string, error) {
+	template, _ := o.templateValue.(string)
+	index := 0
+	var err error
+	result := dynamicOperatorRegexp.ReplaceAllStringFunc(template, func(string) string {
+		defer func() { index++ }()
+		if index >= len(o.functions) || len(o.functions[index]) == 0 {
+			// objects and arrays are written as JSON, missing fields and null as empty strings
+			return gjson.GetBytes(input, o.valueKeys[index]).String()
+		}
+
+		value, valueErr := o.placeholderValue(input, index)
+		if valueErr != nil {
+			err = valueErr
+			return ""
+		}
+		return stringValue(value)
+	})
+	return result, err
+}
+
+func (o operation) apply(input *eventInput, output []byte) ([]byte, error) {
 	switch o.operationType {
 	case set:
-		output, err := o.setData(input, output)
+		output, err := o.setData(input.raw, output)
 		if err != nil {
 			return nil, err
 		}
 		return output, nil
+	case evaluate:
+		return o.evaluate(input, output)
+	case iterate:
+		return o.iterate(input, output)
 	default:
 		return nil, fmt.Errorf("operation not supported: %v", o.operationType)
 	}
@@ -64,26 +150,114 @@ func (o operation) setData(input, output []byte) ([]byte, error) {
 		return nil, fmt.Errorf("%w: output key is empty", errTransform)
 	}
 
-	out, err := sjson.SetBytes(output, o.keyToUpdate, o.getValueToSet(input))
+	value, err := o.getValueToSet(input)
 	if err != nil {
+		return nil, err
+	}
+	out, err := sjson.SetBytes(output, o.keyToUpdate, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errTransform, err)
+	}
+
+	return out, nil
+}
+
+func (o operation) evaluate(input *eventInput, output []byte) ([]byte, error) {
+	if o.keyToUpdate == "" {
+		return nil, fmt.Errorf("%w: output key is empty", errTransform)
+	}
+	variables, err := input.expressionVariables()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errTransform, err)
+	}
+
+	result, _, err := o.program.Eval(variables)
+	if err != nil {
+		return nil, fmt.Errorf("%w: expression %s evaluation failed: %w", errTransform, o.templateValue, err)
+	}
+	value, err := expression.ToNative(result)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errTransform, err)
+	}
+
+	out, err := sjson.SetBytes(output, o.keyToUpdate, value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errTransform, err)
+	}
+	return out, nil
+}
+
+func (o operation) iterate(input *eventInput, output []byte) ([]byte, error) {
+	if !gjson.ValidBytes(input.raw) {
+		err := json.Unmarshal(input.raw, &map[string]any{})
 		return nil, fmt.Errorf("%w: %w", errTransform, err)
 	}
+	if o.keyToUpdate == "" {
+		return nil, fmt.Errorf("%w: output key is empty", errTransform)
+	}
+
+	source := gjson.GetBytes(input.raw, o.valueKeys[0])
+	if source.Exists() && source.Type != gjson.Null && !source.IsArray() {
+		return nil, fmt.Errorf("%w: %s is not an array", errTransform, o.valueKeys[0])
+	}
 
+	elements := make([]json.RawMessage, 0)
+	var err error
+	for _, element := range source.Array() {
+		elementInput := &eventInput{raw: []byte(element.Raw), eventType: input.eventType}
+		elementOutput := []byte("{}")
+		for _, operation := range o.elementOperations {
+			elementOutput, err = operation.apply(elementInput, elementOutput)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		value := gjson.GetBytes(elementOutput, elementKey)
+		if !value.Exists() {
+			elements = append(elements, json.RawMessage("null"))
+			continue
+		}
+		elements = append(elements, json.RawMessage(value.Raw))
+	}
+
+	rawElements, err := json.Marshal(elements)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errTransform, err)
+	}
+	out, err := sjson.SetRawBytes(output, o.keyToUpdate, rawElements)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errTransform, err)
+	}
 	return out, nil
 }
 
 // newOperation creates a new operation from the given input key and output value.
 // keyToUpdate are the keys where to retrieve the value from the input JSON.
 // outputValue is the key where to set the value in the output JSON.
+// A template made of a single placeholder keeps the JSON type of the value, while placeholders
+// mixed with text, or more than one, are interpolated in a string.
+// The path of a placeholder can be followed by functions applied to its value, e.g. `{{ key | lower }}`.
 func newOperation(keyToUpdate string, template gjson.Result) (operation, error) {
 	matches := dynamicOperatorRegexp.FindAllStringSubmatch(template.String(), -1)
-	if len(matches) > 1 {
-		return operation{}, fmt.Errorf("%w: unsupported combine template: %s", errOperation, template)
-	}
 
 	var valueKeys []string
-	for _, match := range matches {
-		valueKeys = append(valueKeys, match[1:]...)
+	var functions [][]templateFunction
+	for i, match := range matches {
+		if match[1] == "" {
+			return operation{}, fmt.Errorf("%w: empty placeholder in template: %s", errOperation, template)
+		}
+		key, placeholderFunctions, err := parsePlaceholder(match[1])
+		if err != nil {
+			return operation{}, err
+		}
+		valueKeys = append(valueKeys, key)
+		if len(placeholderFunctions) > 0 {
+			if functions == nil {
+				functions = make([][]templateFunction, len(matches))
+			}
+			functions[i] = placeholderFunctions
+		}
 	}
 
 	return operation{
@@ -91,5 +265,76 @@ func newOperation(keyToUpdate string, template gjson.Result) (operation, error)
 		keyToUpdate:   keyToUpdate,
 		operationType: set,
 		templateValue: template.Value(),
+		interpolate:   len(matches) > 1 || (len(matches) == 1 && matches[0][0] != template.String()),
+		functions:     functions,
 	}, nil
 }
+
+// newExpressionOperation creates an operation setting keyToUpdate with the result of the CEL
+// expression, evaluated with the same variables of the filter processor.
+func newExpressionOperation(keyToUpdate string, celExpression string) (operation, error) {
+	program, err := expression.Compile(celExpression)
+	if err != nil {
+		return operation{}, fmt.Errorf("%w: invalid expression %s: %w", errOperation, celExpression, err)
+	}
+
+	return operation{
+		keyToUpdate:   keyToUpdate,
+		operationType: evaluate,
+		templateValue: celExpression,
+		program:       program,
+	}, nil
+}
+
+// expressionOf returns the CEL expression of value, if it is an object with the only key $cel.
+func expressionOf(value gjson.Result) (string, bool) {
+	if !value.IsObject() {
+		return "", false
+	}
+	fields := value.Map()
+	celExpression, ok := fields[celKey]
+	if !ok || len(fields) != 1 || celExpression.Type != gjson.String {
+		return "", false
+	}
+	return celExpression.String(), true
+}
+
+// newIterationOperation creates an operation setting keyToUpdate with an array, built applying
+// the template to each element of the array found at sourceKey in the input JSON.
+func newIterationOperation(keyToUpdate string, sourceKey string, template gjson.Result) (operation, error) {
+	if sourceKey == "" {
+		return operation{}, fmt.Errorf("%w: %s must be the path of an array", errOperation, forEachKey)
+	}
+	if !template.Exists() {
+		return operation{}, fmt.Errorf("%w: %s is required with %s", errOperation, templateKey, forEachKey)
+	}
+
+	// the template of the element is applied to a key of an object, since it can be any JSON value
+	elementTemplate := gjson.Parse(fmt.Sprintf(`{%q:%s}`, elementKey, template.Raw))
+	elementOperations, err := generateOperations(elementTemplate)
+	if err != nil {
+		return operation{}, err
+	}
+
+	return operation{
+		keyToUpdate:       keyToUpdate,
+		valueKeys:         []string{sourceKey},
+		operationType:     iterate,
+		templateValue:     template.Value(),
+		elementOperations: elementOperations,
+	}, nil
+}
+
+// iterationOf returns the path of the array and the template of its elements, if value is
+// an object with the $forEach key.
+func iterationOf(value gjson.Result) (string, gjson.Result, bool) {
+	if !value.IsObject() {
+		return "", gjson.Result{}, false
+	}
+	fields := value.Map()
+	sourceKey, ok := fields[forEachKey]
+	if !ok {
+		return "", gjson.Result{}, false
+	}
+	return sourceKey.String(), fields[templateKey], true
+}
@@ -82,12 +82,115 @@ func TestNewOperation(t *testing.T) {
 			},
 			expectedOutputJSON: `{"output": ["foo", "bar"]}`,
 		},
-		"error composite fields": {
-			jsonTemplate: "{{key}}-{{field.foo}}",
+		"composite fields": {
+			jsonTemplate: `"{{key}}-{{ field.foo }}"`,
 			keyToUpdate:  "key",
 			inputJSON:    `{"key": "key", "field": {"foo": "bar"}}`,
 
-			expectedOperationError: "error creating operation: unsupported combine template: {{key}}-{{field.foo}}",
+			expectedOperation: operation{
+				valueKeys:     []string{"key", "field.foo"},
+				operationType: set,
+				interpolate:   true,
+			},
+			expectedOutputJSON: `{"key": "key-bar"}`,
+		},
+		"single placeholder with text is interpolated": {
+			jsonTemplate: `"count: {{ count }}"`,
+			keyToUpdate:  "output",
+			inputJSON:    `{"count": 3}`,
+
+			expectedOperation: operation{
+				valueKeys:     []string{"count"},
+				operationType: set,
+				interpolate:   true,
+			},
+			expectedOutputJSON: `{"output": "count: 3"}`,
+		},
+		"interpolated values of any type": {
+			jsonTemplate: `"{{ number }} {{ boolean }} {{ object }} {{ array }} [{{ null }}] [{{ missing }}]"`,
+			keyToUpdate:  "output",
+			inputJSON:    `{"number": 1.5, "boolean": true, "object": {"foo": "bar"}, "array": [1, 2], "null": null}`,
+
+			expectedOperation: operation{
+				valueKeys:     []string{"number", "boolean", "object", "array", "null", "missing"},
+				operationType: set,
+				interpolate:   true,
+			},
+			expectedOutputJSON: `{"output": "1.5 true {\"foo\": \"bar\"} [1, 2] [] []"}`,
+		},
+		"error empty placeholder": {
+			jsonTemplate: `"{{ key }}-{{ }}"`,
+			keyToUpdate:  "key",
+
+			expectedOperationError: "error creating operation: empty placeholder in template: {{ key }}-{{ }}",
+		},
+		"placeholder with functions": {
+			jsonTemplate: `"{{ fields.created | toRFC3339 }}"`,
+			keyToUpdate:  "output",
+			inputJSON:    `{"fields": {"created": "2024-11-06T10:00:00.000+0100"}}`,
+
+			expectedOperation: operation{
+				valueKeys:     []string{"fields.created"},
+				operationType: set,
+				functions:     [][]templateFunction{{{name: "toRFC3339", args: []string{}}}},
+			},
+			expectedOutputJSON: `{"output": "2024-11-06T09:00:00Z"}`,
+		},
+		"placeholder with functions keeps the type of the result": {
+			jsonTemplate: `"{{ labels | lower | split \",\" }}"`,
+			keyToUpdate:  "output",
+			inputJSON:    `{"labels": "Bug,UI"}`,
+
+			expectedOperation: operation{
+				valueKeys:     []string{"labels"},
+				operationType: set,
+				functions: [][]templateFunction{{
+					{name: "lower", args: []string{}},
+					{name: "split", args: []string{","}},
+				}},
+			},
+			expectedOutputJSON: `{"output": ["bug", "ui"]}`,
+		},
+		"interpolated placeholders with functions": {
+			jsonTemplate: `"{{ key }}: {{ priority | default 'n/a' | upper }} {{ estimate | toNumber }}"`,
+			keyToUpdate:  "output",
+			inputJSON:    `{"key": "PRJ-1", "estimate": "2.50"}`,
+
+			expectedOperation: operation{
+				valueKeys:     []string{"key", "priority", "estimate"},
+				operationType: set,
+				interpolate:   true,
+				functions: [][]templateFunction{
+					nil,
+					{{name: "default", args: []string{"n/a"}}, {name: "upper", args: []string{}}},
+					{{name: "toNumber", args: []string{}}},
+				},
+			},
+			expectedOutputJSON: `{"output": "PRJ-1: N/A 2.5"}`,
+		},
+		"error applying function": {
+			jsonTemplate: `"{{ created | toRFC3339 }}"`,
+			keyToUpdate:  "output",
+			inputJSON:    `{"created": "yesterday"}`,
+
+			expectedOperation: operation{
+				valueKeys:     []string{"created"},
+				operationType: set,
+				functions:     [][]templateFunction{{{name: "toRFC3339", args: []string{}}}},
+			},
+			expectedApplyError: "error transforming data: function toRFC3339 failed: unsupported date format: yesterday",
+		},
+		"error unknown function": {
+			jsonTemplate: `"{{ key | unknown }}"`,
+			keyToUpdate:  "output",
+
+			expectedOperationError: "error creating operation: unknown function: unknown",
+		},
+		"error function with wrong number of arguments": {
+			jsonTemplate: `"{{ key | default }}"`,
+			keyToUpdate:  "output",
+
+			expectedOperationError: "error creating operation: function default has 0 arguments",
 		},
 		"set array of numbers": {
 			keyToUpdate:  "output",
@@ -123,7 +226,7 @@ func TestNewOperation(t *testing.T) {
 			require.Equal(t, expectedOperation, actual)
 
 			t.Run("apply", func(t *testing.T) {
-				output, err := actual.apply([]byte(tc.inputJSON), []byte(`{}`))
+				output, err := actual.apply(&eventInput{raw: []byte(tc.inputJSON)}, []byte(`{}`))
 				if tc.expectedApplyError != "" {
 					require.EqualError(t, err, tc.expectedApplyError)
 					return
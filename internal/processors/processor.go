@@ -7,15 +7,22 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/mia-platform/integration-connector-agent/entities"
 	"github.com/mia-platform/integration-connector-agent/internal/config"
 
+	"github.com/mia-platform/integration-connector-agent/internal/processors/aggregate"
 	cloudvendoraggregator "github.com/mia-platform/integration-connector-agent/internal/processors/cloud-vendor-aggregator"
 	cloudvendoraggregatorConfig "github.com/mia-platform/integration-connector-agent/internal/processors/cloud-vendor-aggregator/config"
+	"github.com/mia-platform/integration-connector-agent/internal/processors/dedupe"
 	"github.com/mia-platform/integration-connector-agent/internal/processors/filter"
 	"github.com/mia-platform/integration-connector-agent/internal/processors/hcgp"
+	httpenrich "github.com/mia-platform/integration-connector-agent/internal/processors/http-enrich"
+	"github.com/mia-platform/integration-connector-agent/internal/processors/identity"
 	"github.com/mia-platform/integration-connector-agent/internal/processors/mapper"
+	"github.com/mia-platform/integration-connector-agent/internal/processors/routes"
+	"github.com/mia-platform/integration-connector-agent/internal/processors/split"
 
 	"github.com/sirupsen/logrus"
 )
@@ -29,55 +36,212 @@ const (
 	Filter                = "filter"
 	RPC                   = "rpc-plugin"
 	CloudVendorAggregator = "cloud-vendor-aggregator"
+	Routes                = "routes"
+	Identity              = "identity"
+	HTTPEnrich            = "http-enrich"
+	Dedupe                = "dedupe"
+	Aggregate             = "aggregate"
+	Split                 = "split"
 )
 
+// Observer is notified of the outcome of each processor execution.
+type Observer interface {
+	ObserveProcessor(index int, processorType string, duration time.Duration, err error)
+}
+
 type Processors struct {
 	processors []entities.Processor
+	types      []string
+
+	observer Observer
 }
 
-func (p *Processors) Process(ctx context.Context, message entities.PipelineEvent) (entities.PipelineEvent, error) {
-	for i, processor := range p.processors {
-		processorType := fmt.Sprintf("%T", processor)
-		logger := logrus.WithFields(logrus.Fields{
-			"processorIndex": i,
-			"processorType":  processorType,
-			"eventType":      message.GetType(),
-			"primaryKeys":    message.GetPrimaryKeys().Map(),
-		})
-
-		logger.Debug("starting processor execution")
-
-		var err error
-		message, err = processor.Process(message)
-		if err != nil {
-			if errors.Is(err, entities.ErrDiscardEvent) {
-				// Event filtered out - not an error, just pass it through
-				logger.WithError(err).Debug("event filtered by processor")
+// SetObserver sets the observer notified of each processor execution.
+func (p *Processors) SetObserver(observer Observer) {
+	p.observer = observer
+}
+
+// Process runs the processors on the message, and returns the resulting events. The processors
+// implementing entities.MultiProcessor can return zero or more events, each one elaborated by
+// the next processors: the events discarded are dropped, and the discard error is returned only
+// if all of them are discarded. No events and no error are returned when the message has been
+// buffered by a processor, to be returned later by Flush.
+//
+// The ack of the message is passed on to the events created from it, and the discarded events
+// are acknowledged, so that the message is acknowledged once all the events created from it
+// have been handled, even if buffered.
+func (p *Processors) Process(_ context.Context, message entities.PipelineEvent) ([]entities.PipelineEvent, error) {
+	return p.processFrom(0, []entities.PipelineEvent{message})
+}
+
+// processFrom runs the processors starting from the one at index start on the events.
+func (p *Processors) processFrom(start int, events []entities.PipelineEvent) ([]entities.PipelineEvent, error) {
+	for i := start; i < len(p.processors); i++ {
+		var processed []entities.PipelineEvent
+		var discardErr error
+		for _, event := range events {
+			outputs, err := p.run(i, event)
+			if err != nil {
+				if errors.Is(err, entities.ErrDiscardEvent) {
+					// the discarded events are done with
+					event.Ack(nil)
+					discardErr = err
+					continue
+				}
 				return nil, err
 			}
-			logger.WithError(err).Error("processor execution failed")
+			passAck(event, outputs)
+			processed = append(processed, outputs...)
+		}
+
+		if len(processed) == 0 {
+			return nil, discardErr
+		}
+		events = processed
+	}
+
+	return events, nil
+}
+
+// passAck sets the ack of the input on the events created by a processor, split among them, so
+// that the input is acknowledged once all of them are. The input returned by the processor, and
+// the events it set the ack of, keep their own.
+func passAck(input entities.PipelineEvent, outputs []entities.PipelineEvent) {
+	ack := input.GetAck()
+	if ack == nil {
+		return
+	}
+
+	var created []entities.PipelineEvent
+	for _, output := range outputs {
+		if output == input {
+			return
+		}
+		if output.GetAck() == nil {
+			created = append(created, output)
+		}
+	}
+	if len(created) == 0 {
+		return
+	}
+	for i, splitAck := range entities.SplitAck(ack, len(created)) {
+		created[i].WithAck(splitAck)
+	}
+}
+
+// run executes the processor at index on the event.
+func (p *Processors) run(index int, event entities.PipelineEvent) ([]entities.PipelineEvent, error) {
+	processor := p.processors[index]
+	logger := logrus.WithFields(logrus.Fields{
+		"processorIndex": index,
+		"processorType":  fmt.Sprintf("%T", processor),
+		"eventType":      event.GetType(),
+		"primaryKeys":    event.GetPrimaryKeys().Map(),
+	})
+
+	logger.Debug("starting processor execution")
+
+	var outputs []entities.PipelineEvent
+	var err error
+	start := time.Now()
+	if multiProcessor, ok := processor.(entities.MultiProcessor); ok {
+		outputs, err = multiProcessor.ProcessEvents(event)
+	} else {
+		var output entities.PipelineEvent
+		if output, err = processor.Process(event); err == nil {
+			outputs = []entities.PipelineEvent{output}
+		}
+	}
+	p.observe(index, time.Since(start), err)
+	if err != nil {
+		if errors.Is(err, entities.ErrDiscardEvent) {
+			// Event filtered out - not an error, just pass it through
+			logger.WithError(err).Debug("event filtered by processor")
 			return nil, err
 		}
+		logger.WithError(err).Error("processor execution failed")
+		return nil, err
+	}
+
+	logger.WithField("outputEvents", len(outputs)).Debug("processor execution completed successfully")
+	return outputs, nil
+}
+
+// Flush returns the events buffered by the processors implementing entities.FlushingProcessor
+// that are ready at now, or all of them when final is set, elaborated by the next processors.
+// The events failing to be flushed or elaborated are reported in the returned error, while the
+// other ones are returned anyway.
+func (p *Processors) Flush(now time.Time, final bool) ([]entities.PipelineEvent, error) {
+	var flushed []entities.PipelineEvent
+	var flushErrors []error
+	for i, processor := range p.processors {
+		flushingProcessor, ok := processor.(entities.FlushingProcessor)
+		if !ok {
+			continue
+		}
 
-		logger.Debug("processor execution completed successfully")
+		events, err := flushingProcessor.Flush(now, final)
+		if err != nil {
+			flushErrors = append(flushErrors, fmt.Errorf("error flushing processor %d (%T): %w", i, processor, err))
+		}
+		// each event is elaborated on its own, so that a failure does not drop the other ones
+		for _, event := range events {
+			outputs, err := p.processFrom(i+1, []entities.PipelineEvent{event})
+			if err != nil {
+				if !errors.Is(err, entities.ErrDiscardEvent) {
+					// the events aggregated in the failed one are not acknowledged
+					event.Ack(err)
+					flushErrors = append(flushErrors, err)
+				}
+				continue
+			}
+			flushed = append(flushed, outputs...)
+		}
 	}
+	return flushed, errors.Join(flushErrors...)
+}
 
-	return message, nil
+// FlushInterval returns how often Flush has to be called, that is the shortest interval of the
+// flushing processors, or zero if there are none.
+func (p *Processors) FlushInterval() time.Duration {
+	var interval time.Duration
+	for _, processor := range p.processors {
+		if flushingProcessor, ok := processor.(entities.FlushingProcessor); ok {
+			if processorInterval := flushingProcessor.FlushInterval(); interval == 0 || processorInterval < interval {
+				interval = processorInterval
+			}
+		}
+	}
+	return interval
+}
+
+func (p *Processors) observe(index int, duration time.Duration, err error) {
+	if p.observer == nil {
+		return
+	}
+
+	processorType := fmt.Sprintf("%T", p.processors[index])
+	if index < len(p.types) {
+		processorType = p.types[index]
+	}
+	p.observer.ObserveProcessor(index, processorType, duration, err)
 }
 
 type CloseableProcessor interface {
 	Close() error
 }
 
+// Close closes all the processors, also when some of them fail, and returns the join of their errors.
 func (p *Processors) Close() error {
+	var errs []error
 	for _, processor := range p.processors {
 		if closer, ok := processor.(CloseableProcessor); ok {
 			if err := closer.Close(); err != nil {
-				return fmt.Errorf("error closing processor %T: %w", processor, err)
+				errs = append(errs, fmt.Errorf("error closing processor %T: %w", processor, err))
 			}
 		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 func New(logger *logrus.Logger, cfg config.Processors) (*Processors, error) {
@@ -106,6 +270,66 @@ func New(logger *logrus.Logger, cfg config.Processors) (*Processors, error) {
 				return nil, err
 			}
 			p.processors = append(p.processors, f)
+		case Routes:
+			config, err := config.GetConfig[routes.Config](processor)
+			if err != nil {
+				return nil, err
+			}
+			r, err := routes.New(config)
+			if err != nil {
+				return nil, err
+			}
+			p.processors = append(p.processors, r)
+		case Identity:
+			config, err := config.GetConfig[identity.Config](processor)
+			if err != nil {
+				return nil, err
+			}
+			i, err := identity.New(config)
+			if err != nil {
+				return nil, err
+			}
+			p.processors = append(p.processors, i)
+		case HTTPEnrich:
+			config, err := config.GetConfig[httpenrich.Config](processor)
+			if err != nil {
+				return nil, err
+			}
+			e, err := httpenrich.New(config)
+			if err != nil {
+				return nil, err
+			}
+			p.processors = append(p.processors, e)
+		case Dedupe:
+			config, err := config.GetConfig[dedupe.Config](processor)
+			if err != nil {
+				return nil, err
+			}
+			d, err := dedupe.New(config)
+			if err != nil {
+				return nil, err
+			}
+			p.processors = append(p.processors, d)
+		case Aggregate:
+			config, err := config.GetConfig[aggregate.Config](processor)
+			if err != nil {
+				return nil, err
+			}
+			a, err := aggregate.New(config)
+			if err != nil {
+				return nil, err
+			}
+			p.processors = append(p.processors, a)
+		case Split:
+			config, err := config.GetConfig[split.Config](processor)
+			if err != nil {
+				return nil, err
+			}
+			s, err := split.New(config)
+			if err != nil {
+				return nil, err
+			}
+			p.processors = append(p.processors, s)
 		case RPC:
 			config, err := config.GetConfig[hcgp.Config](processor)
 			if err != nil {
@@ -133,6 +357,7 @@ func New(logger *logrus.Logger, cfg config.Processors) (*Processors, error) {
 		default:
 			return nil, ErrProcessorNotSupported
 		}
+		p.types = append(p.types, processor.Type)
 	}
 
 	return p, nil
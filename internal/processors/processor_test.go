@@ -6,7 +6,9 @@ package processors
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mia-platform/integration-connector-agent/entities"
 	"github.com/mia-platform/integration-connector-agent/internal/config"
@@ -80,12 +82,225 @@ func TestProcessors_Process(t *testing.T) {
 				require.EqualError(t, err, tt.expectedErr)
 			} else {
 				require.NoError(t, err)
-				require.Equal(t, tt.expected.Data(), got.Data())
+				require.Len(t, got, 1)
+				require.Equal(t, tt.expected.Data(), got[0].Data())
 			}
 		})
 	}
 }
 
+type mockMultiProcessor struct {
+	mockProcessor
+	processEventsFunc func(data entities.PipelineEvent) ([]entities.PipelineEvent, error)
+	flushFunc         func(now time.Time, final bool) ([]entities.PipelineEvent, error)
+	interval          time.Duration
+}
+
+func (m *mockMultiProcessor) ProcessEvents(data entities.PipelineEvent) ([]entities.PipelineEvent, error) {
+	return m.processEventsFunc(data)
+}
+
+func (m *mockMultiProcessor) Flush(now time.Time, final bool) ([]entities.PipelineEvent, error) {
+	return m.flushFunc(now, final)
+}
+
+func (m *mockMultiProcessor) FlushInterval() time.Duration {
+	return m.interval
+}
+
+func TestProcessors_ProcessEvents(t *testing.T) {
+	split := &mockMultiProcessor{
+		processEventsFunc: func(event entities.PipelineEvent) ([]entities.PipelineEvent, error) {
+			var events []entities.PipelineEvent
+			for _, part := range strings.Split(string(event.Data()), ",") {
+				events = append(events, &entities.Event{OriginalRaw: []byte(part)})
+			}
+			return events, nil
+		},
+	}
+	discardSkipped := &mockProcessor{
+		processFunc: func(event entities.PipelineEvent) (entities.PipelineEvent, error) {
+			if string(event.Data()) == "skip" {
+				return nil, entities.ErrDiscardEvent
+			}
+			event.WithData(append(event.Data(), []byte(" processed")...))
+			return event, nil
+		},
+	}
+
+	tests := map[string]struct {
+		processors []entities.Processor
+		input      string
+
+		expected    []string
+		expectedErr string
+	}{
+		"each event is processed by the next processors": {
+			processors: []entities.Processor{split, discardSkipped},
+			input:      "a,skip,b",
+			expected:   []string{"a processed", "b processed"},
+		},
+		"all the events discarded": {
+			processors:  []entities.Processor{split, discardSkipped},
+			input:       "skip,skip",
+			expectedErr: entities.ErrDiscardEvent.Error(),
+		},
+		"event buffered": {
+			processors: []entities.Processor{
+				&mockMultiProcessor{
+					processEventsFunc: func(_ entities.PipelineEvent) ([]entities.PipelineEvent, error) {
+						return nil, nil
+					},
+				},
+				&mockProcessor{
+					processFunc: func(_ entities.PipelineEvent) (entities.PipelineEvent, error) {
+						panic("the event should be buffered")
+					},
+				},
+			},
+			input: "a",
+		},
+		"processor error": {
+			processors: []entities.Processor{
+				split,
+				&mockProcessor{
+					processFunc: func(_ entities.PipelineEvent) (entities.PipelineEvent, error) {
+						return nil, errors.New("processing error")
+					},
+				},
+			},
+			input:       "a,b",
+			expectedErr: "processing error",
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := &Processors{processors: tt.processors}
+			got, err := p.Process(t.Context(), &entities.Event{OriginalRaw: []byte(tt.input)})
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var data []string
+			for _, event := range got {
+				data = append(data, string(event.Data()))
+			}
+			require.Equal(t, tt.expected, data)
+		})
+	}
+}
+
+func TestProcessors_Acks(t *testing.T) {
+	split := &mockMultiProcessor{
+		processEventsFunc: func(event entities.PipelineEvent) ([]entities.PipelineEvent, error) {
+			var events []entities.PipelineEvent
+			for _, part := range strings.Split(string(event.Data()), ",") {
+				events = append(events, &entities.Event{OriginalRaw: []byte(part)})
+			}
+			return events, nil
+		},
+	}
+	discardSkipped := &mockProcessor{
+		processFunc: func(event entities.PipelineEvent) (entities.PipelineEvent, error) {
+			if string(event.Data()) == "skip" {
+				return nil, entities.ErrDiscardEvent
+			}
+			return event, nil
+		},
+	}
+
+	p := &Processors{processors: []entities.Processor{split, discardSkipped}}
+	message := &entities.Event{OriginalRaw: []byte("a,skip,b")}
+	var acks []error
+	message.WithAck(func(err error) { acks = append(acks, err) })
+
+	got, err := p.Process(t.Context(), message)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Empty(t, acks, "the message is acknowledged once all the events created from it are")
+
+	got[0].Ack(nil)
+	require.Empty(t, acks)
+	got[1].Ack(errors.New("sink error"))
+	require.Len(t, acks, 1)
+	require.EqualError(t, acks[0], "sink error")
+}
+
+type mockCloseableProcessor struct {
+	mockProcessor
+	closeErr error
+	closed   bool
+}
+
+func (m *mockCloseableProcessor) Close() error {
+	m.closed = true
+	return m.closeErr
+}
+
+func TestProcessors_Close(t *testing.T) {
+	first := &mockCloseableProcessor{closeErr: errors.New("first error")}
+	second := &mockCloseableProcessor{}
+	third := &mockCloseableProcessor{closeErr: errors.New("third error")}
+	p := &Processors{processors: []entities.Processor{first, second, third}}
+
+	err := p.Close()
+	require.EqualError(t, err, "error closing processor *processors.mockCloseableProcessor: first error\n"+
+		"error closing processor *processors.mockCloseableProcessor: third error")
+	require.True(t, first.closed)
+	require.True(t, second.closed, "the processors are closed also after a failure")
+	require.True(t, third.closed)
+}
+
+func TestProcessors_Flush(t *testing.T) {
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	var flushes []bool
+	buffering := &mockMultiProcessor{
+		interval: time.Minute,
+		flushFunc: func(flushTime time.Time, final bool) ([]entities.PipelineEvent, error) {
+			require.Equal(t, now, flushTime)
+			flushes = append(flushes, final)
+			return []entities.PipelineEvent{
+				&entities.Event{OriginalRaw: []byte("a")},
+				&entities.Event{OriginalRaw: []byte("skip")},
+			}, nil
+		},
+	}
+	p := &Processors{processors: []entities.Processor{
+		buffering,
+		&mockProcessor{
+			processFunc: func(event entities.PipelineEvent) (entities.PipelineEvent, error) {
+				if string(event.Data()) == "skip" {
+					return nil, entities.ErrDiscardEvent
+				}
+				event.WithData(append(event.Data(), []byte(" processed")...))
+				return event, nil
+			},
+		},
+		&mockMultiProcessor{
+			interval: 10 * time.Second,
+			processEventsFunc: func(event entities.PipelineEvent) ([]entities.PipelineEvent, error) {
+				return []entities.PipelineEvent{event}, nil
+			},
+			flushFunc: func(_ time.Time, _ bool) ([]entities.PipelineEvent, error) {
+				return []entities.PipelineEvent{&entities.Event{OriginalRaw: []byte("b")}}, errors.New("flush error")
+			},
+		},
+	}}
+
+	require.Equal(t, 10*time.Second, p.FlushInterval())
+	require.Zero(t, (&Processors{}).FlushInterval())
+
+	events, err := p.Flush(now, true)
+	require.EqualError(t, err, "error flushing processor 2 (*processors.mockMultiProcessor): flush error")
+	require.Equal(t, []bool{true}, flushes)
+	require.Len(t, events, 2)
+	require.Equal(t, "a processed", string(events[0].Data()))
+	require.Equal(t, "b", string(events[1].Data()))
+}
+
 func TestNew(t *testing.T) {
 	tests := map[string]struct {
 		cfg config.Processors
@@ -121,6 +336,72 @@ func TestNew(t *testing.T) {
 			},
 			expectedErr: "ERROR: <input>:1:1: undeclared reference to 'foo' (in container '')\n | foo\n | ^",
 		},
+		"routes processor": {
+			cfg: config.Processors{
+				{Type: Routes, Raw: []byte(`{"type":"routes","routes":[{"eventType":"issue","outputEvent":{"key":"{{ key }}"}}]}`)},
+			},
+		},
+		"routes processor - wrong config": {
+			cfg: config.Processors{
+				{Type: Routes, Raw: []byte(`{"type":"routes","routes":[]}`)},
+			},
+			expectedErr: "configuration not valid: at least one route is required",
+		},
+		"identity processor": {
+			cfg: config.Processors{
+				{Type: Identity, Raw: []byte(`{"type":"identity","primaryKeys":[{"key":"id","value":"{{ tenantId }}-{{ projectId }}"}]}`)},
+			},
+		},
+		"identity processor - wrong config": {
+			cfg: config.Processors{
+				{Type: Identity, Raw: []byte(`{"type":"identity"}`)},
+			},
+			expectedErr: "configuration not valid: at least one of primaryKeys, writeWhen or deleteWhen is required",
+		},
+		"http-enrich processor": {
+			cfg: config.Processors{
+				{Type: HTTPEnrich, Raw: []byte(`{"type":"http-enrich","url":"http://jira/rest/api/2/issue/{{ issue.key }}","target":"issue"}`)},
+			},
+		},
+		"http-enrich processor - wrong config": {
+			cfg: config.Processors{
+				{Type: HTTPEnrich, Raw: []byte(`{"type":"http-enrich","url":"http://jira","auth":{"type":"bearer"}}`)},
+			},
+			expectedErr: "configuration not valid: invalid auth: token is required with bearer auth",
+		},
+		"dedupe processor": {
+			cfg: config.Processors{
+				{Type: Dedupe, Raw: []byte(`{"type":"dedupe","window":"10m","fields":["issue.fields"]}`)},
+			},
+		},
+		"dedupe processor - wrong config": {
+			cfg: config.Processors{
+				{Type: Dedupe, Raw: []byte(`{"type":"dedupe"}`)},
+			},
+			expectedErr: "configuration not valid: window must be greater than zero",
+		},
+		"aggregate processor": {
+			cfg: config.Processors{
+				{Type: Aggregate, Raw: []byte(`{"type":"aggregate","window":"5m","groupBy":[{"key":"repository","path":"repository.full_name"}]}`)},
+			},
+		},
+		"aggregate processor - wrong config": {
+			cfg: config.Processors{
+				{Type: Aggregate, Raw: []byte(`{"type":"aggregate","window":"5m","mode":"sum"}`)},
+			},
+			expectedErr: "configuration not valid: unsupported mode: sum",
+		},
+		"split processor": {
+			cfg: config.Processors{
+				{Type: Split, Raw: []byte(`{"type":"split","path":"assets","primaryKeys":[{"key":"name","value":"{{ name }}"}]}`)},
+			},
+		},
+		"split processor - wrong config": {
+			cfg: config.Processors{
+				{Type: Split, Raw: []byte(`{"type":"split"}`)},
+			},
+			expectedErr: "configuration not valid: path is required",
+		},
 	}
 
 	for name, tt := range tests {
@@ -0,0 +1,41 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNoRoutes       = errors.New("at least one route is required")
+	ErrRouteCondition = errors.New("route requires eventType or condition")
+)
+
+type Config struct {
+	Routes  []Route `json:"routes"`
+	Default *Route  `json:"default,omitempty"`
+}
+
+// Route maps the events matching both its eventType and its CEL condition, when set, with
+// outputEvent, the same template of the mapper processor. Without outputEvent, the matching
+// events are passed unchanged.
+type Route struct {
+	EventType   string          `json:"eventType,omitempty"`
+	Condition   string          `json:"condition,omitempty"`
+	OutputEvent json.RawMessage `json:"outputEvent,omitempty"`
+}
+
+func (c Config) Validate() error {
+	if len(c.Routes) == 0 {
+		return ErrNoRoutes
+	}
+	for i, route := range c.Routes {
+		if route.EventType == "" && route.Condition == "" {
+			return fmt.Errorf("%w: route %d", ErrRouteCondition, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package routes
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("unmarshal json", func(t *testing.T) {
+		cfg := Config{}
+		err := json.Unmarshal([]byte(`{
+			"routes": [{"eventType": "issue", "condition": "data.key != ''", "outputEvent": {"key": "{{ key }}"}}],
+			"default": {"outputEvent": {"raw": "{{ @this }}"}}
+		}`), &cfg)
+		require.NoError(t, err)
+		require.Equal(t, Config{
+			Routes: []Route{
+				{EventType: "issue", Condition: "data.key != ''", OutputEvent: []byte(`{"key": "{{ key }}"}`)},
+			},
+			Default: &Route{OutputEvent: []byte(`{"raw": "{{ @this }}"}`)},
+		}, cfg)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		testCases := map[string]struct {
+			cfg           Config
+			expectedError string
+		}{
+			"valid": {
+				cfg: Config{Routes: []Route{{EventType: "issue"}, {Condition: "true"}}},
+			},
+			"without routes": {
+				cfg:           Config{Default: &Route{}},
+				expectedError: "at least one route is required",
+			},
+			"route without eventType and condition": {
+				cfg:           Config{Routes: []Route{{EventType: "issue"}, {OutputEvent: []byte(`{}`)}}},
+				expectedError: "route requires eventType or condition: route 1",
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				err := tc.cfg.Validate()
+				if tc.expectedError != "" {
+					require.EqualError(t, err, tc.expectedError)
+					return
+				}
+				require.NoError(t, err)
+			})
+		}
+	})
+}
@@ -0,0 +1,154 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package routes
+
+import (
+	"fmt"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/processors/expression"
+	"github.com/mia-platform/integration-connector-agent/internal/processors/mapper"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Routes applies to each event the first route matching it, or the default route if none
+// matches. Events not matching any route are discarded when the default route is not set.
+type Routes struct {
+	routes       []route
+	defaultRoute *route
+}
+
+type route struct {
+	eventType string
+	condition string
+	program   cel.Program
+	mapper    *mapper.Mapper
+}
+
+func (r route) matches(event entities.PipelineEvent, variables func() (map[string]any, error)) (bool, error) {
+	if r.eventType != "" && r.eventType != event.GetType() {
+		return false, nil
+	}
+	if r.program == nil {
+		return true, nil
+	}
+
+	evalContext, err := variables()
+	if err != nil {
+		return false, err
+	}
+	out, _, err := r.program.Eval(evalContext)
+	if err != nil {
+		return false, fmt.Errorf("route condition %s evaluation failed: %w", r.condition, err)
+	}
+	return out.Equal(types.True) == types.True, nil
+}
+
+func (r route) apply(event entities.PipelineEvent) (entities.PipelineEvent, error) {
+	if r.mapper == nil {
+		return event, nil
+	}
+	return r.mapper.Process(event)
+}
+
+func (r Routes) Process(event entities.PipelineEvent) (entities.PipelineEvent, error) {
+	// the variables of the conditions are decoded once, and only if needed
+	var evalContext map[string]any
+	variables := func() (map[string]any, error) {
+		if evalContext != nil {
+			return evalContext, nil
+		}
+		var err error
+		evalContext, err = expression.Variables(event)
+		return evalContext, err
+	}
+
+	for i, route := range r.routes {
+		matches, err := route.matches(event, variables)
+		if err != nil {
+			return nil, err
+		}
+		if matches {
+			logrus.WithFields(logrus.Fields{
+				"eventType":   event.GetType(),
+				"primaryKeys": event.GetPrimaryKeys().Map(),
+				"routeIndex":  i,
+			}).Debug("event matched route")
+			return route.apply(event)
+		}
+	}
+
+	if r.defaultRoute == nil {
+		logrus.WithFields(logrus.Fields{
+			"eventType":   event.GetType(),
+			"primaryKeys": event.GetPrimaryKeys().Map(),
+			"reason":      "no_route_matched",
+		}).Debug("event skipped by routes")
+		return nil, entities.ErrDiscardEvent
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"eventType":   event.GetType(),
+		"primaryKeys": event.GetPrimaryKeys().Map(),
+	}).Debug("event matched default route")
+	return r.defaultRoute.apply(event)
+}
+
+func New(cfg Config) (*Routes, error) {
+	routes := make([]route, 0, len(cfg.Routes))
+	for _, cfgRoute := range cfg.Routes {
+		route, err := newRoute(cfgRoute)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+
+	var defaultRoute *route
+	if cfg.Default != nil {
+		// the default route matches any event
+		route, err := newRoute(Route{OutputEvent: cfg.Default.OutputEvent})
+		if err != nil {
+			return nil, err
+		}
+		defaultRoute = &route
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"routesLen":    len(routes),
+		"defaultRoute": defaultRoute != nil,
+	}).Debug("routes processor initialized")
+
+	return &Routes{
+		routes:       routes,
+		defaultRoute: defaultRoute,
+	}, nil
+}
+
+func newRoute(cfg Route) (route, error) {
+	r := route{
+		eventType: cfg.EventType,
+		condition: cfg.Condition,
+	}
+
+	if cfg.Condition != "" {
+		program, err := expression.Compile(cfg.Condition)
+		if err != nil {
+			return route{}, err
+		}
+		r.program = program
+	}
+
+	if len(cfg.OutputEvent) > 0 {
+		m, err := mapper.New(mapper.Config{OutputEvent: cfg.OutputEvent})
+		if err != nil {
+			return route{}, err
+		}
+		r.mapper = m
+	}
+	return r, nil
+}
@@ -0,0 +1,143 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package routes
+
+import (
+	"testing"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutes(t *testing.T) {
+	issueRoute := Route{
+		EventType:   "issue_updated",
+		OutputEvent: []byte(`{"kind": "issue", "key": "{{ issue.key }}"}`),
+	}
+	archivedProjectRoute := Route{
+		Condition:   `eventType.startsWith("project_") && has(data.project.archived) && data.project.archived`,
+		OutputEvent: []byte(`{"kind": "archived-project", "key": "{{ project.key }}"}`),
+	}
+	projectRoute := Route{
+		Condition:   `eventType.startsWith("project_")`,
+		OutputEvent: []byte(`{"kind": "project", "key": "{{ project.key }}"}`),
+	}
+
+	testCases := map[string]struct {
+		config Config
+		event  *entities.Event
+
+		expectedData        string
+		expectedNewError    string
+		expectedResultError string
+	}{
+		"route by event type": {
+			config: Config{Routes: []Route{issueRoute, projectRoute}},
+			event: &entities.Event{
+				Type:        "issue_updated",
+				OriginalRaw: []byte(`{"issue": {"key": "PRJ-1"}}`),
+			},
+			expectedData: `{"kind":"issue","key":"PRJ-1"}`,
+		},
+		"first matching route is applied": {
+			config: Config{Routes: []Route{issueRoute, archivedProjectRoute, projectRoute}},
+			event: &entities.Event{
+				Type:        "project_updated",
+				OriginalRaw: []byte(`{"project": {"key": "PRJ", "archived": true}}`),
+			},
+			expectedData: `{"kind":"archived-project","key":"PRJ"}`,
+		},
+		"route by condition": {
+			config: Config{Routes: []Route{issueRoute, archivedProjectRoute, projectRoute}},
+			event: &entities.Event{
+				Type:        "project_created",
+				OriginalRaw: []byte(`{"project": {"key": "PRJ"}}`),
+			},
+			expectedData: `{"kind":"project","key":"PRJ"}`,
+		},
+		"route with event type and condition": {
+			config: Config{Routes: []Route{{
+				EventType:   "issue_updated",
+				Condition:   `data.issue.key.startsWith("OPS-")`,
+				OutputEvent: []byte(`{"ops": true}`),
+			}}},
+			event: &entities.Event{
+				Type:        "issue_updated",
+				OriginalRaw: []byte(`{"issue": {"key": "PRJ-1"}}`),
+			},
+			expectedResultError: entities.ErrDiscardEvent.Error(),
+		},
+		"route without output event passes the event unchanged": {
+			config: Config{Routes: []Route{{EventType: "issue_updated"}}},
+			event: &entities.Event{
+				Type:        "issue_updated",
+				OriginalRaw: []byte(`{"issue": {"key": "PRJ-1"}}`),
+			},
+			expectedData: `{"issue": {"key": "PRJ-1"}}`,
+		},
+		"default route": {
+			config: Config{
+				Routes:  []Route{issueRoute, projectRoute},
+				Default: &Route{OutputEvent: []byte(`{"kind": "other"}`)},
+			},
+			event: &entities.Event{
+				Type:        "version_released",
+				OriginalRaw: []byte(`{"version": {"id": "1"}}`),
+			},
+			expectedData: `{"kind":"other"}`,
+		},
+		"event discarded without default route": {
+			config: Config{Routes: []Route{issueRoute, projectRoute}},
+			event: &entities.Event{
+				Type:        "version_released",
+				OriginalRaw: []byte(`{"version": {"id": "1"}}`),
+			},
+			expectedResultError: entities.ErrDiscardEvent.Error(),
+		},
+		"event type routes do not decode the event": {
+			config: Config{Routes: []Route{issueRoute}, Default: &Route{}},
+			event: &entities.Event{
+				Type:        "version_released",
+				OriginalRaw: []byte(`not a json`),
+			},
+			expectedData: `not a json`,
+		},
+		"fails if event is not a JSON": {
+			config: Config{Routes: []Route{projectRoute}},
+			event: &entities.Event{
+				Type:        "project_created",
+				OriginalRaw: []byte(`not a json`),
+			},
+			expectedResultError: "invalid character 'o' in literal null (expecting 'u')",
+		},
+		"fails to create with invalid condition": {
+			config:           Config{Routes: []Route{{Condition: "unknown.key"}}},
+			expectedNewError: "ERROR: <input>:1:1: undeclared reference to 'unknown' (in container '')\n | unknown.key\n | ^",
+		},
+		"fails to create with invalid output event": {
+			config:           Config{Routes: []Route{{EventType: "issue_updated", OutputEvent: []byte(`{"key": "{{ key | unknown }}"}`)}}},
+			expectedNewError: "error creating operation: unknown function: unknown",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			processor, err := New(tc.config)
+			if tc.expectedNewError != "" {
+				require.EqualError(t, err, tc.expectedNewError)
+				return
+			}
+			require.NoError(t, err)
+
+			result, err := processor.Process(tc.event)
+			if tc.expectedResultError != "" {
+				require.EqualError(t, err, tc.expectedResultError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedData, string(result.Data()))
+		})
+	}
+}
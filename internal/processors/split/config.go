@@ -0,0 +1,34 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package split
+
+import (
+	"errors"
+
+	"github.com/mia-platform/integration-connector-agent/internal/processors/identity"
+)
+
+var (
+	ErrPathNotSet        = errors.New("path is required")
+	ErrPrimaryKeysNotSet = errors.New("primaryKeys is required")
+)
+
+type Config struct {
+	// Path is the gjson path of the array whose elements become the events.
+	Path string `json:"path"`
+	// PrimaryKeys are the primary keys of the events, built from each element as the ones of the
+	// identity processor. They are required, so that the events of the elements do not overwrite
+	// each other in the sinks.
+	PrimaryKeys []identity.PrimaryKey `json:"primaryKeys,omitempty"`
+}
+
+func (c Config) Validate() error {
+	if c.Path == "" {
+		return ErrPathNotSet
+	}
+	if len(c.PrimaryKeys) == 0 {
+		return ErrPrimaryKeysNotSet
+	}
+	return identity.Config{PrimaryKeys: c.PrimaryKeys}.Validate()
+}
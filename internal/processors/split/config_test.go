@@ -0,0 +1,61 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package split
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mia-platform/integration-connector-agent/internal/processors/identity"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("unmarshal json", func(t *testing.T) {
+		cfg := Config{}
+		err := json.Unmarshal([]byte(`{
+			"path": "assets",
+			"primaryKeys": [{"key": "name", "value": "{{ name }}"}]
+		}`), &cfg)
+		require.NoError(t, err)
+		require.Equal(t, Config{
+			Path:        "assets",
+			PrimaryKeys: []identity.PrimaryKey{{Key: "name", Value: json.RawMessage(`"{{ name }}"`)}},
+		}, cfg)
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		testCases := map[string]struct {
+			cfg           Config
+			expectedError string
+		}{
+			"valid": {
+				cfg: Config{Path: "assets", PrimaryKeys: []identity.PrimaryKey{{Key: "name", Value: json.RawMessage(`"{{ name }}"`)}}},
+			},
+			"without path": {
+				expectedError: "path is required",
+			},
+			"without primary keys": {
+				cfg:           Config{Path: "assets"},
+				expectedError: "primaryKeys is required",
+			},
+			"primary key without value": {
+				cfg:           Config{Path: "assets", PrimaryKeys: []identity.PrimaryKey{{Key: "name"}}},
+				expectedError: "primary key requires key and value: primary key 0",
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				err := tc.cfg.Validate()
+				if tc.expectedError != "" {
+					require.EqualError(t, err, tc.expectedError)
+					return
+				}
+				require.NoError(t, err)
+			})
+		}
+	})
+}
@@ -0,0 +1,73 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package split
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/processors/identity"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+)
+
+var (
+	ErrNotArray = errors.New("value to split is not an array")
+)
+
+// Split replaces the event with an event for each element of an array of its payload. The events
+// have the type and the operation of the split event, and the primary keys built from the element.
+type Split struct {
+	path     string
+	identity *identity.Identity
+}
+
+func (s *Split) Process(event entities.PipelineEvent) (entities.PipelineEvent, error) {
+	return entities.ProcessOne(s, event)
+}
+
+// ProcessEvents returns an event for each element of the array. The event is discarded if the
+// array is missing or empty.
+func (s *Split) ProcessEvents(event entities.PipelineEvent) ([]entities.PipelineEvent, error) {
+	logger := logrus.WithFields(logrus.Fields{
+		"eventType":   event.GetType(),
+		"primaryKeys": event.GetPrimaryKeys().Map(),
+	})
+
+	value := gjson.GetBytes(event.Data(), s.path)
+	if value.Exists() && value.Type != gjson.Null && !value.IsArray() {
+		return nil, fmt.Errorf("%w: %s", ErrNotArray, s.path)
+	}
+	elements := value.Array()
+	if len(elements) == 0 {
+		logger.WithField("reason", "empty_array").Debug("event skipped by split")
+		return nil, fmt.Errorf("%w: %s is empty", entities.ErrDiscardEvent, s.path)
+	}
+
+	events := make([]entities.PipelineEvent, 0, len(elements))
+	for i, element := range elements {
+		elementEvent, err := s.identity.Process(&entities.Event{
+			Type:          event.GetType(),
+			OperationType: event.Operation(),
+			OriginalRaw:   []byte(element.Raw),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("element %d: %w", i, err)
+		}
+		events = append(events, elementEvent)
+	}
+
+	logger.WithField("events", len(events)).Debug("event split")
+	return events, nil
+}
+
+func New(cfg Config) (*Split, error) {
+	i, err := identity.New(identity.Config{PrimaryKeys: cfg.PrimaryKeys})
+	if err != nil {
+		return nil, err
+	}
+	return &Split{path: cfg.Path, identity: i}, nil
+}
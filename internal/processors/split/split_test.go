@@ -0,0 +1,114 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package split
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/processors/identity"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	sourcePrimaryKeys := entities.PkFields{{Key: "exportId", Value: "42"}}
+	testCases := map[string]struct {
+		cfg   Config
+		input string
+
+		expected      []entities.PipelineEvent
+		expectedError string
+	}{
+		"an event for each element": {
+			cfg: Config{
+				Path: "result.assets",
+				PrimaryKeys: []identity.PrimaryKey{
+					{Key: "name", Value: json.RawMessage(`"{{ name }}"`)},
+					{Key: "type", Value: json.RawMessage(`{"$cel": "data.assetType.lowerAscii()"}`)},
+				},
+			},
+			input: `{"result":{"assets":[{"name":"bucket-1","assetType":"Bucket"},{"name":"vm-1","assetType":"Instance"}]}}`,
+			expected: []entities.PipelineEvent{
+				&entities.Event{
+					PrimaryKeys:   entities.PkFields{{Key: "name", Value: "bucket-1"}, {Key: "type", Value: "bucket"}},
+					Type:          "export",
+					OperationType: entities.Write,
+					OriginalRaw:   []byte(`{"name":"bucket-1","assetType":"Bucket"}`),
+				},
+				&entities.Event{
+					PrimaryKeys:   entities.PkFields{{Key: "name", Value: "vm-1"}, {Key: "type", Value: "instance"}},
+					Type:          "export",
+					OperationType: entities.Write,
+					OriginalRaw:   []byte(`{"name":"vm-1","assetType":"Instance"}`),
+				},
+			},
+		},
+		"the whole payload": {
+			cfg:   Config{Path: "@this", PrimaryKeys: []identity.PrimaryKey{{Key: "id", Value: json.RawMessage(`"{{ id }}"`)}}},
+			input: `[{"id":"a"},{"id":"b"}]`,
+			expected: []entities.PipelineEvent{
+				&entities.Event{PrimaryKeys: entities.PkFields{{Key: "id", Value: "a"}}, Type: "export", OperationType: entities.Write, OriginalRaw: []byte(`{"id":"a"}`)},
+				&entities.Event{PrimaryKeys: entities.PkFields{{Key: "id", Value: "b"}}, Type: "export", OperationType: entities.Write, OriginalRaw: []byte(`{"id":"b"}`)},
+			},
+		},
+		"empty array": {
+			cfg:           Config{Path: "assets", PrimaryKeys: []identity.PrimaryKey{{Key: "name", Value: json.RawMessage(`"{{ name }}"`)}}},
+			input:         `{"assets":[]}`,
+			expectedError: "event discarded: assets is empty",
+		},
+		"missing array": {
+			cfg:           Config{Path: "assets", PrimaryKeys: []identity.PrimaryKey{{Key: "name", Value: json.RawMessage(`"{{ name }}"`)}}},
+			input:         `{}`,
+			expectedError: "event discarded: assets is empty",
+		},
+		"not an array": {
+			cfg:           Config{Path: "assets", PrimaryKeys: []identity.PrimaryKey{{Key: "name", Value: json.RawMessage(`"{{ name }}"`)}}},
+			input:         `{"assets":{"name":"bucket-1"}}`,
+			expectedError: "value to split is not an array: assets",
+		},
+		"empty primary key of an element": {
+			cfg: Config{
+				Path:        "assets",
+				PrimaryKeys: []identity.PrimaryKey{{Key: "name", Value: json.RawMessage(`"{{ name }}"`)}},
+			},
+			input:         `{"assets":[{"name":"bucket-1"},{}]}`,
+			expectedError: "element 1: primary key value is empty: name",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, tc.cfg.Validate())
+			s, err := New(tc.cfg)
+			require.NoError(t, err)
+
+			events, err := s.ProcessEvents(&entities.Event{
+				PrimaryKeys:   sourcePrimaryKeys,
+				Type:          "export",
+				OperationType: entities.Write,
+				OriginalRaw:   []byte(tc.input),
+			})
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, events)
+		})
+	}
+
+	t.Run("process fails with more than one element", func(t *testing.T) {
+		s, err := New(Config{Path: "assets", PrimaryKeys: []identity.PrimaryKey{{Key: "name", Value: json.RawMessage(`"{{ name }}"`)}}})
+		require.NoError(t, err)
+
+		event, err := s.Process(&entities.Event{OriginalRaw: []byte(`{"assets":[{"name":"bucket-1"}]}`)})
+		require.NoError(t, err)
+		require.JSONEq(t, `{"name":"bucket-1"}`, string(event.Data()))
+
+		_, err = s.Process(&entities.Event{OriginalRaw: []byte(`{"assets":[{"name":"a"},{"name":"b"}]}`)})
+		require.EqualError(t, err, "processor returned multiple events: 2 events")
+	})
+}
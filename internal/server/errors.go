@@ -8,5 +8,6 @@ import "errors"
 var (
 	errSetupSource       = errors.New("error setting up source")
 	errSetupWriter       = errors.New("error setting up writer")
+	errSetupQueue        = errors.New("error setting up pipeline queue")
 	errUnsupportedWriter = errors.New("unsupported writer type")
 )
@@ -5,18 +5,27 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
+	"sync"
 
 	"github.com/mia-platform/integration-connector-agent/entities"
 	"github.com/mia-platform/integration-connector-agent/internal/config"
+	"github.com/mia-platform/integration-connector-agent/internal/deadletter"
+	"github.com/mia-platform/integration-connector-agent/internal/health"
+	"github.com/mia-platform/integration-connector-agent/internal/metrics"
 	"github.com/mia-platform/integration-connector-agent/internal/pipeline"
+	"github.com/mia-platform/integration-connector-agent/internal/pipeline/queue"
 	"github.com/mia-platform/integration-connector-agent/internal/processors"
 	"github.com/mia-platform/integration-connector-agent/internal/sinks"
 	consolecatalog "github.com/mia-platform/integration-connector-agent/internal/sinks/console-catalog"
 	crudservice "github.com/mia-platform/integration-connector-agent/internal/sinks/crud-service"
 	fakewriter "github.com/mia-platform/integration-connector-agent/internal/sinks/fake"
+	httpsink "github.com/mia-platform/integration-connector-agent/internal/sinks/http"
 	"github.com/mia-platform/integration-connector-agent/internal/sinks/kafka"
 	"github.com/mia-platform/integration-connector-agent/internal/sinks/mongo"
+	"github.com/mia-platform/integration-connector-agent/internal/sinks/postgres"
 	"github.com/mia-platform/integration-connector-agent/internal/sources"
 	awssqs "github.com/mia-platform/integration-connector-agent/internal/sources/aws-sqs"
 	azureactivitylogeventhub "github.com/mia-platform/integration-connector-agent/internal/sources/azure-activity-log-event-hub"
@@ -37,6 +46,10 @@ import (
 type Integration struct {
 	PipelineGroup  pipeline.IPipelineGroup
 	sourcesToClose []sources.CloseableSource
+
+	name   string
+	config config.Integration
+	routes *integrationRoutes
 }
 
 func (i *Integration) appendCloseableSource(source sources.CloseableSource) {
@@ -46,55 +59,140 @@ func (i *Integration) appendCloseableSource(source sources.CloseableSource) {
 	i.sourcesToClose = append(i.sourcesToClose, source)
 }
 
+// Close stops the integration: the sources stop receiving new events first, then the pipelines
+// process the events already received until ctx is done, and close their sinks.
 func (i Integration) Close(ctx context.Context) error {
-	if i.PipelineGroup != nil {
-		return i.PipelineGroup.Close(ctx)
-	}
+	var closeErrors []error
 	for _, source := range i.sourcesToClose {
 		if err := source.Close(); err != nil {
-			return fmt.Errorf("error closing source: %w", err)
+			closeErrors = append(closeErrors, fmt.Errorf("error closing source: %w", err))
 		}
 	}
-	return nil
+	if i.PipelineGroup != nil {
+		if err := i.PipelineGroup.Close(ctx); err != nil {
+			closeErrors = append(closeErrors, err)
+		}
+	}
+	return errors.Join(closeErrors...)
+}
+
+// closeIntegrations closes all the integrations concurrently, reporting the failures.
+func closeIntegrations(ctx context.Context, log *logrus.Logger, integrations []*Integration) {
+	var wg sync.WaitGroup
+	for i, integration := range integrations {
+		wg.Add(1)
+		go func(i int, integration *Integration) {
+			defer wg.Done()
+			if err := integration.Close(ctx); err != nil {
+				log.WithError(err).WithField("integrationIndex", i).Error("error closing integration")
+			}
+		}(i, integration)
+	}
+	wg.Wait()
+	log.Info("integrations closed")
 }
 
 // TODO: write an integration test to test this setup
-func setupIntegrations(ctx context.Context, log *logrus.Logger, cfg *config.Configuration, oasRouter *swagger.Router[fiber.Handler, fiber.Router]) ([]*Integration, error) {
+func setupIntegrations(ctx context.Context, log *logrus.Logger, cfg *config.Configuration, servicePrefix string, m *metrics.Metrics, registry *health.Registry) ([]*Integration, error) {
 	integrations := make([]*Integration, 0)
-	for _, cfgIntegration := range cfg.Integrations {
-		log.WithFields(logrus.Fields{
-			"sourceType":   cfgIntegration.Source.Type,
-			"pipelinesLen": len(cfgIntegration.Pipelines),
-		}).Trace("setting up integration")
-
-		pipelines, err := setupIntegrationPipelines(ctx, log, cfgIntegration)
+	for i, cfgIntegration := range cfg.Integrations {
+		integration, err := setupIntegration(ctx, log, integrationName(i, cfgIntegration), cfgIntegration, servicePrefix, m, registry)
 		if err != nil {
 			return nil, err
 		}
+		integrations = append(integrations, integration)
+	}
 
-		pg := pipeline.NewGroup(log, pipelines...)
+	return integrations, nil
+}
 
-		// skip this source as it is only used for test, but still add it to integrations
-		if cfgIntegration.Source.Type == "test" {
-			integrations = append(integrations, &Integration{
-				PipelineGroup: pg,
-			})
-			continue
-		}
+// setupIntegration builds the pipelines and the source of an integration. The routes added by
+// the source are recorded in the integration, to be served by the integrations router.
+func setupIntegration(ctx context.Context, log *logrus.Logger, name string, cfgIntegration config.Integration, servicePrefix string, m *metrics.Metrics, registry *health.Registry) (*Integration, error) {
+	log.WithFields(logrus.Fields{
+		"sourceType":   cfgIntegration.Source.Type,
+		"pipelinesLen": len(cfgIntegration.Pipelines),
+	}).Trace("setting up integration")
 
-		integration, err := runIntegration(ctx, log, pg, cfgIntegration, oasRouter)
-		if err != nil {
-			return nil, err
+	pipelines, err := setupIntegrationPipelines(ctx, log, cfgIntegration, m, registry, name)
+	if err != nil {
+		return nil, err
+	}
+
+	pg := pipeline.NewGroup(log, pipelines...)
+
+	// skip this source as it is only used for test, but still add it to integrations
+	if cfgIntegration.Source.Type == "test" {
+		return &Integration{
+			PipelineGroup: pg,
+			name:          name,
+			config:        cfgIntegration,
+		}, nil
+	}
+
+	oasRouter, routes, err := newIntegrationRouter(servicePrefix)
+	if err != nil {
+		closePipelines(ctx, log, name, pg)
+		return nil, err
+	}
+
+	integration, err := runIntegration(ctx, log, pg, cfgIntegration, oasRouter)
+	if err != nil {
+		closePipelines(ctx, log, name, pg)
+		return nil, err
+	}
+	integration.name = name
+	integration.config = cfgIntegration
+	integration.routes = routes
+
+	for _, source := range integration.sourcesToClose {
+		if checker, ok := source.(health.Checker); ok {
+			registry.RegisterCritical(fmt.Sprintf("%s/source", name), cfgIntegration.Source.Type, checker)
+		}
+	}
+	for i, pip := range pipelines {
+		if checker, ok := pip.(health.Checker); ok {
+			registry.RegisterCritical(fmt.Sprintf("%s/pipelines/%d", name, i), "pipeline", checker)
 		}
+	}
+	return integration, nil
+}
 
-		integrations = append(integrations, integration)
+// closePipelines releases the sinks and the queues of an integration not set up, that could be
+// needed by the integration built again.
+func closePipelines(ctx context.Context, log *logrus.Logger, name string, pg pipeline.IPipelineGroup) {
+	if err := pg.Close(ctx); err != nil {
+		log.WithError(err).WithField("integration", name).Warn("error closing pipelines of the integration not set up")
 	}
+}
 
-	return integrations, nil
+// integrationName returns the name identifying the integration in logs and metrics: the configured
+// one, or its position in the configuration.
+func integrationName(index int, cfgIntegration config.Integration) string {
+	if cfgIntegration.Name != "" {
+		return cfgIntegration.Name
+	}
+	return strconv.Itoa(index)
 }
 
-func setupIntegrationPipelines(ctx context.Context, log *logrus.Logger, cfgIntegration config.Integration) ([]pipeline.IPipeline, error) {
+func setupIntegrationPipelines(ctx context.Context, log *logrus.Logger, cfgIntegration config.Integration, m *metrics.Metrics, registry *health.Registry, name string) (_ []pipeline.IPipeline, err error) {
 	pipelines := make([]pipeline.IPipeline, 0)
+	// closers release the sinks and the queues opened for the pipeline being built, which
+	// are owned by the pipeline once it is created
+	var closers []func(context.Context) error
+	defer func() {
+		if err == nil {
+			return
+		}
+		// the disk queues lock their file: they must be released to be opened again,
+		// e.g. by the integration built again with the previous configuration
+		for _, closer := range closers {
+			if closeErr := closer(ctx); closeErr != nil {
+				log.WithError(closeErr).WithField("integration", name).Warn("error closing resources of the pipeline not set up")
+			}
+		}
+		closePipelines(ctx, log, name, pipeline.NewGroup(log, pipelines...))
+	}()
 
 	for i, cfgPipeline := range cfgIntegration.Pipelines {
 		log.WithFields(logrus.Fields{
@@ -103,32 +201,101 @@ func setupIntegrationPipelines(ctx context.Context, log *logrus.Logger, cfgInteg
 			"processorsLen": len(cfgPipeline.Processors),
 		}).Trace("setting up pipeline processors")
 
-		sinks, err := setupSinks(ctx, log, cfgPipeline.Sinks)
+		sinks, err := setupSinks(ctx, log, cfgPipeline.Sinks, max(cfgPipeline.Workers, 1))
 		if err != nil {
 			return nil, err
 		}
-		if len(sinks) != 1 {
-			return nil, fmt.Errorf("only 1 writer is supported, now there are %d", len(sinks))
+		closers = make([]func(context.Context) error, 0, len(sinks)+3)
+		for _, sink := range sinks {
+			closers = append(closers, sink.Close)
+		}
+
+		targets := make([]pipeline.Sink, 0, len(sinks))
+		for j, sink := range sinks {
+			retryConfig, err := config.GetConfig[*sinkRetryConfig](cfgPipeline.Sinks[j])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", errSetupWriter, err)
+			}
+			targets = append(targets, pipeline.Sink{
+				Type:  cfgPipeline.Sinks[j].Type,
+				Sink:  sink,
+				Retry: retryConfig.Retry,
+			})
+			if checker, ok := sink.(health.Checker); ok {
+				registry.Register(fmt.Sprintf("%s/pipelines/%d/sinks/%d", name, i, j), cfgPipeline.Sinks[j].Type, checker)
+			}
 		}
-		writer := sinks[0]
 
 		proc, err := processors.New(log, cfgPipeline.Processors)
 		if err != nil {
 			return nil, err
 		}
+		closers = append(closers, func(context.Context) error { return proc.Close() })
 
-		pip, err := pipeline.New(log, proc, writer)
+		eventQueue, err := queue.New(cfgPipeline.Queue)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", errSetupQueue, err)
+		}
+		closers = append(closers, func(context.Context) error { return eventQueue.Close() })
+
+		opts := []pipeline.Option{
+			pipeline.WithQueue(eventQueue),
+			pipeline.WithMetrics(m.Pipeline(name, i, cfgIntegration.Source.Type)),
+		}
+		if cfgPipeline.SinksPolicy != "" {
+			opts = append(opts, pipeline.WithSinksPolicy(pipeline.SinksPolicy(cfgPipeline.SinksPolicy)))
+		}
+		if cfgPipeline.Workers > 0 {
+			opts = append(opts, pipeline.WithWorkers(cfgPipeline.Workers))
+		}
+		if cfgPipeline.DeadLetterQueue != nil {
+			queue, err := deadletter.New(ctx, log, *cfgPipeline.DeadLetterQueue)
+			if err != nil {
+				return nil, err
+			}
+			closers = append(closers, queue.Close)
+			opts = append(opts, pipeline.WithDeadLetterQueue(queue))
+		}
+
+		pip, err := pipeline.NewWithSinks(log, proc, targets, opts...)
 		if err != nil {
 			return nil, err
 		}
 
+		closers = nil
 		pipelines = append(pipelines, pip)
 	}
 	return pipelines, nil
 }
 
-func setupSinks(ctx context.Context, log *logrus.Logger, writers config.Sinks) ([]sinks.Sink[entities.PipelineEvent], error) { //nolint: gocyclo
+// sinkRetryConfig is the retry policy that can be set in the configuration of any sink.
+type sinkRetryConfig struct {
+	Retry *pipeline.RetryPolicy `json:"retry,omitempty"`
+}
+
+func (c *sinkRetryConfig) Validate() error {
+	if c.Retry == nil {
+		return nil
+	}
+	return c.Retry.Validate()
+}
+
+// setupSinks creates the sinks of a pipeline, whose events are written concurrently by the given
+// number of workers.
+func setupSinks(ctx context.Context, log *logrus.Logger, writers config.Sinks, workers int) (_ []sinks.Sink[entities.PipelineEvent], err error) { //nolint: gocyclo
 	var w []sinks.Sink[entities.PipelineEvent]
+	defer func() {
+		if err == nil {
+			return
+		}
+		// the sinks already created are closed, releasing their connections
+		for _, sink := range w {
+			if closeErr := sink.Close(ctx); closeErr != nil {
+				log.WithError(closeErr).Warn("error closing sink not set up")
+			}
+		}
+	}()
+
 	for _, configuredWriter := range writers {
 		switch configuredWriter.Type {
 		case sinks.Mongo:
@@ -136,6 +303,14 @@ func setupSinks(ctx context.Context, log *logrus.Logger, writers config.Sinks) (
 			if err != nil {
 				return nil, fmt.Errorf("%w: %w", errSetupWriter, err)
 			}
+			if config.Batch != nil {
+				if err := config.Batch.SetWriters(workers); err != nil {
+					return nil, fmt.Errorf("%w: %w", errSetupWriter, err)
+				}
+				if workers == 1 {
+					log.WithField("sinkType", configuredWriter.Type).Warn("mongo batch has no effect with a single pipeline worker")
+				}
+			}
 			mongoWriter, err := mongo.NewMongoDBWriter[entities.PipelineEvent](ctx, config)
 			if err != nil {
 				return nil, fmt.Errorf("%w: %w", errSetupWriter, err)
@@ -171,6 +346,26 @@ func setupSinks(ctx context.Context, log *logrus.Logger, writers config.Sinks) (
 				return nil, fmt.Errorf("%w: %w", errSetupWriter, err)
 			}
 			w = append(w, kafkaSink)
+		case sinks.HTTP:
+			config, err := config.GetConfig[*httpsink.Config](configuredWriter)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", errSetupWriter, err)
+			}
+			httpSink, err := httpsink.New[entities.PipelineEvent](config)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", errSetupWriter, err)
+			}
+			w = append(w, httpSink)
+		case sinks.Postgres:
+			config, err := config.GetConfig[*postgres.Config](configuredWriter)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", errSetupWriter, err)
+			}
+			postgresWriter, err := postgres.NewPostgresWriter[entities.PipelineEvent](ctx, config)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", errSetupWriter, err)
+			}
+			w = append(w, postgresWriter)
 		case sinks.Fake:
 			config, err := config.GetConfig[*fakewriter.Config](configuredWriter)
 			if err != nil {
@@ -216,7 +411,12 @@ func setupSource(ctx context.Context, log *logrus.Logger, source config.GenericC
 		sources.GCPInventoryPubSub: func() error { return setupGCPInventorySource(ctx, log, source, pg, oasRouter, integration) },
 		sources.AWSCloudTrailSQS:   func() error { return setupAWSCloudTrailSource(ctx, log, source, pg, oasRouter, integration) },
 		sources.AzureActivityLogEventHub: func() error {
-			return wrapSetupError(azureactivitylogeventhub.AddSource(ctx, source, pg, log, oasRouter))
+			s, err := azureactivitylogeventhub.AddSource(ctx, source, pg, log, oasRouter)
+			if err != nil {
+				return wrapSetupError(err)
+			}
+			integration.appendCloseableSource(s)
+			return nil
 		},
 		sources.AzureDevOps: func() error {
 			return wrapSetupError(azuredevops.AddSourceToRouter(ctx, source, pg, oasRouter))
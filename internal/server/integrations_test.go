@@ -13,16 +13,14 @@ import (
 	"github.com/mia-platform/integration-connector-agent/internal/sinks"
 	"github.com/mia-platform/integration-connector-agent/internal/sources"
 
-	swagger "github.com/davidebianchi/gswagger"
-	oasfiber "github.com/davidebianchi/gswagger/support/fiber"
-	"github.com/getkin/kin-openapi/openapi3"
-	"github.com/gofiber/fiber/v2"
 	"github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/require"
 )
 
 func TestSetupWriters(t *testing.T) {
 	ctx := t.Context()
+	t.Setenv("TEST_MONGO_URL", "mongodb://localhost:27017/db")
+	t.Setenv("TEST_POSTGRES_URL", "postgres://localhost:5432/inventory")
 
 	testCases := map[string]struct {
 		writers config.Sinks
@@ -60,12 +58,47 @@ func TestSetupWriters(t *testing.T) {
 			},
 			expectError: "error setting up writer: configuration not valid: URL not set in CRUD service sink configuration",
 		},
+		"mongo writer fail for batch greater than the workers": {
+			writers: config.Sinks{
+				config.GenericConfig{
+					Type: sinks.Mongo,
+					Raw:  []byte(`{"url":{"fromEnv":"TEST_MONGO_URL"},"collection":"items","batch":{"maxSize":500}}`),
+				},
+			},
+			expectError: "error setting up writer: batch maxSize 500 cannot be greater than the 1 workers of the pipeline",
+		},
+		"http writer": {
+			writers: config.Sinks{
+				config.GenericConfig{
+					Type: sinks.HTTP,
+					Raw:  []byte(`{"write":{"method":"PUT","url":"https://some-url.com/items/{{ id }}"},"delete":{"url":"https://some-url.com/items/{{ id }}"}}`),
+				},
+			},
+		},
+		"http writer fail for invalid configuration": {
+			writers: config.Sinks{
+				config.GenericConfig{
+					Type: sinks.HTTP,
+					Raw:  []byte(`{"write":{"method":"PUT"}}`),
+				},
+			},
+			expectError: "error setting up writer: configuration not valid: url is required: write request",
+		},
+		"postgres writer fail for invalid configuration": {
+			writers: config.Sinks{
+				config.GenericConfig{
+					Type: sinks.Postgres,
+					Raw:  []byte(`{"url":{"fromEnv":"TEST_POSTGRES_URL"}}`),
+				},
+			},
+			expectError: "error setting up writer: configuration not valid: table is required",
+		},
 	}
 
 	for name, tc := range testCases {
 		t.Run(name, func(t *testing.T) {
 			log, _ := test.NewNullLogger()
-			w, err := setupSinks(ctx, log, tc.writers)
+			w, err := setupSinks(ctx, log, tc.writers, 1)
 
 			if tc.expectError != "" {
 				require.EqualError(t, err, tc.expectError)
@@ -86,12 +119,12 @@ func TestSetupIntegrations(t *testing.T) {
 		expectError          string
 		expectedIntegrations int
 	}{
-		"more than 1 writers not supported": {
+		"more than 1 writers": {
 			cfg: config.Configuration{
 				Integrations: []config.Integration{
 					{
 						Source: config.GenericConfig{
-							Type: sources.Jira,
+							Type: "test",
 						},
 						Pipelines: []config.Pipeline{
 							{
@@ -104,7 +137,13 @@ func TestSetupIntegrations(t *testing.T) {
 					},
 				},
 			},
-			expectError: "only 1 writer is supported, now there are 2",
+		},
+		"more than 1 writers with sinks policy": {
+			jsonCfg: `{"integrations":[{"source":{"type":"test"},"pipelines":[{"sinksPolicy":"ordered","sinks":[{"type":"fake","raw":{}},{"type":"fake","raw":{}}]}]}]}`,
+		},
+		"unsupported sinks policy": {
+			jsonCfg:     `{"integrations":[{"source":{"type":"test"},"pipelines":[{"sinksPolicy":"unsupported","sinks":[{"type":"fake","raw":{}}]}]}]}`,
+			expectError: "unsupported sinks policy: unsupported",
 		},
 		"multiple integration sources": {
 			cfg: config.Configuration{
@@ -188,7 +227,6 @@ func TestSetupIntegrations(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			ctx := t.Context()
 			log, _ := test.NewNullLogger()
-			router := getRouter(t)
 
 			cfg := &tc.cfg
 			if tc.jsonCfg != "" {
@@ -198,7 +236,7 @@ func TestSetupIntegrations(t *testing.T) {
 				cfg = &jsonConfig
 			}
 
-			integrations, err := setupIntegrations(ctx, log, cfg, router)
+			integrations, err := setupIntegrations(ctx, log, cfg, "", nil, nil)
 			if tc.expectError != "" {
 				require.EqualError(t, err, tc.expectError)
 			} else {
@@ -214,24 +252,6 @@ func TestSetupIntegrations(t *testing.T) {
 	}
 }
 
-func getRouter(t *testing.T) *swagger.Router[fiber.Handler, fiber.Router] {
-	t.Helper()
-
-	app := fiber.New()
-	router, err := swagger.NewRouter(oasfiber.NewRouter(app), swagger.Options{
-		Openapi: &openapi3.T{
-			OpenAPI: "3.1.0",
-			Info: &openapi3.Info{
-				Title:   "Test",
-				Version: "test-version",
-			},
-		},
-	})
-	require.NoError(t, err)
-
-	return router
-}
-
 func getFakeWriter(t *testing.T) config.GenericConfig {
 	t.Helper()
 
@@ -0,0 +1,216 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+	"github.com/mia-platform/integration-connector-agent/internal/health"
+	"github.com/mia-platform/integration-connector-agent/internal/metrics"
+
+	"github.com/sirupsen/logrus"
+)
+
+// reloader holds the running integrations, and replaces the ones whose configuration is changed.
+type reloader struct {
+	mtx sync.Mutex
+
+	ctx      context.Context
+	log      *logrus.Logger
+	env      config.EnvironmentVariables
+	metrics  *metrics.Metrics
+	registry *health.Registry
+	router   *integrationsRouter
+
+	integrations []*Integration
+	closed       bool
+}
+
+// reload applies cfg: the integrations whose configuration is not changed keep running, while
+// the changed and the removed ones are drained and closed before the new ones are set up.
+// An integration that fails to be set up is set up again with its previous configuration.
+func (r *reloader) reload(cfg *config.Configuration) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	unchanged, toClose := r.changedIntegrations(cfg)
+	if len(toClose) == 0 && len(unchanged) == len(cfg.Integrations) {
+		r.log.Info("configuration not changed, integrations not reloaded")
+		return nil
+	}
+
+	// the routes of the integrations to close respond with an error while reloading,
+	// and their pipelines process the events already received
+	if err := r.router.swap(unchanged, toClose); err != nil {
+		return err
+	}
+	closeCtx, cancel := context.WithTimeout(r.ctx, time.Duration(r.env.DelayShutdownSeconds)*time.Second)
+	closeIntegrations(closeCtx, r.log, toClose)
+	cancel()
+
+	previous := make(map[string]*Integration, len(toClose))
+	for _, integration := range toClose {
+		r.registry.Unregister(integration.name + "/")
+		r.metrics.RemoveIntegration(integration.name)
+		previous[integration.name] = integration
+	}
+
+	integrations := make([]*Integration, 0, len(cfg.Integrations))
+	for i, cfgIntegration := range cfg.Integrations {
+		name := integrationName(i, cfgIntegration)
+		if integration := findIntegration(unchanged, name); integration != nil {
+			integrations = append(integrations, integration)
+			continue
+		}
+
+		integration, err := r.setupIntegration(name, cfgIntegration, previous[name])
+		if err != nil {
+			r.log.WithError(err).WithField("integration", name).Error("error reloading integration, integration removed")
+			continue
+		}
+		integrations = append(integrations, integration)
+	}
+
+	r.integrations = integrations
+	if err := r.router.swap(integrations, nil); err != nil {
+		return err
+	}
+
+	r.log.WithFields(logrus.Fields{
+		"integrationsLen": len(integrations),
+		"reloadedLen":     len(integrations) - len(unchanged),
+		"closedLen":       len(toClose),
+	}).Info("integrations reloaded")
+	return nil
+}
+
+// changedIntegrations compares the running integrations with cfg, matching them by name, and
+// returns the ones to keep and the ones to close, because changed or removed.
+func (r *reloader) changedIntegrations(cfg *config.Configuration) ([]*Integration, []*Integration) {
+	running := make(map[string]*Integration, len(r.integrations))
+	for _, integration := range r.integrations {
+		running[integration.name] = integration
+	}
+
+	unchanged := make([]*Integration, 0, len(cfg.Integrations))
+	toClose := make([]*Integration, 0)
+	for i, cfgIntegration := range cfg.Integrations {
+		name := integrationName(i, cfgIntegration)
+		integration, ok := running[name]
+		if !ok {
+			continue
+		}
+		delete(running, name)
+		if reflect.DeepEqual(integration.config, cfgIntegration) {
+			unchanged = append(unchanged, integration)
+			continue
+		}
+		toClose = append(toClose, integration)
+	}
+	for _, integration := range running {
+		toClose = append(toClose, integration)
+	}
+	return unchanged, toClose
+}
+
+func (r *reloader) setupIntegration(name string, cfgIntegration config.Integration, previous *Integration) (*Integration, error) {
+	integration, err := setupIntegration(r.ctx, r.log, name, cfgIntegration, r.env.ServicePrefix, r.metrics, r.registry)
+	if err == nil || previous == nil {
+		return integration, err
+	}
+
+	r.log.WithError(err).WithField("integration", name).Error("error setting up the changed integration, restoring the previous configuration")
+	r.registry.Unregister(name + "/")
+	return setupIntegration(r.ctx, r.log, name, previous.config, r.env.ServicePrefix, r.metrics, r.registry)
+}
+
+// close closes the running integrations, whose routes respond with an error while their pipelines
+// process the events already received: after it, the configuration is no longer reloaded.
+func (r *reloader) close(ctx context.Context) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if r.closed {
+		return
+	}
+	r.closed = true
+	if err := r.router.swap(nil, r.integrations); err != nil {
+		r.log.WithError(err).Warn("error disabling the routes of the integrations")
+	}
+	for _, integration := range r.integrations {
+		r.registry.Unregister(integration.name + "/")
+	}
+	closeIntegrations(ctx, r.log, r.integrations)
+}
+
+func findIntegration(integrations []*Integration, name string) *Integration {
+	for _, integration := range integrations {
+		if integration.name == name {
+			return integration
+		}
+	}
+	return nil
+}
+
+// watchConfiguration reloads the configuration file each time a signal is received on
+// reloadChannel and, if CONFIGURATION_RELOAD_INTERVAL_SECONDS is set, each time the content of
+// the file changes. An invalid configuration is reported, and the running integrations are kept.
+func watchConfiguration[Signal any](ctx context.Context, r *reloader, reloadChannel <-chan Signal) {
+	var tick <-chan time.Time
+	if r.env.ConfigurationReloadIntervalSeconds > 0 {
+		ticker := time.NewTicker(time.Duration(r.env.ConfigurationReloadIntervalSeconds) * time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	checksum := configurationChecksum(r.env.ConfigurationPath)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-reloadChannel:
+			if !ok {
+				reloadChannel = nil
+				continue
+			}
+			r.log.Info("reloading configuration")
+		case <-tick:
+			current := configurationChecksum(r.env.ConfigurationPath)
+			if current == nil || bytes.Equal(current, checksum) {
+				continue
+			}
+			r.log.Info("configuration file changed, reloading configuration")
+		}
+
+		checksum = configurationChecksum(r.env.ConfigurationPath)
+		cfg, err := config.LoadServiceConfiguration(r.env.ConfigurationPath)
+		if err != nil {
+			r.log.WithError(err).Error("error loading configuration, integrations not reloaded")
+			continue
+		}
+		if err := r.reload(cfg); err != nil {
+			r.log.WithError(err).Error("error reloading integrations")
+		}
+	}
+}
+
+func configurationChecksum(path string) []byte {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	checksum := sha256.Sum256(content)
+	return checksum[:]
+}
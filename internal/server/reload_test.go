@@ -0,0 +1,212 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	const initialConfig = `{"integrations":[
+		{"name":"jira","source":{"type":"jira","webhookPath":"/jira/webhook"},"pipelines":[{"sinks":[{"type":"fake"}]}]},
+		{"name":"console","source":{"type":"console"},"pipelines":[{"sinks":[{"type":"fake"}]}]},
+		{"name":"test","source":{"type":"test"},"pipelines":[{"sinks":[{"type":"fake"}]}]}
+	]}`
+
+	setup := func(t *testing.T) (*fiber.App, *reloader) {
+		t.Helper()
+
+		log, _ := test.NewNullLogger()
+		app, reloader, err := newApp(t.Context(), config.EnvironmentVariables{DelayShutdownSeconds: 1}, log, parseConfig(t, initialConfig))
+		require.NoError(t, err)
+		return app, reloader
+	}
+
+	t.Run("only the changed integrations are set up again", func(t *testing.T) {
+		app, reloader := setup(t)
+		previous := reloader.integrations
+
+		err := reloader.reload(parseConfig(t, `{"integrations":[
+			{"name":"jira","source":{"type":"jira","webhookPath":"/jira/new-webhook"},"pipelines":[{"sinks":[{"type":"fake"}]}]},
+			{"name":"console","source":{"type":"console"},"pipelines":[{"sinks":[{"type":"fake"}]}]},
+			{"name":"added","source":{"type":"test"},"pipelines":[{"sinks":[{"type":"fake"}]}]}
+		]}`))
+		require.NoError(t, err)
+
+		require.Len(t, reloader.integrations, 3)
+		require.NotSame(t, previous[0], reloader.integrations[0])
+		require.Same(t, previous[1], reloader.integrations[1])
+		require.Equal(t, "added", reloader.integrations[2].name)
+
+		require.Equal(t, http.StatusNotFound, postWebhook(t, app, "/jira/webhook"))
+		require.NotEqual(t, http.StatusNotFound, postWebhook(t, app, "/jira/new-webhook"))
+		require.NotEqual(t, http.StatusNotFound, postWebhook(t, app, "/console/webhook"))
+
+		documentation := getDocumentation(t, app)
+		require.Contains(t, documentation, "/jira/new-webhook")
+		require.NotContains(t, documentation, `"/jira/webhook"`)
+	})
+
+	t.Run("unchanged configuration does not set up the integrations", func(t *testing.T) {
+		_, reloader := setup(t)
+		previous := reloader.integrations
+
+		require.NoError(t, reloader.reload(parseConfig(t, initialConfig)))
+		require.Equal(t, previous, reloader.integrations)
+	})
+
+	t.Run("integration failing to be set up keeps the previous configuration", func(t *testing.T) {
+		app, reloader := setup(t)
+		previous := reloader.integrations
+
+		err := reloader.reload(parseConfig(t, `{"integrations":[
+			{"name":"jira","source":{"type":"jira","webhookPath":"/jira/new-webhook"},"pipelines":[{"sinks":[{"type":"unsupported"}]}]},
+			{"name":"console","source":{"type":"console"},"pipelines":[{"sinks":[{"type":"fake"}]}]},
+			{"name":"test","source":{"type":"test"},"pipelines":[{"sinks":[{"type":"fake"}]}]}
+		]}`))
+		require.NoError(t, err)
+
+		require.Len(t, reloader.integrations, 3)
+		require.NotSame(t, previous[0], reloader.integrations[0])
+		require.Equal(t, previous[0].config, reloader.integrations[0].config)
+		require.NotEqual(t, http.StatusNotFound, postWebhook(t, app, "/jira/webhook"))
+		require.Equal(t, http.StatusNotFound, postWebhook(t, app, "/jira/new-webhook"))
+	})
+
+	t.Run("resources of an integration failing to be set up are released", func(t *testing.T) {
+		log, _ := test.NewNullLogger()
+		queuePath := filepath.Join(t.TempDir(), "queue.db")
+		integrationConfig := func(webhookPath, otherSink string) string {
+			return `{"integrations":[{"name":"jira","source":{"type":"jira","webhookPath":"` + webhookPath + `"},"pipelines":[
+				{"queue":{"type":"disk","path":"` + queuePath + `"},"sinks":[{"type":"fake"}]},
+				{"sinks":[{"type":"` + otherSink + `"}]}
+			]}]}`
+		}
+		app, reloader, err := newApp(t.Context(), config.EnvironmentVariables{DelayShutdownSeconds: 1}, log, parseConfig(t, integrationConfig("/jira/webhook", "fake")))
+		require.NoError(t, err)
+		t.Cleanup(func() { reloader.close(t.Context()) })
+
+		// the second pipeline sink fails, after the disk queue of the first pipeline has been opened
+		start := time.Now()
+		require.NoError(t, reloader.reload(parseConfig(t, integrationConfig("/jira/new-webhook", "unsupported"))))
+
+		// the previous configuration opens the same disk queue again without waiting for its lock
+		require.Less(t, time.Since(start), time.Second)
+		require.Len(t, reloader.integrations, 1)
+		require.NotEqual(t, http.StatusNotFound, postWebhook(t, app, "/jira/webhook"))
+		require.Equal(t, http.StatusNotFound, postWebhook(t, app, "/jira/new-webhook"))
+	})
+
+	t.Run("closed integrations respond with service unavailable", func(t *testing.T) {
+		app, reloader := setup(t)
+		reloader.close(t.Context())
+
+		require.Equal(t, http.StatusServiceUnavailable, postWebhook(t, app, "/jira/webhook"))
+	})
+
+	t.Run("closed reloader does not reload", func(t *testing.T) {
+		_, reloader := setup(t)
+		reloader.close(t.Context())
+		previous := reloader.integrations
+
+		require.NoError(t, reloader.reload(parseConfig(t, `{"integrations":[]}`)))
+		require.Equal(t, previous, reloader.integrations)
+	})
+}
+
+func TestWatchConfiguration(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	writeConfig := func(t *testing.T, webhookPath string) {
+		t.Helper()
+
+		content := `{"integrations":[{"name":"jira","source":{"type":"jira","webhookPath":"` + webhookPath + `"},"pipelines":[{"sinks":[{"type":"fake"}]}]}]}`
+		require.NoError(t, os.WriteFile(configPath, []byte(content), 0o600))
+	}
+	writeConfig(t, "/jira/webhook")
+
+	log, _ := test.NewNullLogger()
+	cfg, err := config.LoadServiceConfiguration(configPath)
+	require.NoError(t, err)
+	env := config.EnvironmentVariables{
+		ConfigurationPath:                  configPath,
+		ConfigurationReloadIntervalSeconds: 1,
+		DelayShutdownSeconds:               1,
+	}
+	app, reloader, err := newApp(t.Context(), env, log, cfg)
+	require.NoError(t, err)
+
+	reloadChannel := make(chan struct{})
+	go watchConfiguration(t.Context(), reloader, reloadChannel)
+
+	t.Run("reloads on signal", func(t *testing.T) {
+		writeConfig(t, "/jira/signal")
+		reloadChannel <- struct{}{}
+
+		require.Eventually(t, func() bool {
+			return postWebhook(t, app, "/jira/signal") != http.StatusNotFound
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("reloads when the file changes", func(t *testing.T) {
+		writeConfig(t, "/jira/changed")
+
+		require.Eventually(t, func() bool {
+			return postWebhook(t, app, "/jira/changed") != http.StatusNotFound
+		}, 3*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("invalid configuration keeps the integrations running", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(configPath, []byte(`{"integrations":"invalid"}`), 0o600))
+		reloadChannel <- struct{}{}
+		// the next signal is received once the invalid configuration has been handled
+		reloadChannel <- struct{}{}
+
+		require.NotEqual(t, http.StatusNotFound, postWebhook(t, app, "/jira/changed"))
+	})
+}
+
+func parseConfig(t *testing.T, rawConfig string) *config.Configuration {
+	t.Helper()
+
+	var cfg config.Configuration
+	require.NoError(t, json.Unmarshal([]byte(rawConfig), &cfg))
+	return &cfg
+}
+
+func postWebhook(t *testing.T, app *fiber.App, path string) int {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+	response, err := app.Test(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	return response.StatusCode
+}
+
+func getDocumentation(t *testing.T, app *fiber.App) string {
+	t.Helper()
+
+	request := httptest.NewRequest(http.MethodGet, "/documentations/json", nil)
+	response, err := app.Test(request)
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	return string(body)
+}
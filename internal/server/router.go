@@ -10,12 +10,12 @@ import (
 	"path/filepath"
 
 	"github.com/mia-platform/integration-connector-agent/internal/config"
+	"github.com/mia-platform/integration-connector-agent/internal/health"
+	"github.com/mia-platform/integration-connector-agent/internal/metrics"
 	"github.com/mia-platform/integration-connector-agent/internal/utils"
 
-	swagger "github.com/davidebianchi/gswagger"
-	oasfiber "github.com/davidebianchi/gswagger/support/fiber"
-	"github.com/getkin/kin-openapi/openapi3"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/fiber/v2/middleware/pprof"
 	glogrus "github.com/mia-platform/glogger/v4/loggers/logrus"
 	middleware "github.com/mia-platform/glogger/v4/middleware/fiber"
@@ -23,6 +23,13 @@ import (
 )
 
 func NewApp(ctx context.Context, env config.EnvironmentVariables, log *logrus.Logger, cfg *config.Configuration) (*fiber.App, error) {
+	app, _, err := newApp(ctx, env, log, cfg)
+	return app, err
+}
+
+// newApp returns the app serving the integrations configured in cfg, and the reloader to apply
+// a new configuration to them.
+func newApp(ctx context.Context, env config.EnvironmentVariables, log *logrus.Logger, cfg *config.Configuration) (*fiber.App, *reloader, error) {
 	app := fiber.New(fiber.Config{
 		DisableStartupMessage: true,
 	})
@@ -30,44 +37,41 @@ func NewApp(ctx context.Context, env config.EnvironmentVariables, log *logrus.Lo
 	cmdName := filepath.Base(os.Args[0])
 	middlewareLog := glogrus.GetLogger(logrus.NewEntry(log))
 	app.Use(middleware.RequestMiddlewareLogger(middlewareLog, []string{"/-/"}))
-	statusRoutes(app, cmdName, utils.ServiceVersionInformation())
+	registry := health.NewRegistry()
+	statusRoutes(app, cmdName, utils.ServiceVersionInformation(), registry)
+
+	m := metrics.New()
+	app.Get("/-/metrics", adaptor.HTTPHandler(m.Handler()))
+
 	if env.ServicePrefix != "" && env.ServicePrefix != "/" {
 		log.WithField("servicePrefix", env.ServicePrefix).Trace("applying service prefix")
 		app.Use(pprof.New(pprof.Config{Prefix: path.Clean(env.ServicePrefix)}))
 	}
 
-	oasRouter, err := swagger.NewRouter(oasfiber.NewRouter(app), swagger.Options{
-		Context: context.Background(),
-		Openapi: &openapi3.T{
-			Info: &openapi3.Info{
-				Title:   cmdName,
-				Version: utils.Version,
-			},
-		},
-		JSONDocumentationPath: "/documentations/json",
-		YAMLDocumentationPath: "/documentations/yaml",
-		PathPrefix:            env.ServicePrefix,
-	})
+	integrations, err := setupIntegrations(ctx, log, cfg, env.ServicePrefix, m, registry)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	integrations, err := setupIntegrations(ctx, log, cfg, oasRouter)
-	if err != nil {
-		return nil, err
+	router := newIntegrationsRouter(env.ServicePrefix)
+	if err := router.swap(integrations, nil); err != nil {
+		return nil, nil, err
 	}
+	app.Use(router.handler)
 
-	if err := oasRouter.GenerateAndExposeOpenapi(); err != nil {
-		return nil, err
+	reloader := &reloader{
+		ctx:          ctx,
+		log:          log,
+		env:          env,
+		metrics:      m,
+		registry:     registry,
+		router:       router,
+		integrations: integrations,
 	}
 
-	go func(integrations []*Integration) {
-		ch := ctx.Done()
-		<-ch
-
-		for _, integration := range integrations {
-			integration.Close(ctx)
-		}
-	}(integrations)
-	return app, nil
+	go func() {
+		<-ctx.Done()
+		reloader.close(ctx)
+	}()
+	return app, reloader, nil
 }
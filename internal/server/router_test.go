@@ -51,4 +51,16 @@ func TestSetupRouter(t *testing.T) {
 		require.NoError(t, readBodyError)
 		require.NotEmpty(t, string(body), "The response body should not be an empty string")
 	})
+
+	t.Run("metrics are exposed", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/-/metrics", nil)
+		response, err := app.Test(request)
+		require.NoError(t, err)
+		defer response.Body.Close()
+
+		require.Equal(t, fiber.StatusOK, response.StatusCode, "The response statusCode should be 200")
+		body, readBodyError := io.ReadAll(response.Body)
+		require.NoError(t, readBodyError)
+		require.Contains(t, string(body), "go_goroutines")
+	})
 }
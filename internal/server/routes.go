@@ -0,0 +1,164 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/mia-platform/integration-connector-agent/internal/utils"
+
+	swagger "github.com/davidebianchi/gswagger"
+	"github.com/davidebianchi/gswagger/apirouter"
+	oasfiber "github.com/davidebianchi/gswagger/support/fiber"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/gofiber/fiber/v2"
+)
+
+type userContextKey struct{}
+
+type route struct {
+	method  string
+	path    string
+	handler fiber.Handler
+}
+
+// integrationRoutes collects the routes and the OpenAPI operations added by the source of an
+// integration, so that they can be mounted again every time the integrations router is rebuilt.
+type integrationRoutes struct {
+	routes  []route
+	openapi *openapi3.T
+}
+
+// newIntegrationRouter returns the router to be passed to the source of an integration, and the
+// routes that it records.
+func newIntegrationRouter(servicePrefix string) (*swagger.Router[fiber.Handler, fiber.Router], *integrationRoutes, error) {
+	routes := &integrationRoutes{
+		openapi: &openapi3.T{
+			Info: &openapi3.Info{
+				Title:   filepath.Base(os.Args[0]),
+				Version: utils.Version,
+			},
+		},
+	}
+
+	router, err := swagger.NewRouter(apirouter.Router[fiber.Handler, fiber.Router](routes), swagger.Options{
+		Context:    context.Background(),
+		Openapi:    routes.openapi,
+		PathPrefix: servicePrefix,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return router, routes, nil
+}
+
+func (r *integrationRoutes) AddRoute(method string, path string, handler fiber.Handler) fiber.Router {
+	r.routes = append(r.routes, route{method: method, path: path, handler: handler})
+	return nil
+}
+
+func (r *integrationRoutes) SwaggerHandler(contentType string, blob []byte) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		c.Set("Content-Type", contentType)
+		return c.Send(blob)
+	}
+}
+
+func (r *integrationRoutes) TransformPathToOasPath(path string) string {
+	return apirouter.TransformPathParamsWithColon(path)
+}
+
+// integrationsRouter serves the routes of the integrations and their OpenAPI documentation.
+// The routes are mounted on an inner app that is replaced atomically when the integrations
+// are reloaded, so that each request is served either by the old or by the new integrations.
+type integrationsRouter struct {
+	servicePrefix string
+	current       atomic.Pointer[fiber.App]
+}
+
+func newIntegrationsRouter(servicePrefix string) *integrationsRouter {
+	return &integrationsRouter{servicePrefix: servicePrefix}
+}
+
+func (r *integrationsRouter) handler(c *fiber.Ctx) error {
+	app := r.current.Load()
+	if app == nil {
+		return c.Next()
+	}
+
+	// the inner app creates its own fiber context: the user context set by the
+	// middlewares of the outer app (e.g. the request logger) is passed along with the request
+	c.Context().SetUserValue(userContextKey{}, c.UserContext())
+	app.Handler()(c.Context())
+	return nil
+}
+
+// swap replaces the routes served with the ones of integrations. The routes of the integrations
+// in reloading are kept, but respond with 503 Service Unavailable, so that the callers retry
+// them once the integrations are ready again.
+func (r *integrationsRouter) swap(integrations []*Integration, reloading []*Integration) error {
+	app := fiber.New(fiber.Config{
+		DisableStartupMessage: true,
+	})
+	app.Use(func(c *fiber.Ctx) error {
+		if ctx, ok := c.Context().UserValue(userContextKey{}).(context.Context); ok {
+			c.SetUserContext(ctx)
+		}
+		return c.Next()
+	})
+
+	openapi := &openapi3.T{
+		Info: &openapi3.Info{
+			Title:   filepath.Base(os.Args[0]),
+			Version: utils.Version,
+		},
+	}
+	oasRouter, err := swagger.NewRouter(oasfiber.NewRouter(app), swagger.Options{
+		Context:               context.Background(),
+		Openapi:               openapi,
+		JSONDocumentationPath: "/documentations/json",
+		YAMLDocumentationPath: "/documentations/yaml",
+		PathPrefix:            r.servicePrefix,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, integration := range integrations {
+		integration.routes.mount(app, openapi, func(route route) fiber.Handler { return route.handler })
+	}
+	for _, integration := range reloading {
+		integration.routes.mount(app, openapi, func(route) fiber.Handler {
+			return func(c *fiber.Ctx) error {
+				return c.SendStatus(http.StatusServiceUnavailable)
+			}
+		})
+	}
+
+	if err := oasRouter.GenerateAndExposeOpenapi(); err != nil {
+		return err
+	}
+
+	r.current.Store(app)
+	return nil
+}
+
+func (r *integrationRoutes) mount(app *fiber.App, openapi *openapi3.T, handler func(route) fiber.Handler) {
+	if r == nil {
+		return
+	}
+
+	for _, route := range r.routes {
+		app.Add(route.method, route.path, handler(route))
+	}
+	for path, item := range r.openapi.Paths.Map() {
+		for method, operation := range item.Operations() {
+			openapi.AddOperation(path, method, operation)
+		}
+	}
+}
@@ -13,7 +13,9 @@ import (
 	glogrus "github.com/mia-platform/glogger/v4/loggers/logrus"
 )
 
-func New[Signal any](ctx context.Context, envVars config.EnvironmentVariables, cfg *config.Configuration, sysChannel <-chan Signal) error {
+// New starts the server, and shuts it down when a signal is received on sysChannel. Each signal
+// received on reloadChannel reloads the configuration of the integrations.
+func New[Signal any](ctx context.Context, envVars config.EnvironmentVariables, cfg *config.Configuration, sysChannel, reloadChannel <-chan Signal) error {
 	// Init logger instance.
 	ctxWithCancel, cancel := context.WithCancel(ctx)
 	log, err := glogrus.InitHelper(glogrus.InitOptions{Level: envVars.LogLevel})
@@ -21,11 +23,12 @@ func New[Signal any](ctx context.Context, envVars config.EnvironmentVariables, c
 		panic(err)
 	}
 
-	app, err := NewApp(ctxWithCancel, envVars, log, cfg)
+	app, reloader, err := newApp(ctxWithCancel, envVars, log, cfg)
 	if err != nil {
 		cancel()
 		return err
 	}
+	go watchConfiguration(ctxWithCancel, reloader, reloadChannel)
 
 	go func() {
 		log.WithField("port", envVars.HTTPPort).Info("starting server")
@@ -35,13 +38,17 @@ func New[Signal any](ctx context.Context, envVars config.EnvironmentVariables, c
 	}()
 
 	<-sysChannel
-	time.Sleep(time.Duration(envVars.DelayShutdownSeconds) * time.Second)
 	log.Info("Gracefully shutting down...")
 
-	cancel() // shutting down server, cancel the context
-	if err := app.Shutdown(); err != nil {
-		return err
-	}
+	// the whole shutdown lasts DELAY_SHUTDOWN_SECONDS: the integrations stop receiving new events and
+	// drain the ones already received, while the server keeps responding until the deadline, so that
+	// the service can be removed from the load balancers before it stops listening
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.WithoutCancel(ctx), time.Duration(envVars.DelayShutdownSeconds)*time.Second)
+	defer cancelShutdown()
+	reloader.close(shutdownCtx)
+	<-shutdownCtx.Done()
 
-	return nil
+	err = app.Shutdown()
+	cancel() // shutting down server, cancel the context
+	return err
 }
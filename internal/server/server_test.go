@@ -29,7 +29,7 @@ func TestServer(t *testing.T) {
 
 		ctx := t.Context()
 		go func() {
-			assert.NoError(t, New(ctx, envVars, cfg, shutdown))
+			assert.NoError(t, New(ctx, envVars, cfg, shutdown, nil))
 			assert.ErrorIs(t, ctx.Err(), context.Canceled)
 		}()
 
@@ -58,7 +58,7 @@ func TestServer(t *testing.T) {
 		}
 		cfg := &config.Configuration{}
 		go func() {
-			assert.NoError(t, New(t.Context(), envVars, cfg, shutdown))
+			assert.NoError(t, New(t.Context(), envVars, cfg, shutdown, nil))
 		}()
 		defer func() { shutdown <- struct{}{} }()
 
@@ -88,12 +88,15 @@ func TestShutdown(t *testing.T) {
 			LogLevel:             "error",
 			DelayShutdownSeconds: 3,
 		}
-		assert.NoError(t, New(t.Context(), envVars, cfg, shutdown))
+		assert.NoError(t, New(t.Context(), envVars, cfg, shutdown, nil))
 		done <- true
 	}()
 
+	start := time.Now()
 	shutdown <- struct{}{}
 
 	flag := <-done
 	assert.True(t, flag)
+	// the integrations are drained within the delay, not after it
+	assert.Less(t, time.Since(start), 4*time.Second)
 }
@@ -4,42 +4,46 @@
 package server
 
 import (
+	"context"
+	"net/http"
+
+	"github.com/mia-platform/integration-connector-agent/internal/health"
+
 	"github.com/gofiber/fiber/v2"
 )
 
 // statusResponse type.
 type statusResponse struct {
-	Status  string `json:"status"`
-	Name    string `json:"name"`
-	Version string `json:"version"`
+	Status     string                   `json:"status"`
+	Name       string                   `json:"name"`
+	Version    string                   `json:"version"`
+	Components []health.ComponentStatus `json:"components,omitempty"`
 }
 
-// statusRoutes add status routes to router.
-func statusRoutes(app *fiber.App, serviceName, serviceVersion string) {
-	app.Get("/-/healthz", func(c *fiber.Ctx) error {
-		status := statusResponse{
-			Status:  "OK",
-			Name:    serviceName,
-			Version: serviceVersion,
+// statusRoutes add status routes to router, each reporting the health of a different set of
+// the components in the registry, and failing if any of them is not healthy:
+//   - liveness checks the critical components, like the source consumer loops and the pipeline
+//     workers, that need a restart of the service to recover;
+//   - readiness checks the external dependencies, like the connection to the sinks;
+//   - check-up checks all the components, to report the full status of the service.
+func statusRoutes(app *fiber.App, serviceName, serviceVersion string, registry *health.Registry) {
+	componentsStatus := func(check func(context.Context) (string, []health.ComponentStatus)) fiber.Handler {
+		return func(c *fiber.Ctx) error {
+			overall, components := check(c.UserContext())
+			status := statusResponse{
+				Status:     overall,
+				Name:       serviceName,
+				Version:    serviceVersion,
+				Components: components,
+			}
+			if overall != health.StatusOK {
+				c.Status(http.StatusServiceUnavailable)
+			}
+			return c.JSON(status)
 		}
-		return c.JSON(status)
-	})
+	}
 
-	app.Get("/-/ready", func(c *fiber.Ctx) error {
-		status := statusResponse{
-			Status:  "OK",
-			Name:    serviceName,
-			Version: serviceVersion,
-		}
-		return c.JSON(status)
-	})
-
-	app.Get("/-/check-up", func(c *fiber.Ctx) error {
-		status := statusResponse{
-			Status:  "OK",
-			Name:    serviceName,
-			Version: serviceVersion,
-		}
-		return c.JSON(status)
-	})
+	app.Get("/-/healthz", componentsStatus(registry.CheckCritical))
+	app.Get("/-/ready", componentsStatus(registry.CheckDependencies))
+	app.Get("/-/check-up", componentsStatus(registry.Check))
 }
@@ -4,12 +4,16 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/mia-platform/integration-connector-agent/internal/health"
 	"github.com/mia-platform/integration-connector-agent/internal/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -20,7 +24,7 @@ func TestStatusRoutes(t *testing.T) {
 	app := fiber.New()
 	serviceName := "my-service-name"
 	serviceVersion := utils.Version
-	statusRoutes(app, serviceName, serviceVersion)
+	statusRoutes(app, serviceName, serviceVersion, nil)
 
 	t.Run("/-/healthz - ok", func(t *testing.T) {
 		expectedResponse := fmt.Sprintf("{\"status\":\"OK\",\"name\":\"%s\",\"version\":\"%s\"}", serviceName, serviceVersion)
@@ -60,4 +64,71 @@ func TestStatusRoutes(t *testing.T) {
 		require.NoError(t, readBodyError)
 		require.Equal(t, expectedResponse, string(body), "The response body should be the expected one")
 	})
+
+	t.Run("components health", func(t *testing.T) {
+		sinkHealthy, sourceHealthy := true, true
+		registry := health.NewRegistry()
+		registry.Register("my-integration/pipelines/0/sinks/0", "mongo", checkerFunc(func(context.Context) error {
+			if !sinkHealthy {
+				return errors.New("server selection timeout")
+			}
+			return nil
+		}))
+		registry.RegisterCritical("my-integration/source", "aws-sqs", checkerFunc(func(context.Context) error {
+			if !sourceHealthy {
+				return health.ErrLoopStopped
+			}
+			return nil
+		}))
+
+		app := fiber.New()
+		statusRoutes(app, serviceName, serviceVersion, registry)
+
+		get := func(t *testing.T, route string) (int, string) {
+			t.Helper()
+			response, err := app.Test(httptest.NewRequest(http.MethodGet, route, nil))
+			require.NoError(t, err)
+			defer response.Body.Close()
+			body, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			return response.StatusCode, string(body)
+		}
+
+		sinkOK := `{"name":"my-integration/pipelines/0/sinks/0","type":"mongo","status":"OK"}`
+		sinkKO := `{"name":"my-integration/pipelines/0/sinks/0","type":"mongo","status":"KO","error":"server selection timeout"}`
+		sourceOK := `{"name":"my-integration/source","type":"aws-sqs","status":"OK"}`
+		sourceKO := `{"name":"my-integration/source","type":"aws-sqs","status":"KO","error":"consumer loop is not running"}`
+		response := func(status string, components ...string) string {
+			return fmt.Sprintf(`{"status":"%s","name":"%s","version":"%s","components":[%s]}`, status, serviceName, serviceVersion, strings.Join(components, ","))
+		}
+
+		testCases := []struct {
+			route                      string
+			sinkHealthy, sourceHealthy bool
+			expectedStatusCode         int
+			expectedResponse           string
+		}{
+			{"/-/healthz", true, true, http.StatusOK, response("OK", sourceOK)},
+			{"/-/healthz", false, true, http.StatusOK, response("OK", sourceOK)},
+			{"/-/healthz", true, false, http.StatusServiceUnavailable, response("KO", sourceKO)},
+			{"/-/ready", true, true, http.StatusOK, response("OK", sinkOK)},
+			{"/-/ready", true, false, http.StatusOK, response("OK", sinkOK)},
+			{"/-/ready", false, true, http.StatusServiceUnavailable, response("KO", sinkKO)},
+			{"/-/check-up", true, true, http.StatusOK, response("OK", sinkOK, sourceOK)},
+			{"/-/check-up", false, true, http.StatusServiceUnavailable, response("KO", sinkKO, sourceOK)},
+			{"/-/check-up", true, false, http.StatusServiceUnavailable, response("KO", sinkOK, sourceKO)},
+		}
+		for _, tc := range testCases {
+			sinkHealthy, sourceHealthy = tc.sinkHealthy, tc.sourceHealthy
+			statusCode, body := get(t, tc.route)
+			require.Equal(t, tc.expectedStatusCode, statusCode, tc.route)
+			require.JSONEq(t, tc.expectedResponse, body, tc.route)
+		}
+	})
+}
+
+type checkerFunc func(ctx context.Context) error
+
+func (f checkerFunc) HealthCheck(ctx context.Context) error {
+	return f(ctx)
 }
@@ -56,6 +56,11 @@ func (t *ClientSecretBasic) SetAuthHeader(req *http.Request) error {
 	return nil
 }
 
+// EnsureToken requests a new token, unless the cached one is still valid.
+func (t *ClientSecretBasic) EnsureToken(ctx context.Context) error {
+	return t.ensureCachedToken(ctx)
+}
+
 func (t *ClientSecretBasic) ensureCachedToken(ctx context.Context) error {
 	t.lock.Lock()
 	defer t.lock.Unlock()
@@ -16,10 +16,15 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+type tokenManager interface {
+	EnsureToken(ctx context.Context) error
+}
+
 type Writer[T entities.PipelineEvent] struct {
-	config *Config
-	client consoleclient.CatalogClient[any]
-	log    *logrus.Logger
+	config       *Config
+	client       consoleclient.CatalogClient[any]
+	tokenManager tokenManager
+	log          *logrus.Logger
 }
 
 func NewWriter[T entities.PipelineEvent](config *Config, log *logrus.Logger) (sinks.Sink[T], error) {
@@ -30,9 +35,10 @@ func NewWriter[T entities.PipelineEvent](config *Config, log *logrus.Logger) (si
 
 	client := consoleclient.New[any](config.URL, tokenManager)
 	return &Writer[T]{
-		client: client,
-		config: config,
-		log:    log,
+		client:       client,
+		tokenManager: tokenManager,
+		config:       config,
+		log:          log,
 	}, nil
 }
 
@@ -114,6 +120,17 @@ func (w *Writer[T]) Close(_ context.Context) error {
 	return nil
 }
 
+// HealthCheck verifies that a token to call the Console can be acquired.
+func (w *Writer[T]) HealthCheck(ctx context.Context) error {
+	if w.tokenManager == nil {
+		return nil
+	}
+	if err := w.tokenManager.EnsureToken(ctx); err != nil {
+		return fmt.Errorf("error acquiring console token: %w", err)
+	}
+	return nil
+}
+
 func (w *Writer[T]) createCatalogItem(event T) (*consoleclient.MarketplaceResource[any], error) {
 	res := make(map[string]any)
 	if err := json.Unmarshal(event.Data(), &res); err != nil {
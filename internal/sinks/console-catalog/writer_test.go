@@ -5,6 +5,8 @@ package consolecatalog
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/mia-platform/integration-connector-agent/entities"
@@ -147,6 +149,50 @@ func TestWriteData(t *testing.T) {
 	})
 }
 
+func TestHealthCheck(t *testing.T) {
+	log, _ := test.NewNullLogger()
+
+	testCases := map[string]struct {
+		statusCode    int
+		expectedError string
+	}{
+		"token is acquired": {
+			statusCode: http.StatusOK,
+		},
+		"token cannot be acquired": {
+			statusCode:    http.StatusUnauthorized,
+			expectedError: "error acquiring console token",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tc.statusCode)
+				_, err := w.Write([]byte(`{"access_token": "token", "token_type": "Bearer", "expires_in": 3600}`))
+				require.NoError(t, err)
+			}))
+			defer server.Close()
+
+			writer, err := NewWriter[entities.PipelineEvent](&Config{
+				URL:          server.URL,
+				TenantID:     "tenant-id",
+				ClientID:     "client-id",
+				ClientSecret: "secret",
+			}, log)
+			require.NoError(t, err)
+
+			err = writer.(*Writer[entities.PipelineEvent]).HealthCheck(t.Context())
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
 type mockConsoleClient struct {
 	ApplyResult string
 	ApplyError  error
@@ -99,7 +99,8 @@ func (f *Writer) WriteData(ctx context.Context, data entities.PipelineEvent) err
 	defer f.mtx.Unlock()
 
 	f.stub = append(f.stub, Call{
-		Data:      data,
+		// the copy of the event is recorded without its ack, which is invoked by the pipeline
+		Data:      data.Clone(),
 		Operation: data.Operation(),
 	})
 
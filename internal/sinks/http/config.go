@@ -0,0 +1,106 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package httpsink
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+)
+
+var (
+	ErrWriteNotSet = errors.New("write request is required")
+	ErrURLNotSet   = errors.New("url is required")
+	ErrInvalidAuth = errors.New("invalid auth")
+)
+
+const (
+	AuthBearer = "bearer"
+	AuthBasic  = "basic"
+	AuthHMAC   = "hmac"
+
+	defaultTimeout = 10 * time.Second
+)
+
+type Config struct {
+	// Write and Delete are the requests sent for the events with the Write and Delete operation.
+	Write  *Request `json:"write"`
+	Delete *Request `json:"delete,omitempty"`
+	// Headers are the headers of the requests, whose values are mapper templates.
+	Headers map[string]string `json:"headers,omitempty"`
+	Auth    *Auth             `json:"auth,omitempty"`
+	Timeout config.Duration   `json:"timeout,omitempty"`
+	// RetryableStatusCodes are the response status codes retried besides 408, 429 and 5xx.
+	RetryableStatusCodes []int `json:"retryableStatusCodes,omitempty"`
+}
+
+type Request struct {
+	Method string `json:"method,omitempty"`
+	// URL is a mapper template, interpolated with the event.
+	URL string `json:"url"`
+	// Body is the mapper template of the request body. If empty, the body is the payload of the
+	// event for the write requests, and no body is sent for the delete ones.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+type Auth struct {
+	Type     string              `json:"type"`
+	Token    config.SecretSource `json:"token,omitempty"`
+	Username string              `json:"username,omitempty"`
+	Password config.SecretSource `json:"password,omitempty"`
+	// Secret signs the request body in the HeaderName header, with the format checked by the
+	// webhook sources: sha256=<hex encoded HMAC-SHA256>.
+	Secret     config.SecretSource `json:"secret,omitempty"`
+	HeaderName string              `json:"headerName,omitempty"`
+}
+
+func (c *Config) Validate() error {
+	if c.Write == nil {
+		return ErrWriteNotSet
+	}
+	if c.Write.URL == "" {
+		return fmt.Errorf("%w: write request", ErrURLNotSet)
+	}
+	if c.Delete != nil && c.Delete.URL == "" {
+		return fmt.Errorf("%w: delete request", ErrURLNotSet)
+	}
+	if c.Auth == nil {
+		return nil
+	}
+
+	switch c.Auth.Type {
+	case AuthBearer:
+		if c.Auth.Token == "" {
+			return fmt.Errorf("%w: token is required with %s auth", ErrInvalidAuth, AuthBearer)
+		}
+	case AuthBasic:
+		if c.Auth.Username == "" {
+			return fmt.Errorf("%w: username is required with %s auth", ErrInvalidAuth, AuthBasic)
+		}
+	case AuthHMAC:
+		if c.Auth.Secret == "" || c.Auth.HeaderName == "" {
+			return fmt.Errorf("%w: secret and headerName are required with %s auth", ErrInvalidAuth, AuthHMAC)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported type %s", ErrInvalidAuth, c.Auth.Type)
+	}
+	return nil
+}
+
+func (c *Config) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return defaultTimeout
+	}
+	return c.Timeout.Duration()
+}
+
+func (r Request) method(defaultMethod string) string {
+	if r.Method == "" {
+		return defaultMethod
+	}
+	return r.Method
+}
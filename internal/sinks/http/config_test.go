@@ -0,0 +1,87 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package httpsink
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("unmarshal json", func(t *testing.T) {
+		t.Setenv("WEBHOOK_SECRET", "secret")
+
+		cfg := Config{}
+		err := json.Unmarshal([]byte(`{
+			"write": {"method": "PUT", "url": "https://example.com/items/{{ id }}", "body": {"name": "{{ name }}"}},
+			"delete": {"url": "https://example.com/items/{{ id }}"},
+			"headers": {"X-Tenant": "{{ tenant }}"},
+			"auth": {"type": "hmac", "secret": {"fromEnv": "WEBHOOK_SECRET"}, "headerName": "X-Signature"},
+			"timeout": "5s",
+			"retryableStatusCodes": [409]
+		}`), &cfg)
+		require.NoError(t, err)
+		require.Equal(t, Config{
+			Write:                &Request{Method: "PUT", URL: "https://example.com/items/{{ id }}", Body: json.RawMessage(`{"name": "{{ name }}"}`)},
+			Delete:               &Request{URL: "https://example.com/items/{{ id }}"},
+			Headers:              map[string]string{"X-Tenant": "{{ tenant }}"},
+			Auth:                 &Auth{Type: AuthHMAC, Secret: "secret", HeaderName: "X-Signature"},
+			Timeout:              config.Duration(5 * time.Second),
+			RetryableStatusCodes: []int{409},
+		}, cfg)
+		require.Equal(t, 5*time.Second, cfg.timeout())
+		require.Equal(t, defaultTimeout, (&Config{}).timeout())
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		write := &Request{URL: "https://example.com"}
+		testCases := map[string]struct {
+			cfg           Config
+			expectedError string
+		}{
+			"valid": {
+				cfg: Config{Write: write, Auth: &Auth{Type: AuthBasic, Username: "user"}},
+			},
+			"without write request": {
+				expectedError: "write request is required",
+			},
+			"write request without url": {
+				cfg:           Config{Write: &Request{Method: "PUT"}},
+				expectedError: "url is required: write request",
+			},
+			"delete request without url": {
+				cfg:           Config{Write: write, Delete: &Request{}},
+				expectedError: "url is required: delete request",
+			},
+			"bearer auth without token": {
+				cfg:           Config{Write: write, Auth: &Auth{Type: AuthBearer}},
+				expectedError: "invalid auth: token is required with bearer auth",
+			},
+			"hmac auth without header name": {
+				cfg:           Config{Write: write, Auth: &Auth{Type: AuthHMAC, Secret: "secret"}},
+				expectedError: "invalid auth: secret and headerName are required with hmac auth",
+			},
+			"unsupported auth": {
+				cfg:           Config{Write: write, Auth: &Auth{Type: "oauth"}},
+				expectedError: "invalid auth: unsupported type oauth",
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				err := tc.cfg.Validate()
+				if tc.expectedError != "" {
+					require.EqualError(t, err, tc.expectedError)
+					return
+				}
+				require.NoError(t, err)
+			})
+		}
+	})
+}
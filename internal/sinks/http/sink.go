@@ -0,0 +1,219 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package httpsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/processors/mapper"
+	"github.com/mia-platform/integration-connector-agent/internal/sinks"
+	"github.com/mia-platform/integration-connector-agent/internal/sources/webhook/hmac"
+	"github.com/mia-platform/integration-connector-agent/internal/urltemplate"
+
+	"github.com/tidwall/gjson"
+)
+
+var (
+	ErrRequest              = errors.New("error sending the request")
+	ErrUnexpectedStatus     = errors.New("unexpected response status")
+	ErrUnsupportedOperation = errors.New("unsupported operation")
+)
+
+const (
+	urlKeyPrefix    = "url"
+	bodyKey         = "body"
+	headerKeyPrefix = "header"
+
+	// maxErrorBodySize is the maximum size of the response body reported in the errors.
+	maxErrorBodySize = 512
+)
+
+// Sink sends an HTTP request for each event, built from the event with the request of its operation.
+type Sink[T entities.PipelineEvent] struct {
+	cfg         *Config
+	client      *http.Client
+	headerNames []string
+	write       *request
+	delete      *request
+}
+
+// request is an HTTP request templated from the events.
+type request struct {
+	method string
+	url    *urltemplate.Template
+	// mapper builds the values of the URL placeholders, the header values and the body of the request.
+	mapper *mapper.Mapper
+	// withBody is set when the body is templated.
+	withBody bool
+}
+
+func (s *Sink[T]) WriteData(ctx context.Context, data T) error {
+	var req *request
+	var defaultBody []byte
+	switch data.Operation() {
+	case entities.Write:
+		req = s.write
+		defaultBody = data.Data()
+	case entities.Delete:
+		req = s.delete
+	}
+	if req == nil {
+		return fmt.Errorf("%w: %w: %s", sinks.ErrNotRetryable, ErrUnsupportedOperation, data.Operation())
+	}
+
+	output, err := req.mapper.Map(data)
+	if err != nil {
+		// the event cannot be mapped to a request
+		return fmt.Errorf("%w: %w", sinks.ErrNotRetryable, err)
+	}
+	url := req.url.Build(output)
+	body := defaultBody
+	if req.withBody {
+		body = []byte(gjson.GetBytes(output, bodyKey).Raw)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.timeout())
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %w: %w", sinks.ErrNotRetryable, ErrRequest, err)
+	}
+	for i, name := range s.headerNames {
+		httpReq.Header.Set(name, gjson.GetBytes(output, fmt.Sprintf("%s%d", headerKeyPrefix, i)).String())
+	}
+	if len(body) > 0 && httpReq.Header.Get("Content-Type") == "" {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	s.setAuth(httpReq, body)
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRequest, err)
+	}
+	defer resp.Body.Close()
+
+	return s.checkStatus(data.Operation(), url, resp)
+}
+
+// checkStatus returns an error if the response status is not successful. The errors of the
+// statuses that would not change if the request is retried wrap sinks.ErrNotRetryable.
+func (s *Sink[T]) checkStatus(operation entities.Operation, url string, resp *http.Response) error {
+	if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+	if operation == entities.Delete && resp.StatusCode == http.StatusNotFound {
+		// the entity is already deleted
+		return nil
+	}
+
+	responseBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	err := fmt.Errorf("%w: %d from %s: %s", ErrUnexpectedStatus, resp.StatusCode, url, responseBody)
+	if s.isRetryable(resp.StatusCode) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", sinks.ErrNotRetryable, err)
+}
+
+func (s *Sink[T]) isRetryable(statusCode int) bool {
+	switch {
+	case statusCode == http.StatusRequestTimeout,
+		statusCode == http.StatusTooManyRequests,
+		statusCode >= http.StatusInternalServerError:
+		return true
+	default:
+		return slices.Contains(s.cfg.RetryableStatusCodes, statusCode)
+	}
+}
+
+func (s *Sink[T]) setAuth(req *http.Request, body []byte) {
+	if s.cfg.Auth == nil {
+		return
+	}
+
+	switch s.cfg.Auth.Type {
+	case AuthBearer:
+		req.Header.Set("Authorization", "Bearer "+s.cfg.Auth.Token.String())
+	case AuthBasic:
+		req.SetBasicAuth(s.cfg.Auth.Username, s.cfg.Auth.Password.String())
+	case AuthHMAC:
+		req.Header.Set(s.cfg.Auth.HeaderName, hmac.Signature(body, s.cfg.Auth.Secret.String()))
+	}
+}
+
+func (s *Sink[T]) Close(_ context.Context) error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+func New[T entities.PipelineEvent](cfg *Config) (sinks.Sink[T], error) {
+	headerNames := make([]string, 0, len(cfg.Headers))
+	for name := range cfg.Headers {
+		headerNames = append(headerNames, name)
+	}
+	slices.Sort(headerNames)
+
+	write, err := newRequest(*cfg.Write, http.MethodPost, cfg.Headers, headerNames)
+	if err != nil {
+		return nil, fmt.Errorf("invalid write request: %w", err)
+	}
+	sink := &Sink[T]{
+		cfg:         cfg,
+		client:      &http.Client{},
+		headerNames: headerNames,
+		write:       write,
+	}
+	if cfg.Delete != nil {
+		if sink.delete, err = newRequest(*cfg.Delete, http.MethodDelete, cfg.Headers, headerNames); err != nil {
+			return nil, fmt.Errorf("invalid delete request: %w", err)
+		}
+	}
+	return sink, nil
+}
+
+func newRequest(cfg Request, defaultMethod string, headers map[string]string, headerNames []string) (*request, error) {
+	templates := map[string]json.RawMessage{}
+	url, placeholders := urltemplate.Parse(cfg.URL, urlKeyPrefix)
+	for key, placeholder := range placeholders {
+		value, err := json.Marshal(placeholder)
+		if err != nil {
+			return nil, err
+		}
+		templates[key] = value
+	}
+	for i, name := range headerNames {
+		value, err := json.Marshal(headers[name])
+		if err != nil {
+			return nil, err
+		}
+		templates[fmt.Sprintf("%s%d", headerKeyPrefix, i)] = value
+	}
+	if len(cfg.Body) > 0 {
+		templates[bodyKey] = cfg.Body
+	}
+
+	outputEvent, err := json.Marshal(templates)
+	if err != nil {
+		return nil, err
+	}
+	m, err := mapper.New(mapper.Config{OutputEvent: outputEvent})
+	if err != nil {
+		return nil, err
+	}
+
+	return &request{
+		method:   cfg.method(defaultMethod),
+		url:      url,
+		mapper:   m,
+		withBody: len(cfg.Body) > 0,
+	}, nil
+}
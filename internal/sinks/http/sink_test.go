@@ -0,0 +1,232 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package httpsink
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/sinks"
+	"github.com/mia-platform/integration-connector-agent/internal/sources/webhook/hmac"
+
+	"github.com/stretchr/testify/require"
+)
+
+type receivedRequest struct {
+	method string
+	path   string
+	query  string
+	header http.Header
+	body   string
+}
+
+func newServer(t *testing.T, statusCode int) (*httptest.Server, func() []receivedRequest) {
+	t.Helper()
+
+	var mtx sync.Mutex
+	var requests []receivedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		mtx.Lock()
+		requests = append(requests, receivedRequest{method: r.Method, path: r.URL.EscapedPath(), query: r.URL.RawQuery, header: r.Header, body: string(body)})
+		mtx.Unlock()
+
+		w.WriteHeader(statusCode)
+		w.Write([]byte(`{"message":"response"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []receivedRequest {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return requests
+	}
+}
+
+func TestSink(t *testing.T) {
+	newEvent := func(operation entities.Operation) *entities.Event {
+		return &entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "id", Value: "42"}},
+			OperationType: operation,
+			OriginalRaw:   []byte(`{"id":42,"name":"Item","tenant":"acme"}`),
+		}
+	}
+
+	t.Run("write and delete requests", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusOK)
+		cfg := &Config{
+			Write:   &Request{Method: http.MethodPut, URL: server.URL + "/items/{{ id }}", Body: json.RawMessage(`{"title":"{{ name | upper }}"}`)},
+			Delete:  &Request{URL: server.URL + "/items/{{ id }}"},
+			Headers: map[string]string{"X-Tenant": "{{ tenant }}"},
+			Auth:    &Auth{Type: AuthBearer, Token: "token"},
+		}
+		require.NoError(t, cfg.Validate())
+		sink, err := New[entities.PipelineEvent](cfg)
+		require.NoError(t, err)
+
+		require.NoError(t, sink.WriteData(t.Context(), newEvent(entities.Write)))
+		require.NoError(t, sink.WriteData(t.Context(), newEvent(entities.Delete)))
+
+		received := requests()
+		require.Len(t, received, 2)
+		require.Equal(t, http.MethodPut, received[0].method)
+		require.Equal(t, "/items/42", received[0].path)
+		require.Equal(t, `{"title":"ITEM"}`, received[0].body)
+		require.Equal(t, "acme", received[0].header.Get("X-Tenant"))
+		require.Equal(t, "Bearer token", received[0].header.Get("Authorization"))
+		require.Equal(t, "application/json", received[0].header.Get("Content-Type"))
+
+		require.Equal(t, http.MethodDelete, received[1].method)
+		require.Equal(t, "/items/42", received[1].path)
+		require.Empty(t, received[1].body)
+		require.Equal(t, "acme", received[1].header.Get("X-Tenant"))
+	})
+
+	t.Run("the url placeholders are escaped", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusOK)
+		sink, err := New[entities.PipelineEvent](&Config{
+			Write: &Request{URL: server.URL + "/items/{{ key }}?tenant={{ tenant }}"},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, sink.WriteData(t.Context(), &entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "key", Value: "a/b"}},
+			OperationType: entities.Write,
+			OriginalRaw:   []byte(`{"key":"a/b?c","tenant":"acme & co"}`),
+		}))
+
+		received := requests()
+		require.Len(t, received, 1)
+		require.Equal(t, "/items/a%2Fb%3Fc", received[0].path)
+		require.Equal(t, "tenant=acme+%26+co", received[0].query)
+	})
+
+	t.Run("the payload is the default body, signed with hmac", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusAccepted)
+		cfg := &Config{
+			Write: &Request{URL: server.URL + "/events"},
+			Auth:  &Auth{Type: AuthHMAC, Secret: "secret", HeaderName: "X-Hub-Signature-256"},
+		}
+		sink, err := New[entities.PipelineEvent](cfg)
+		require.NoError(t, err)
+
+		require.NoError(t, sink.WriteData(t.Context(), newEvent(entities.Write)))
+
+		received := requests()
+		require.Len(t, received, 1)
+		require.Equal(t, http.MethodPost, received[0].method)
+		require.Equal(t, `{"id":42,"name":"Item","tenant":"acme"}`, received[0].body)
+		require.Equal(t, hmac.Signature([]byte(received[0].body), "secret"), received[0].header.Get("X-Hub-Signature-256"))
+	})
+
+	t.Run("basic auth", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusOK)
+		sink, err := New[entities.PipelineEvent](&Config{
+			Write: &Request{URL: server.URL},
+			Auth:  &Auth{Type: AuthBasic, Username: "user", Password: "password"},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, sink.WriteData(t.Context(), newEvent(entities.Write)))
+		request := &http.Request{Header: requests()[0].header}
+		username, password, ok := request.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "user", username)
+		require.Equal(t, "password", password)
+	})
+
+	t.Run("delete without delete request", func(t *testing.T) {
+		server, requests := newServer(t, http.StatusOK)
+		sink, err := New[entities.PipelineEvent](&Config{Write: &Request{URL: server.URL}})
+		require.NoError(t, err)
+
+		err = sink.WriteData(t.Context(), newEvent(entities.Delete))
+		require.EqualError(t, err, "not retryable: unsupported operation: Delete")
+		require.ErrorIs(t, err, sinks.ErrNotRetryable)
+		require.Empty(t, requests())
+	})
+
+	t.Run("response status classification", func(t *testing.T) {
+		testCases := map[string]struct {
+			statusCode int
+			operation  entities.Operation
+
+			expectedError     string
+			expectedRetryable bool
+		}{
+			"not found on delete": {
+				statusCode: http.StatusNotFound,
+				operation:  entities.Delete,
+			},
+			"not found on write": {
+				statusCode:    http.StatusNotFound,
+				operation:     entities.Write,
+				expectedError: `not retryable: unexpected response status: 404 from {{URL}}: {"message":"response"}`,
+			},
+			"bad request": {
+				statusCode:    http.StatusBadRequest,
+				operation:     entities.Write,
+				expectedError: `not retryable: unexpected response status: 400 from {{URL}}: {"message":"response"}`,
+			},
+			"too many requests": {
+				statusCode:        http.StatusTooManyRequests,
+				operation:         entities.Write,
+				expectedError:     `unexpected response status: 429 from {{URL}}: {"message":"response"}`,
+				expectedRetryable: true,
+			},
+			"server error": {
+				statusCode:        http.StatusBadGateway,
+				operation:         entities.Write,
+				expectedError:     `unexpected response status: 502 from {{URL}}: {"message":"response"}`,
+				expectedRetryable: true,
+			},
+			"configured retryable status": {
+				statusCode:        http.StatusConflict,
+				operation:         entities.Write,
+				expectedError:     `unexpected response status: 409 from {{URL}}: {"message":"response"}`,
+				expectedRetryable: true,
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				server, _ := newServer(t, tc.statusCode)
+				sink, err := New[entities.PipelineEvent](&Config{
+					Write:                &Request{URL: server.URL},
+					Delete:               &Request{URL: server.URL},
+					RetryableStatusCodes: []int{http.StatusConflict},
+				})
+				require.NoError(t, err)
+
+				err = sink.WriteData(t.Context(), newEvent(tc.operation))
+				if tc.expectedError == "" {
+					require.NoError(t, err)
+					return
+				}
+				require.EqualError(t, err, strings.ReplaceAll(tc.expectedError, "{{URL}}", server.URL))
+				require.Equal(t, !tc.expectedRetryable, errors.Is(err, sinks.ErrNotRetryable))
+			})
+		}
+	})
+
+	t.Run("connection error is retryable", func(t *testing.T) {
+		server, _ := newServer(t, http.StatusOK)
+		server.Close()
+		sink, err := New[entities.PipelineEvent](&Config{Write: &Request{URL: server.URL}})
+		require.NoError(t, err)
+
+		err = sink.WriteData(t.Context(), newEvent(entities.Write))
+		require.ErrorIs(t, err, ErrRequest)
+		require.NotErrorIs(t, err, sinks.ErrNotRetryable)
+	})
+}
@@ -0,0 +1,65 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package kafka
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+const (
+	// ValueRaw writes the payload of the events as the message value.
+	ValueRaw = "raw"
+	// ValueEnvelope writes the payload of the events in an envelope with their operation, type,
+	// primary keys and timestamp.
+	ValueEnvelope = "envelope"
+	// ValueCloudEvents writes the events as CloudEvents in structured content mode.
+	ValueCloudEvents = "cloudevents"
+
+	defaultSource = "integration-connector-agent"
+)
+
+type Config struct {
+	ProducerConfig *kafka.ConfigMap `json:"producerConfig"`
+	Topic          string           `json:"topic"`
+
+	// Key is the mapper template of the message key, interpolated with the event envelope. If not set,
+	// the key is the SHA-256 of the primary keys.
+	Key string `json:"key,omitempty"`
+	// Value is the format of the message value: raw, the default, envelope or cloudevents.
+	Value string `json:"value,omitempty"`
+	// Source is the source attribute of the CloudEvents.
+	Source string `json:"source,omitempty"`
+	// Headers are added to the message headers, and their values are mapper templates interpolated
+	// with the event envelope.
+	Headers map[string]string `json:"headers,omitempty"`
+	// TombstoneOnDelete writes the events with the Delete operation as messages with a null value.
+	TombstoneOnDelete bool `json:"tombstoneOnDelete,omitempty"`
+}
+
+func (c *Config) Validate() error {
+	if c.ProducerConfig == nil {
+		return errors.New("producerConfig is required")
+	}
+
+	if len(c.Topic) == 0 {
+		return errors.New("topic is required")
+	}
+	switch c.Value {
+	case "", ValueRaw, ValueEnvelope, ValueCloudEvents:
+	default:
+		return fmt.Errorf("unsupported value format %s", c.Value)
+	}
+
+	return nil
+}
+
+func (c *Config) source() string {
+	if c.Source == "" {
+		return defaultSource
+	}
+	return c.Source
+}
@@ -0,0 +1,56 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package kafka
+
+import (
+	"testing"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	testCases := map[string]struct {
+		config Config
+
+		expectedError string
+	}{
+		"without producer config": {
+			config: Config{Topic: "topic"},
+
+			expectedError: "producerConfig is required",
+		},
+		"without topic": {
+			config: Config{ProducerConfig: &kafka.ConfigMap{}},
+
+			expectedError: "topic is required",
+		},
+		"unsupported value format": {
+			config: Config{ProducerConfig: &kafka.ConfigMap{}, Topic: "topic", Value: "avro"},
+
+			expectedError: "unsupported value format avro",
+		},
+		"valid config": {
+			config: Config{
+				ProducerConfig:    &kafka.ConfigMap{},
+				Topic:             "topic",
+				Key:               "{{ primaryKeys.id }}",
+				Value:             ValueCloudEvents,
+				Headers:           map[string]string{"source": "jira"},
+				TombstoneOnDelete: true,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -9,38 +9,76 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/google/uuid"
 	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/processors/mapper"
 	"github.com/mia-platform/integration-connector-agent/internal/sinks"
+	"github.com/tidwall/gjson"
 )
 
-type Config struct {
-	ProducerConfig *kafka.ConfigMap `json:"producerConfig"`
-	Topic          string           `json:"topic"`
-}
+var (
+	healthCheckTimeout = 5 * time.Second
+	flushTimeout       = 1 * time.Second
+)
 
-func (c *Config) Validate() error {
-	if c.ProducerConfig == nil {
-		return errors.New("producerConfig is required")
-	}
+const (
+	keyKey          = "key"
+	headerKeyPrefix = "header"
 
-	if len(c.Topic) == 0 {
-		return errors.New("topic is required")
-	}
-	return nil
-}
+	cloudEventsSpecVersion = "1.0"
+	cloudEventsContentType = "application/cloudevents+json; charset=UTF-8"
+)
 
 type Sink[T entities.PipelineEvent] struct {
 	producer *kafka.Producer
 	topic    string
+	cfg      *Config
+
+	// mapper builds the message key and the values of the headers, if templated.
+	mapper      *mapper.Mapper
+	headerNames []string
+	now         func() time.Time
+	newID       func() string
+}
+
+// envelope is the event with its metadata: it is the input of the templates, and the message value
+// with the envelope format.
+type envelope struct {
+	Operation   string            `json:"operation"`
+	Type        string            `json:"type"`
+	PrimaryKeys map[string]string `json:"primaryKeys"`
+	Timestamp   string            `json:"timestamp"`
+	Data        json.RawMessage   `json:"data"`
+}
+
+// cloudEvent is the message value with the cloudevents format, with the operation of the event
+// as extension attribute.
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Operation       string          `json:"operation"`
+	Data            json.RawMessage `json:"data"`
 }
 
 func New[T entities.PipelineEvent](cfg *Config) (sinks.Sink[T], error) {
+	sink, err := newSink[T](cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	p, err := kafka.NewProducer(cfg.ProducerConfig)
 	if err != nil {
 		return nil, err
 	}
+	sink.producer = p
 
 	go func() {
 		for e := range p.Events() {
@@ -66,29 +104,123 @@ func New[T entities.PipelineEvent](cfg *Config) (sinks.Sink[T], error) {
 		}
 	}()
 
-	return &Sink[T]{
-		producer: p,
-		topic:    cfg.Topic,
-	}, nil
+	return sink, nil
 }
 
-func (k *Sink[T]) WriteData(_ context.Context, data T) error {
+// newSink returns the sink without the producer.
+func newSink[T entities.PipelineEvent](cfg *Config) (*Sink[T], error) {
+	sink := &Sink[T]{
+		topic: cfg.Topic,
+		cfg:   cfg,
+		now:   time.Now,
+		newID: uuid.NewString,
+	}
+
+	templates := map[string]string{}
+	if cfg.Key != "" {
+		templates[keyKey] = cfg.Key
+	}
+	for name := range cfg.Headers {
+		sink.headerNames = append(sink.headerNames, name)
+	}
+	slices.Sort(sink.headerNames)
+	for i, name := range sink.headerNames {
+		templates[fmt.Sprintf("%s%d", headerKeyPrefix, i)] = cfg.Headers[name]
+	}
+	if len(templates) == 0 {
+		return sink, nil
+	}
+
+	outputEvent, err := json.Marshal(templates)
+	if err != nil {
+		return nil, err
+	}
+	if sink.mapper, err = mapper.New(mapper.Config{OutputEvent: outputEvent}); err != nil {
+		return nil, fmt.Errorf("invalid key or headers template: %w", err)
+	}
+	return sink, nil
+}
+
+// WriteData produces the message of the event and waits for its delivery report, so that
+// the failures of the brokers are retried by the pipeline.
+func (k *Sink[T]) WriteData(ctx context.Context, data T) error {
+	message, err := k.message(data)
+	if err != nil {
+		return err
+	}
+
+	delivery := make(chan kafka.Event, 1)
+	if err := k.producer.Produce(message, delivery); err != nil {
+		return deliveryError(fmt.Errorf("failed to produce message: %w", err))
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-delivery:
+		delivered, ok := e.(*kafka.Message)
+		if !ok {
+			return fmt.Errorf("unexpected delivery report: %s", e)
+		}
+		if err := delivered.TopicPartition.Error; err != nil {
+			return deliveryError(fmt.Errorf("failed to deliver message to topic %s: %w", k.topic, err))
+		}
+		return nil
+	}
+}
+
+// deliveryError marks as not retryable the errors of the messages that the brokers would
+// reject again, leaving the others, as a broker not available, to be retried.
+func deliveryError(err error) error {
+	var kafkaErr kafka.Error
+	if errors.As(err, &kafkaErr) && kafkaErr.Code() == kafka.ErrMsgSizeTooLarge {
+		return fmt.Errorf("%w: %w", sinks.ErrNotRetryable, err)
+	}
+	return err
+}
+
+// message returns the message of the event, with the configured key, value format and headers.
+func (k *Sink[T]) message(data T) (*kafka.Message, error) {
 	keys, err := json.Marshal(data.GetPrimaryKeys())
 	if err != nil {
-		return fmt.Errorf("failed to serialize primary keys: %w", err)
+		return nil, fmt.Errorf("failed to serialize primary keys: %w", err)
 	}
 
-	hasher := sha256.New()
-	if _, err := hasher.Write(keys); err != nil {
-		return fmt.Errorf("failed to hash primary keys: %w", err)
+	timestamp := k.now()
+	rawEnvelope, err := json.Marshal(envelope{
+		Operation:   data.Operation().String(),
+		Type:        data.GetType(),
+		PrimaryKeys: data.GetPrimaryKeys().Map(),
+		Timestamp:   timestamp.UTC().Format(time.RFC3339Nano),
+		Data:        payload(data.Data()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize envelope: %w", err)
 	}
 
-	return k.producer.Produce(&kafka.Message{
+	var templated []byte
+	if k.mapper != nil {
+		if templated, err = k.mapper.Map(&entities.Event{OriginalRaw: rawEnvelope, Type: data.GetType()}); err != nil {
+			// the event cannot be mapped to a message
+			return nil, fmt.Errorf("%w: %w", sinks.ErrNotRetryable, err)
+		}
+	}
+
+	key := []byte(gjson.GetBytes(templated, keyKey).String())
+	if k.cfg.Key == "" {
+		hasher := sha256.New()
+		if _, err := hasher.Write(keys); err != nil {
+			return nil, fmt.Errorf("failed to hash primary keys: %w", err)
+		}
+		key = hasher.Sum(nil)
+	}
+
+	message := &kafka.Message{
 		TopicPartition: kafka.TopicPartition{
 			Topic:     &k.topic,
 			Partition: kafka.PartitionAny,
 		},
-		Key: hasher.Sum(nil),
+		Key: key,
 		Headers: []kafka.Header{
 			{
 				Key:   "operation_type",
@@ -103,12 +235,78 @@ func (k *Sink[T]) WriteData(_ context.Context, data T) error {
 				Value: keys,
 			},
 		},
-		Value: data.Data(),
-	}, nil)
+	}
+	for i, name := range k.headerNames {
+		value := gjson.GetBytes(templated, fmt.Sprintf("%s%d", headerKeyPrefix, i)).String()
+		message.Headers = append(message.Headers, kafka.Header{Key: name, Value: []byte(value)})
+	}
+
+	if k.cfg.TombstoneOnDelete && data.Operation() == entities.Delete {
+		// the message has a null value, so that the entity is removed by the log compaction
+		return message, nil
+	}
+
+	switch k.cfg.Value {
+	case ValueEnvelope:
+		message.Value = rawEnvelope
+	case ValueCloudEvents:
+		message.Value, err = json.Marshal(cloudEvent{
+			SpecVersion:     cloudEventsSpecVersion,
+			ID:              k.newID(),
+			Source:          k.cfg.source(),
+			Type:            data.GetType(),
+			Time:            timestamp.UTC().Format(time.RFC3339Nano),
+			DataContentType: "application/json",
+			Operation:       data.Operation().String(),
+			Data:            payload(data.Data()),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to serialize cloud event: %w", err)
+		}
+		message.Headers = append(message.Headers, kafka.Header{Key: "content-type", Value: []byte(cloudEventsContentType)})
+	default:
+		message.Value = data.Data()
+	}
+	return message, nil
 }
 
-func (k *Sink[T]) Close(_ context.Context) error {
-	k.producer.Flush(1000) // waith max 1 second for message deliveries
+// payload returns the payload of the event as JSON: null if empty, or a string if it is not JSON.
+func payload(data []byte) json.RawMessage {
+	if len(data) == 0 {
+		return json.RawMessage("null")
+	}
+	if json.Valid(data) {
+		return data
+	}
+	encoded, _ := json.Marshal(string(data))
+	return encoded
+}
+
+// HealthCheck requests to the brokers the metadata of the topic.
+func (k *Sink[T]) HealthCheck(ctx context.Context) error {
+	timeout := healthCheckTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	if _, err := k.producer.GetMetadata(&k.topic, false, int(timeout.Milliseconds())); err != nil {
+		return fmt.Errorf("failed to get metadata of topic %s: %w", k.topic, err)
+	}
+	return nil
+}
+
+// Close waits for the delivery of the produced messages until ctx is done, or for at most
+// one second if ctx has no deadline, then closes the producer.
+func (k *Sink[T]) Close(ctx context.Context) error {
+	timeout := flushTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = max(time.Until(deadline), 0)
+	}
+
+	undelivered := k.producer.Flush(int(timeout.Milliseconds()))
 	k.producer.Close()
+	if undelivered > 0 {
+		return fmt.Errorf("%d messages not delivered before closing the producer", undelivered)
+	}
 	return nil
 }
@@ -6,11 +6,13 @@ package kafka
 import (
 	"encoding/hex"
 	"encoding/json"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
 	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/sinks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -79,3 +81,187 @@ func TestKafkaConsumer(t *testing.T) {
 		break
 	}
 }
+
+func TestKafkaDeliveryFailure(t *testing.T) {
+	topicName := "test-topic"
+	server, err := kafka.NewMockCluster(1)
+	require.NoError(t, err)
+	defer server.Close()
+	require.NoError(t, server.CreateTopic(topicName, 1, 1))
+
+	newSink := func(t *testing.T, config kafka.ConfigMap) sinks.Sink[entities.PipelineEvent] {
+		t.Helper()
+		config["bootstrap.servers"] = server.BootstrapServers()
+		sink, err := New[entities.PipelineEvent](&Config{ProducerConfig: &config, Topic: topicName})
+		require.NoError(t, err)
+		t.Cleanup(func() { sink.Close(t.Context()) })
+		return sink
+	}
+	event := &entities.Event{
+		PrimaryKeys:   entities.PkFields{{Key: "id", Value: "123"}},
+		OperationType: entities.Write,
+		OriginalRaw:   json.RawMessage(`{"key":"value"}`),
+	}
+
+	t.Run("broker not available is retryable", func(t *testing.T) {
+		require.NoError(t, server.SetBrokerDown(1))
+		t.Cleanup(func() { server.SetBrokerUp(1) })
+		sink := newSink(t, kafka.ConfigMap{"message.timeout.ms": 500})
+
+		err := sink.WriteData(t.Context(), event)
+		require.ErrorContains(t, err, "failed to deliver message to topic test-topic")
+		require.NotErrorIs(t, err, sinks.ErrNotRetryable)
+	})
+
+	t.Run("message too large is not retryable", func(t *testing.T) {
+		sink := newSink(t, kafka.ConfigMap{"message.max.bytes": 1000})
+		largeEvent := &entities.Event{
+			PrimaryKeys:   event.PrimaryKeys,
+			OperationType: entities.Write,
+			OriginalRaw:   json.RawMessage(`"` + strings.Repeat("a", 2000) + `"`),
+		}
+
+		err := sink.WriteData(t.Context(), largeEvent)
+		require.ErrorIs(t, err, sinks.ErrNotRetryable)
+	})
+}
+
+func TestMessage(t *testing.T) {
+	timestamp := time.Date(2024, 11, 6, 10, 15, 30, 0, time.UTC)
+	writeEvent := &entities.Event{
+		PrimaryKeys:   entities.PkFields{{Key: "id", Value: "123"}},
+		Type:          "jira:issue_updated",
+		OperationType: entities.Write,
+		OriginalRaw:   json.RawMessage(`{"issue":{"key":"PRJ-1"}}`),
+	}
+	deleteEvent := &entities.Event{
+		PrimaryKeys:   entities.PkFields{{Key: "id", Value: "123"}},
+		Type:          "jira:issue_deleted",
+		OperationType: entities.Delete,
+	}
+	defaultHeaders := func(event *entities.Event) []kafka.Header {
+		return []kafka.Header{
+			{Key: "operation_type", Value: []byte(event.Operation().String())},
+			{Key: "event_type", Value: []byte(event.GetType())},
+			{Key: "primary_key", Value: []byte(`[{"Key":"id","Value":"123"}]`)},
+		}
+	}
+
+	testCases := map[string]struct {
+		config *Config
+		event  *entities.Event
+
+		expectedKey     string
+		expectedValue   string
+		expectedHeaders []kafka.Header
+		expectedError   string
+	}{
+		"raw value with hashed key": {
+			config: &Config{},
+			event:  writeEvent,
+
+			expectedKey:     "a94ca16651d330029359101fafc1f9fd35413da8185dd93e1d5a80ef933a027b",
+			expectedValue:   `{"issue":{"key":"PRJ-1"}}`,
+			expectedHeaders: defaultHeaders(writeEvent),
+		},
+		"templated key and headers": {
+			config: &Config{
+				Key: "{{ data.issue.key }}",
+				Headers: map[string]string{
+					"source":    "jira",
+					"entity_id": "{{ primaryKeys.id }}",
+				},
+			},
+			event: writeEvent,
+
+			expectedKey:   "PRJ-1",
+			expectedValue: `{"issue":{"key":"PRJ-1"}}`,
+			expectedHeaders: append(defaultHeaders(writeEvent),
+				kafka.Header{Key: "entity_id", Value: []byte("123")},
+				kafka.Header{Key: "source", Value: []byte("jira")},
+			),
+		},
+		"envelope value": {
+			config: &Config{Key: "issue-{{ primaryKeys.id }}", Value: ValueEnvelope},
+			event:  writeEvent,
+
+			expectedKey:     "issue-123",
+			expectedValue:   `{"operation":"Write","type":"jira:issue_updated","primaryKeys":{"id":"123"},"timestamp":"2024-11-06T10:15:30Z","data":{"issue":{"key":"PRJ-1"}}}`,
+			expectedHeaders: defaultHeaders(writeEvent),
+		},
+		"envelope value of delete without payload": {
+			config: &Config{Key: "{{ primaryKeys.id }}", Value: ValueEnvelope},
+			event:  deleteEvent,
+
+			expectedKey:     "123",
+			expectedValue:   `{"operation":"Delete","type":"jira:issue_deleted","primaryKeys":{"id":"123"},"timestamp":"2024-11-06T10:15:30Z","data":null}`,
+			expectedHeaders: defaultHeaders(deleteEvent),
+		},
+		"cloudevents value": {
+			config: &Config{Key: "{{ primaryKeys.id }}", Value: ValueCloudEvents, Source: "/jira"},
+			event:  writeEvent,
+
+			expectedKey:   "123",
+			expectedValue: `{"specversion":"1.0","id":"event-id","source":"/jira","type":"jira:issue_updated","time":"2024-11-06T10:15:30Z","datacontenttype":"application/json","operation":"Write","data":{"issue":{"key":"PRJ-1"}}}`,
+			expectedHeaders: append(defaultHeaders(writeEvent),
+				kafka.Header{Key: "content-type", Value: []byte(cloudEventsContentType)},
+			),
+		},
+		"tombstone on delete": {
+			config: &Config{Key: "{{ primaryKeys.id }}", Value: ValueEnvelope, TombstoneOnDelete: true},
+			event:  deleteEvent,
+
+			expectedKey:     "123",
+			expectedHeaders: defaultHeaders(deleteEvent),
+		},
+		"tombstone on delete keeps the value of writes": {
+			config: &Config{TombstoneOnDelete: true},
+			event:  writeEvent,
+
+			expectedKey:     "a94ca16651d330029359101fafc1f9fd35413da8185dd93e1d5a80ef933a027b",
+			expectedValue:   `{"issue":{"key":"PRJ-1"}}`,
+			expectedHeaders: defaultHeaders(writeEvent),
+		},
+		"key template failing": {
+			config: &Config{Key: "{{ data.issue.key | toNumber }}"},
+			event:  writeEvent,
+
+			expectedError: `not retryable: error transforming data: function toNumber failed: strconv.ParseFloat: parsing "PRJ-1": invalid syntax`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tc.config.Topic = "test-topic"
+			sink, err := newSink[entities.PipelineEvent](tc.config)
+			require.NoError(t, err)
+			sink.now = func() time.Time { return timestamp }
+			sink.newID = func() string { return "event-id" }
+
+			message, err := sink.message(tc.event)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+
+			require.Equal(t, "test-topic", *message.TopicPartition.Topic)
+			if tc.config.Key == "" {
+				require.Equal(t, tc.expectedKey, hex.EncodeToString(message.Key))
+			} else {
+				require.Equal(t, tc.expectedKey, string(message.Key))
+			}
+			if tc.expectedValue == "" {
+				require.Nil(t, message.Value)
+			} else {
+				require.JSONEq(t, tc.expectedValue, string(message.Value))
+			}
+			require.Equal(t, tc.expectedHeaders, message.Headers)
+		})
+	}
+
+	t.Run("invalid template", func(t *testing.T) {
+		_, err := newSink[entities.PipelineEvent](&Config{Topic: "test-topic", Key: "{{ id | unknown }}"})
+		require.EqualError(t, err, "invalid key or headers template: error creating operation: unknown function: unknown")
+	})
+}
@@ -0,0 +1,159 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	bulkWriteTimeout = 30 * time.Second
+
+	errNotExecuted = errors.New("write not executed")
+)
+
+// bulkWriteFunc executes the writes in order, and returns the error of each of them.
+type bulkWriteFunc func(ctx context.Context, writes []batchWrite) []error
+
+// batchWrite holds the models writing an event.
+type batchWrite struct {
+	// model writes the event to the collection.
+	model mongo.WriteModel
+	// delete is set when model deletes the event, and it fails if the document is not found.
+	delete bool
+	// history appends the event to the history collection, if enabled.
+	history mongo.WriteModel
+}
+
+type pendingWrite struct {
+	write  batchWrite
+	result chan error
+}
+
+// batcher accumulates the writes of the concurrent WriteData calls, and executes them with a
+// single bulk write once maxSize writes are pending, or maxLatency after the first of them.
+// Each call waits for its write to be executed, so that the pipeline acknowledges, retries or
+// sends the event to the dead letter queue as for the other sinks: a batch holds at most a write
+// for each concurrent caller, and maxSize should not exceed them.
+type batcher struct {
+	maxSize    int
+	maxLatency time.Duration
+	write      bulkWriteFunc
+
+	mu      sync.Mutex
+	pending []pendingWrite
+	timer   *time.Timer
+}
+
+func newBatcher(cfg *BatchConfig, write bulkWriteFunc) *batcher {
+	return &batcher{
+		maxSize:    cfg.maxSize(),
+		maxLatency: cfg.maxLatency(),
+		write:      write,
+	}
+}
+
+// add enqueues the write and waits until it has been executed, returning its error.
+func (b *batcher) add(ctx context.Context, write batchWrite) error {
+	result := make(chan error, 1)
+
+	b.mu.Lock()
+	b.pending = append(b.pending, pendingWrite{write: write, result: result})
+	var batch []pendingWrite
+	switch {
+	case len(b.pending) >= b.maxSize:
+		batch = b.take()
+	case len(b.pending) == 1:
+		b.timer = time.AfterFunc(b.maxLatency, b.flush)
+	}
+	b.mu.Unlock()
+
+	if batch != nil {
+		b.execute(batch)
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flush executes the pending writes.
+func (b *batcher) flush() {
+	b.mu.Lock()
+	batch := b.take()
+	b.mu.Unlock()
+
+	if len(batch) > 0 {
+		b.execute(batch)
+	}
+}
+
+// take removes the pending writes, it must be called holding the lock.
+func (b *batcher) take() []pendingWrite {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+func (b *batcher) execute(batch []pendingWrite) {
+	// the write is not bound to the context of a single event, since it writes all of them
+	ctx, cancel := context.WithTimeout(context.Background(), bulkWriteTimeout)
+	defer cancel()
+
+	writes := make([]batchWrite, 0, len(batch))
+	for _, pending := range batch {
+		writes = append(writes, pending.write)
+	}
+
+	errs := b.write(ctx, writes)
+	for i, pending := range batch {
+		pending.result <- errs[i]
+	}
+}
+
+// bulkWriteErrors returns the error of each of the count models written in order by a bulk
+// write failed with err.
+func bulkWriteErrors(err error, count int) []error {
+	errs := make([]error, count)
+	if err == nil {
+		return errs
+	}
+
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || len(bulkErr.WriteErrors) == 0 {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	firstFailed := count
+	for _, writeErr := range bulkErr.WriteErrors {
+		if writeErr.Index < 0 || writeErr.Index >= count {
+			continue
+		}
+		errs[writeErr.Index] = writeErr
+		firstFailed = min(firstFailed, writeErr.Index)
+	}
+	// the writes are ordered, so the ones after the first failure are not executed
+	for i := firstFailed + 1; i < count; i++ {
+		if errs[i] == nil {
+			errs[i] = fmt.Errorf("%w: write %d of the batch failed", errNotExecuted, firstFailed)
+		}
+	}
+	return errs
+}
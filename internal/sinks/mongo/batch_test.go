@@ -0,0 +1,327 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestBatcher(t *testing.T) {
+	newRecorder := func(errs func(writes []batchWrite) []error) (bulkWriteFunc, func() [][]batchWrite) {
+		var mu sync.Mutex
+		var batches [][]batchWrite
+		write := func(_ context.Context, writes []batchWrite) []error {
+			mu.Lock()
+			batches = append(batches, writes)
+			mu.Unlock()
+			return errs(writes)
+		}
+		return write, func() [][]batchWrite {
+			mu.Lock()
+			defer mu.Unlock()
+			return batches
+		}
+	}
+	noErrors := func(writes []batchWrite) []error { return make([]error, len(writes)) }
+
+	t.Run("writes the batch once max size is reached", func(t *testing.T) {
+		write, batches := newRecorder(noErrors)
+		b := newBatcher(&BatchConfig{MaxSize: 3, MaxLatency: config.Duration(time.Hour)}, write)
+
+		var wg sync.WaitGroup
+		for range 3 {
+			wg.Go(func() {
+				require.NoError(t, b.add(t.Context(), batchWrite{model: mongo.NewDeleteOneModel()}))
+			})
+		}
+		wg.Wait()
+
+		require.Len(t, batches(), 1)
+		require.Len(t, batches()[0], 3)
+	})
+
+	t.Run("writes the batch once max latency is elapsed", func(t *testing.T) {
+		write, batches := newRecorder(noErrors)
+		b := newBatcher(&BatchConfig{MaxSize: 100, MaxLatency: config.Duration(10 * time.Millisecond)}, write)
+
+		require.NoError(t, b.add(t.Context(), batchWrite{model: mongo.NewDeleteOneModel()}))
+		require.Len(t, batches(), 1)
+		require.Len(t, batches()[0], 1)
+	})
+
+	t.Run("returns the error of each write", func(t *testing.T) {
+		write, _ := newRecorder(func(writes []batchWrite) []error {
+			errs := make([]error, len(writes))
+			for i, write := range writes {
+				if write.model.(*mongo.DeleteOneModel).Filter == "fail" {
+					errs[i] = errors.New("write failed")
+				}
+			}
+			return errs
+		})
+		b := newBatcher(&BatchConfig{MaxSize: 2, MaxLatency: config.Duration(time.Hour)}, write)
+
+		var wg sync.WaitGroup
+		wg.Go(func() {
+			require.NoError(t, b.add(t.Context(), batchWrite{model: mongo.NewDeleteOneModel().SetFilter("ok")}))
+		})
+		wg.Go(func() {
+			require.EqualError(t, b.add(t.Context(), batchWrite{model: mongo.NewDeleteOneModel().SetFilter("fail")}), "write failed")
+		})
+		wg.Wait()
+	})
+
+	t.Run("flush writes the pending models", func(t *testing.T) {
+		write, batches := newRecorder(noErrors)
+		b := newBatcher(&BatchConfig{MaxSize: 100, MaxLatency: config.Duration(time.Hour)}, write)
+
+		done := make(chan error)
+		go func() { done <- b.add(t.Context(), batchWrite{model: mongo.NewDeleteOneModel()}) }()
+		require.Eventually(t, func() bool {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			return len(b.pending) == 1
+		}, time.Second, time.Millisecond)
+
+		b.flush()
+		require.NoError(t, <-done)
+		require.Len(t, batches(), 1)
+	})
+
+	t.Run("returns when the context is done", func(t *testing.T) {
+		write, _ := newRecorder(noErrors)
+		b := newBatcher(&BatchConfig{MaxSize: 100, MaxLatency: config.Duration(time.Hour)}, write)
+
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, b.add(ctx, batchWrite{model: mongo.NewDeleteOneModel()}), context.DeadlineExceeded)
+	})
+}
+
+func TestBulkWriteErrors(t *testing.T) {
+	t.Run("without error", func(t *testing.T) {
+		require.Equal(t, []error{nil, nil}, bulkWriteErrors(nil, 2))
+	})
+
+	t.Run("error of the whole write is returned for each model", func(t *testing.T) {
+		err := errors.New("connection closed")
+		require.Equal(t, []error{err, err}, bulkWriteErrors(err, 2))
+	})
+
+	t.Run("models after the failed one are not executed", func(t *testing.T) {
+		writeErr := mongo.BulkWriteError{WriteError: mongo.WriteError{Index: 1, Code: 11000, Message: "duplicate key"}}
+		errs := bulkWriteErrors(mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{writeErr}}, 4)
+
+		require.NoError(t, errs[0])
+		require.Equal(t, writeErr, errs[1])
+		require.ErrorIs(t, errs[2], errNotExecuted)
+		require.EqualError(t, errs[3], "write not executed: write 1 of the batch failed")
+	})
+}
+
+func TestBatchWriteData(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	newWriter := func(mt *mtest.T) *Writer[*entities.Event] {
+		w := &Writer[*entities.Event]{
+			client:        mt.Client,
+			database:      mt.DB.Name(),
+			collection:    mt.Coll.Name(),
+			upsertIDField: "_eventId",
+		}
+		w.batcher = newBatcher(&BatchConfig{MaxSize: 3, MaxLatency: config.Duration(time.Hour)}, w.bulkWrite)
+		return w
+	}
+
+	events := []*entities.Event{
+		getTestEventUnit(t, entities.PkFields{{Key: "id", Value: "1"}}, map[string]any{"foo": "bar"}, entities.Write),
+		getTestEventUnit(t, entities.PkFields{{Key: "id", Value: "2"}}, map[string]any{"foo": "baz"}, entities.Write),
+		getTestEventUnit(t, entities.PkFields{{Key: "id", Value: "3"}}, nil, entities.Delete),
+	}
+
+	writeAll := func(w *Writer[*entities.Event]) []error {
+		errs := make([]error, len(events))
+		var wg sync.WaitGroup
+		for i, event := range events {
+			wg.Go(func() { errs[i] = w.WriteData(context.Background(), event) })
+			if i == len(events)-1 {
+				break
+			}
+			// the events are added to the batch in order, the last one fills it
+			require.Eventually(t, func() bool {
+				w.batcher.mu.Lock()
+				defer w.batcher.mu.Unlock()
+				return len(w.batcher.pending) == i+1
+			}, time.Second, time.Millisecond)
+		}
+		wg.Wait()
+		return errs
+	}
+
+	mt.Run("events are written with a bulk write", func(mt *mtest.T) {
+		w := newWriter(mt)
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+
+		require.Equal(t, []error{nil, nil, nil}, writeAll(w))
+
+		started := mt.GetAllStartedEvents()
+		require.Len(t, started, 2)
+		require.Equal(t, "update", started[0].CommandName)
+		require.Equal(t, "delete", started[1].CommandName)
+		require.True(t, started[0].Command.Lookup("ordered").Boolean())
+	})
+
+	mt.Run("failures are reported for each event", func(mt *mtest.T) {
+		w := newWriter(mt)
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 1, Code: 11000, Message: "duplicate key"}))
+
+		errs := writeAll(w)
+		require.NoError(t, errs[0])
+		require.ErrorContains(t, errs[1], "duplicate key")
+		require.ErrorIs(t, errs[2], errNotExecuted)
+	})
+
+	mt.Run("soft deletes and versions are written with bulk writes", func(mt *mtest.T) {
+		w := newWriter(mt)
+		w.softDelete = true
+		w.historyCollection = "history"
+		w.now = time.Now
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 3}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 3}),
+		)
+
+		require.Equal(t, []error{nil, nil, nil}, writeAll(w))
+
+		started := mt.GetAllStartedEvents()
+		require.Len(t, started, 2)
+		require.Equal(t, "update", started[0].CommandName)
+		updates, err := started[0].Command.Lookup("updates").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, updates, 3)
+		require.Equal(t, "insert", started[1].CommandName)
+		require.Equal(t, "history", started[1].Command.Lookup("insert").StringValue())
+		versions, err := started[1].Command.Lookup("documents").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, versions, 3)
+	})
+
+	mt.Run("failed writes are not appended to the history", func(mt *mtest.T) {
+		w := newWriter(mt)
+		w.historyCollection = "history"
+		w.now = time.Now
+		mt.AddMockResponses(
+			mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 1, Code: 11000, Message: "duplicate key"}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+		)
+
+		errs := writeAll(w)
+		require.NoError(t, errs[0])
+		require.ErrorContains(t, errs[1], "duplicate key")
+		require.ErrorIs(t, errs[2], errNotExecuted)
+
+		started := mt.GetAllStartedEvents()
+		require.Len(t, started, 2)
+		versions, err := started[1].Command.Lookup("documents").Array().Values()
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+	})
+
+	mt.Run("delete not finding the document fails", func(mt *mtest.T) {
+		w := newWriter(mt)
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 2}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}),
+		)
+
+		errs := writeAll(w)
+		require.NoError(t, errs[0])
+		require.NoError(t, errs[1])
+		require.EqualError(t, errs[2], "error deleting data: 0 documents deleted")
+	})
+
+	mt.Run("soft delete not finding the document fails", func(mt *mtest.T) {
+		w := newWriter(mt)
+		w.softDelete = true
+		w.now = time.Now
+		// the first write is upserted, the second one matches its document
+		mt.AddMockResponses(mtest.CreateSuccessResponse(
+			bson.E{Key: "n", Value: 2},
+			bson.E{Key: "nModified", Value: 1},
+			bson.E{Key: "upserted", Value: bson.A{bson.D{{Key: "index", Value: 0}, {Key: "_id", Value: "1"}}}},
+		))
+
+		errs := writeAll(w)
+		require.NoError(t, errs[0])
+		require.NoError(t, errs[1])
+		require.EqualError(t, errs[2], "error deleting data: 0 documents matched")
+	})
+
+	mt.Run("writes are split after each delete", func(mt *mtest.T) {
+		w := newWriter(mt)
+		var writes []batchWrite
+		for _, event := range []*entities.Event{events[2], events[0], events[2], events[1]} {
+			model, err := w.writeModel(event)
+			require.NoError(mt, err)
+			writes = append(writes, batchWrite{model: model, delete: event.Operation() == entities.Delete})
+		}
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}),
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		errs := w.bulkWrite(t.Context(), writes)
+		require.NoError(t, errs[0])
+		require.NoError(t, errs[1])
+		require.EqualError(t, errs[2], "error deleting data: 0 documents deleted")
+		require.NoError(t, errs[3])
+
+		started := mt.GetAllStartedEvents()
+		require.Len(t, started, 4)
+		for i, command := range []string{"delete", "update", "delete", "update"} {
+			require.Equal(t, command, started[i].CommandName)
+		}
+	})
+
+	mt.Run("writes after a failed bulk write are not executed", func(mt *mtest.T) {
+		w := newWriter(mt)
+		var writes []batchWrite
+		for _, event := range []*entities.Event{events[2], events[0]} {
+			model, err := w.writeModel(event)
+			require.NoError(mt, err)
+			writes = append(writes, batchWrite{model: model, delete: event.Operation() == entities.Delete})
+		}
+		mt.AddMockResponses(mtest.CreateWriteErrorsResponse(mtest.WriteError{Index: 0, Code: 2, Message: "bad value"}))
+
+		errs := w.bulkWrite(t.Context(), writes)
+		require.ErrorContains(t, errs[0], "bad value")
+		require.ErrorIs(t, errs[1], errNotExecuted)
+		require.Len(t, mt.GetAllStartedEvents(), 1)
+	})
+
+	mt.Run("invalid event is not added to the batch", func(mt *mtest.T) {
+		w := newWriter(mt)
+
+		err := w.WriteData(t.Context(), &entities.Event{OperationType: entities.Write, OriginalRaw: []byte(`{}`)})
+		require.EqualError(t, err, "missing primary key")
+		require.Empty(t, w.batcher.pending)
+	})
+}
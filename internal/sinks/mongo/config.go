@@ -5,6 +5,8 @@ package mongo
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/mia-platform/integration-connector-agent/internal/config"
 )
@@ -14,10 +16,45 @@ type Config struct {
 	URL        config.SecretSource `json:"url"`
 	Collection string              `json:"collection"`
 	InsertOnly bool                `json:"insertOnly"`
+	// DeleteMode sets how the events with the Delete operation are written: hard, the default,
+	// removes the document, while soft marks it as deleted.
+	DeleteMode string `json:"deleteMode,omitempty"`
+	// History enables appending every version of the events, deletes included, to a companion collection.
+	History *HistoryConfig `json:"history,omitempty"`
+	// Batch enables writing the events with bulk writes, instead of one request for each event.
+	Batch *BatchConfig `json:"batch,omitempty"`
 
 	Database string `json:"-"`
 }
 
+// HistoryConfig sets the collection where the versions of the events are appended. If not set,
+// it is the collection of the sink with the _history suffix.
+type HistoryConfig struct {
+	Collection string `json:"collection,omitempty"`
+}
+
+// BatchConfig sets when the accumulated events are written: once MaxSize events are pending,
+// or MaxLatency after the first of them has been received. MaxSize cannot exceed the workers
+// of the pipeline, and defaults to their number.
+type BatchConfig struct {
+	MaxSize    int             `json:"maxSize,omitempty"`
+	MaxLatency config.Duration `json:"maxLatency,omitempty"`
+
+	// Writers is the number of events written concurrently, one for each worker of the pipeline:
+	// since each worker waits for the write of its event, a batch holds at most an event of each.
+	Writers int `json:"-"`
+}
+
+const (
+	DeleteModeHard = "hard"
+	DeleteModeSoft = "soft"
+
+	historyCollectionSuffix = "_history"
+
+	defaultBatchMaxSize    = 100
+	defaultBatchMaxLatency = 100 * time.Millisecond
+)
+
 func (c *Config) Validate() error {
 	if c.URL == "" {
 		return errors.New("url is required")
@@ -25,6 +62,65 @@ func (c *Config) Validate() error {
 	if c.Collection == "" {
 		return errors.New("collection is required")
 	}
+	switch c.DeleteMode {
+	case "", DeleteModeHard, DeleteModeSoft:
+	default:
+		return fmt.Errorf("unsupported deleteMode %s", c.DeleteMode)
+	}
+	if c.InsertOnly && (c.DeleteMode != "" || c.History != nil) {
+		return errors.New("insertOnly cannot be used with deleteMode or history")
+	}
+	if c.History != nil && c.History.Collection == c.Collection {
+		return errors.New("history collection must be different from the collection")
+	}
+	if c.Batch != nil {
+		if c.Batch.MaxSize < 0 {
+			return errors.New("batch maxSize must be positive")
+		}
+		if c.Batch.MaxLatency < 0 {
+			return errors.New("batch maxLatency must be positive")
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) historyCollection() string {
+	if c.History == nil {
+		return ""
+	}
+	if c.History.Collection == "" {
+		return c.Collection + historyCollectionSuffix
+	}
+	return c.History.Collection
+}
 
+// SetWriters sets the number of events written concurrently, and checks that a batch can reach
+// its maxSize: since each writer waits for its event to be written, a batch cannot hold more events
+// than writers.
+func (b *BatchConfig) SetWriters(writers int) error {
+	if b.MaxSize > writers {
+		return fmt.Errorf("batch maxSize %d cannot be greater than the %d workers of the pipeline", b.MaxSize, writers)
+	}
+	b.Writers = writers
 	return nil
 }
+
+// maxSize returns the number of events written together, that defaults to the concurrent writers,
+// so that a batch is written as soon as all of them are waiting for it.
+func (b BatchConfig) maxSize() int {
+	if b.MaxSize > 0 {
+		return b.MaxSize
+	}
+	if b.Writers > 0 {
+		return b.Writers
+	}
+	return defaultBatchMaxSize
+}
+
+func (b BatchConfig) maxLatency() time.Duration {
+	if b.MaxLatency == 0 {
+		return defaultBatchMaxLatency
+	}
+	return b.MaxLatency.Duration()
+}
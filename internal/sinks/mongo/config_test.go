@@ -5,6 +5,7 @@ package mongo
 
 import (
 	"testing"
+	"time"
 
 	"github.com/mia-platform/integration-connector-agent/internal/config"
 
@@ -29,6 +30,67 @@ func TestValidateConfig(t *testing.T) {
 
 			expectedError: "collection is required",
 		},
+		"unsupported delete mode": {
+			config: Config{
+				URL:        config.SecretSource("mongodb://localhost:27017"),
+				Collection: "test",
+				DeleteMode: "archive",
+			},
+
+			expectedError: "unsupported deleteMode archive",
+		},
+		"insert only with history": {
+			config: Config{
+				URL:        config.SecretSource("mongodb://localhost:27017"),
+				Collection: "test",
+				InsertOnly: true,
+				History:    &HistoryConfig{},
+			},
+
+			expectedError: "insertOnly cannot be used with deleteMode or history",
+		},
+		"history in the same collection": {
+			config: Config{
+				URL:        config.SecretSource("mongodb://localhost:27017"),
+				Collection: "test",
+				History:    &HistoryConfig{Collection: "test"},
+			},
+
+			expectedError: "history collection must be different from the collection",
+		},
+		"valid config with soft delete and history": {
+			config: Config{
+				URL:        config.SecretSource("mongodb://localhost:27017"),
+				Collection: "test",
+				DeleteMode: DeleteModeSoft,
+				History:    &HistoryConfig{},
+			},
+		},
+		"negative batch size": {
+			config: Config{
+				URL:        config.SecretSource("mongodb://localhost:27017"),
+				Collection: "test",
+				Batch:      &BatchConfig{MaxSize: -1},
+			},
+
+			expectedError: "batch maxSize must be positive",
+		},
+		"negative batch latency": {
+			config: Config{
+				URL:        config.SecretSource("mongodb://localhost:27017"),
+				Collection: "test",
+				Batch:      &BatchConfig{MaxLatency: config.Duration(-time.Second)},
+			},
+
+			expectedError: "batch maxLatency must be positive",
+		},
+		"valid config with batch": {
+			config: Config{
+				URL:        config.SecretSource("mongodb://localhost:27017"),
+				Collection: "test",
+				Batch:      &BatchConfig{MaxSize: 500, MaxLatency: config.Duration(time.Second)},
+			},
+		},
 		"valid config": {
 			config: Config{
 				URL:        config.SecretSource("mongodb://localhost:27017"),
@@ -48,3 +110,31 @@ func TestValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestBatchConfigDefaults(t *testing.T) {
+	require.Equal(t, defaultBatchMaxSize, BatchConfig{}.maxSize())
+	require.Equal(t, defaultBatchMaxLatency, BatchConfig{}.maxLatency())
+	require.Equal(t, 10, BatchConfig{MaxSize: 10}.maxSize())
+	require.Equal(t, 2, BatchConfig{MaxSize: 2, Writers: 4}.maxSize())
+	require.Equal(t, 4, BatchConfig{Writers: 4}.maxSize())
+	require.Equal(t, time.Second, BatchConfig{MaxLatency: config.Duration(time.Second)}.maxLatency())
+}
+
+func TestBatchConfigSetWriters(t *testing.T) {
+	batch := &BatchConfig{MaxSize: 4}
+	require.NoError(t, batch.SetWriters(4))
+	require.Equal(t, 4, batch.Writers)
+
+	batch = &BatchConfig{MaxSize: 500}
+	require.EqualError(t, batch.SetWriters(1), "batch maxSize 500 cannot be greater than the 1 workers of the pipeline")
+
+	batch = &BatchConfig{}
+	require.NoError(t, batch.SetWriters(16))
+	require.Equal(t, 16, batch.maxSize())
+}
+
+func TestHistoryCollection(t *testing.T) {
+	require.Empty(t, (&Config{Collection: "issues"}).historyCollection())
+	require.Equal(t, "issues_history", (&Config{Collection: "issues", History: &HistoryConfig{}}).historyCollection())
+	require.Equal(t, "versions", (&Config{Collection: "issues", History: &HistoryConfig{Collection: "versions"}}).historyCollection())
+}
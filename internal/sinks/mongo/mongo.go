@@ -7,6 +7,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/mia-platform/integration-connector-agent/entities"
@@ -18,6 +19,15 @@ import (
 	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
 )
 
+const (
+	// deletedField and deletedAtField mark the documents deleted with the soft delete mode.
+	deletedField   = "__deleted"
+	deletedAtField = "__deletedAt"
+	// operationField and recordedAtField are set in the versions appended to the history collection.
+	operationField  = "__operation"
+	recordedAtField = "__recordedAt"
+)
+
 var (
 	mongoTimeout = 5 * time.Second
 
@@ -34,6 +44,13 @@ type Writer[T entities.PipelineEvent] struct {
 	collection    string
 	upsertIDField string
 	insertOnly    bool
+	softDelete    bool
+	// historyCollection is the collection where every version of the events is appended, if set.
+	historyCollection string
+	now               func() time.Time
+
+	// batcher is set when the events are written with bulk writes.
+	batcher *batcher
 }
 
 // NewMongoDBWriter will construct a new MongoDB writer and validate the connection parameters via a ping request.
@@ -60,16 +77,40 @@ func newMongoDBWriter[T entities.PipelineEvent](ctx context.Context, config *Con
 		return nil, fmt.Errorf("%w: %w", ErrMongoInitialization, err)
 	}
 
-	return &Writer[T]{
+	writer := &Writer[T]{
 		client:        client,
 		database:      db,
 		collection:    collection,
 		upsertIDField: "_eventId",
 		insertOnly:    config.InsertOnly,
-	}, nil
+		softDelete:    config.DeleteMode == DeleteModeSoft,
+
+		historyCollection: config.historyCollection(),
+		now:               time.Now,
+	}
+	if config.Batch != nil {
+		writer.batcher = newBatcher(config.Batch, writer.bulkWrite)
+	}
+	return writer, nil
 }
 
 func (w *Writer[T]) WriteData(ctx context.Context, data T) error {
+	if w.batcher != nil {
+		model, err := w.writeModel(data)
+		if err != nil || model == nil {
+			return err
+		}
+		write := batchWrite{model: model, delete: !w.insertOnly && data.Operation() == entities.Delete}
+		if w.historyCollection != "" {
+			history, err := w.historyDocument(data)
+			if err != nil {
+				return err
+			}
+			write.history = mongo.NewInsertOneModel().SetDocument(history)
+		}
+		return w.batcher.add(ctx, write)
+	}
+
 	if w.insertOnly {
 		return w.Insert(ctx, data)
 	}
@@ -85,24 +126,30 @@ func (w *Writer[T]) WriteData(ctx context.Context, data T) error {
 		}
 	}
 
+	if w.historyCollection != "" {
+		return w.appendHistory(ctx, data)
+	}
 	return nil
 }
 
 func (w *Writer[T]) Close(ctx context.Context) error {
+	if w.batcher != nil {
+		w.batcher.flush()
+	}
 	return w.client.Disconnect(ctx)
 }
 
+// HealthCheck pings the MongoDB instance.
+func (w *Writer[T]) HealthCheck(ctx context.Context) error {
+	return w.client.Ping(ctx, nil)
+}
+
 func (w *Writer[T]) Insert(ctx context.Context, data T) error {
 	ctxWithCancel, cancel := context.WithCancel(ctx)
 	defer cancel()
 
 	opts := options.InsertOne()
-	dataToUpsert, err := data.JSON()
-	if err != nil {
-		return err
-	}
-
-	dataToSave, err := bson.Marshal(dataToUpsert)
+	dataToSave, err := w.document(data)
 	if err != nil {
 		return err
 	}
@@ -125,19 +172,7 @@ func (w *Writer[T]) Upsert(ctx context.Context, event T) error {
 	opts := options.Replace()
 	opts.SetUpsert(true)
 
-	queryFilter, err := w.idFilter(event)
-	if err != nil {
-		return err
-	}
-
-	parsedData, err := event.JSON()
-	if err != nil {
-		return err
-	}
-
-	w.addPrimaryKeyToData(parsedData, event)
-
-	dataToSave, err := bson.Marshal(parsedData)
+	queryFilter, dataToSave, err := w.replacement(event)
 	if err != nil {
 		return err
 	}
@@ -156,11 +191,16 @@ func (w *Writer[T]) Upsert(ctx context.Context, event T) error {
 	return nil
 }
 
-// Delete implement the Writer interface
+// Delete implement the Writer interface. With the soft delete mode, the document is marked as deleted
+// instead of being removed.
 func (w *Writer[T]) Delete(ctx context.Context, data T) error {
 	ctxWithCancel, cancel := context.WithCancel(ctx)
 	defer cancel()
 
+	if w.softDelete {
+		return w.markDeleted(ctxWithCancel, data)
+	}
+
 	queryFilter, err := w.idFilter(data)
 	if err != nil {
 		return err
@@ -180,6 +220,206 @@ func (w *Writer[T]) Delete(ctx context.Context, data T) error {
 	return nil
 }
 
+func (w *Writer[T]) markDeleted(ctx context.Context, data T) error {
+	queryFilter, err := w.idFilter(data)
+	if err != nil {
+		return err
+	}
+
+	result, err := w.client.Database(w.database).
+		Collection(w.collection).
+		UpdateOne(ctx, queryFilter, w.deletedUpdate())
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount != 1 {
+		return fmt.Errorf("error deleting data: %d documents matched", result.MatchedCount)
+	}
+
+	return nil
+}
+
+// appendHistory inserts the version of the event in the history collection.
+func (w *Writer[T]) appendHistory(ctx context.Context, data T) error {
+	ctxWithCancel, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	document, err := w.historyDocument(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.client.Database(w.database).
+		Collection(w.historyCollection).
+		InsertOne(ctxWithCancel, document)
+	return err
+}
+
+// deletedUpdate returns the update marking a document as deleted.
+func (w *Writer[T]) deletedUpdate() bson.D {
+	return bson.D{{Key: "$set", Value: bson.D{
+		{Key: deletedField, Value: true},
+		{Key: deletedAtField, Value: w.now()},
+	}}}
+}
+
+// historyDocument returns the version of the event appended to the history collection: its payload,
+// if any, with its primary keys, operation and the time it has been recorded.
+func (w *Writer[T]) historyDocument(event T) ([]byte, error) {
+	parsedData := map[string]any{}
+	if event.Operation() != entities.Delete || len(event.Data()) > 0 {
+		var err error
+		if parsedData, err = event.JSON(); err != nil {
+			return nil, err
+		}
+	}
+
+	w.addPrimaryKeyToData(parsedData, event)
+	parsedData[operationField] = event.Operation().String()
+	parsedData[recordedAtField] = w.now()
+
+	return bson.Marshal(parsedData)
+}
+
+// writeModel returns the model writing the event with a bulk write, or nil if the event
+// operation is not handled.
+func (w *Writer[T]) writeModel(event T) (mongo.WriteModel, error) {
+	if w.insertOnly {
+		document, err := w.document(event)
+		if err != nil {
+			return nil, err
+		}
+		return mongo.NewInsertOneModel().SetDocument(document), nil
+	}
+
+	switch event.Operation() {
+	case entities.Write:
+		queryFilter, document, err := w.replacement(event)
+		if err != nil {
+			return nil, err
+		}
+		return mongo.NewReplaceOneModel().SetFilter(queryFilter).SetReplacement(document).SetUpsert(true), nil
+	case entities.Delete:
+		queryFilter, err := w.idFilter(event)
+		if err != nil {
+			return nil, err
+		}
+		if w.softDelete {
+			return mongo.NewUpdateOneModel().SetFilter(queryFilter).SetUpdate(w.deletedUpdate()), nil
+		}
+		return mongo.NewDeleteOneModel().SetFilter(queryFilter), nil
+	}
+	return nil, nil
+}
+
+// bulkWrite executes the writes with ordered bulk writes: the versions of the events written to the
+// collection are then appended to the history collection. The writes are split after each delete,
+// so that the documents it deleted are counted from the result of its bulk write.
+func (w *Writer[T]) bulkWrite(ctx context.Context, writes []batchWrite) []error {
+	errs := make([]error, 0, len(writes))
+	for len(errs) < len(writes) {
+		start := len(errs)
+		end := len(writes)
+		if i := slices.IndexFunc(writes[start:], func(write batchWrite) bool { return write.delete }); i >= 0 {
+			end = start + i + 1
+		}
+
+		segmentErrs, err := w.bulkWriteSegment(ctx, writes[start:end])
+		errs = append(errs, segmentErrs...)
+		if err != nil {
+			// the writes are ordered, so the ones after a failure are not executed
+			failed := start + slices.IndexFunc(segmentErrs, func(err error) bool { return err != nil })
+			for len(errs) < len(writes) {
+				errs = append(errs, fmt.Errorf("%w: write %d of the batch failed", errNotExecuted, failed))
+			}
+		}
+	}
+
+	var historyModels []mongo.WriteModel
+	var indexes []int
+	for i, write := range writes {
+		if write.history != nil && errs[i] == nil {
+			historyModels = append(historyModels, write.history)
+			indexes = append(indexes, i)
+		}
+	}
+	if len(historyModels) == 0 {
+		return errs
+	}
+
+	_, err := w.client.Database(w.database).
+		Collection(w.historyCollection).
+		BulkWrite(ctx, historyModels, options.BulkWrite().SetOrdered(true))
+	for i, historyErr := range bulkWriteErrors(err, len(historyModels)) {
+		if historyErr != nil {
+			errs[indexes[i]] = historyErr
+		}
+	}
+	return errs
+}
+
+// bulkWriteSegment executes the writes with an ordered bulk write, and returns the error of each of
+// them and the one of the bulk write. Only the last write can be a delete, which fails if it does not
+// find the document, as the Delete method.
+func (w *Writer[T]) bulkWriteSegment(ctx context.Context, writes []batchWrite) ([]error, error) {
+	models := make([]mongo.WriteModel, 0, len(writes))
+	replaces := int64(0)
+	for _, write := range writes {
+		models = append(models, write.model)
+		if _, ok := write.model.(*mongo.ReplaceOneModel); ok {
+			replaces++
+		}
+	}
+	result, err := w.client.Database(w.database).
+		Collection(w.collection).
+		BulkWrite(ctx, models, options.BulkWrite().SetOrdered(true))
+	errs := bulkWriteErrors(err, len(models))
+	if err != nil || !writes[len(writes)-1].delete {
+		return errs, err
+	}
+
+	if w.softDelete {
+		// each upserting replace either matches a document or inserts a new one
+		if matched := result.MatchedCount - (replaces - result.UpsertedCount); matched != 1 {
+			errs[len(errs)-1] = fmt.Errorf("error deleting data: %d documents matched", matched)
+		}
+	} else if result.DeletedCount != 1 {
+		errs[len(errs)-1] = fmt.Errorf("error deleting data: %d documents deleted", result.DeletedCount)
+	}
+	return errs, nil
+}
+
+// document returns the event payload encoded in BSON.
+func (w *Writer[T]) document(event T) ([]byte, error) {
+	parsedData, err := event.JSON()
+	if err != nil {
+		return nil, err
+	}
+	return bson.Marshal(parsedData)
+}
+
+// replacement returns the filter and the document upserting the event, identified by its primary keys.
+func (w *Writer[T]) replacement(event T) (bson.D, []byte, error) {
+	queryFilter, err := w.idFilter(event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parsedData, err := event.JSON()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w.addPrimaryKeyToData(parsedData, event)
+
+	dataToSave, err := bson.Marshal(parsedData)
+	if err != nil {
+		return nil, nil, err
+	}
+	return queryFilter, dataToSave, nil
+}
+
 // mongoClientOptionsFromConfig return a ClientOptions, database and collection parameters parsed from a
 // MongoDBConfig struct.
 func mongoClientOptionsFromConfig(config *Config) (*options.ClientOptions, string, string) {
@@ -265,6 +265,134 @@ func TestInsert(t *testing.T) {
 	}
 }
 
+func TestSoftDelete(t *testing.T) {
+	t.Parallel()
+	deletedAt := time.Date(2024, 11, 6, 10, 15, 30, 0, time.UTC)
+	tests := map[string]struct {
+		data        entities.PipelineEvent
+		responses   primitive.D
+		expectedErr string
+	}{
+		"mark element as deleted": {
+			data:      getEvent(t),
+			responses: mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		},
+		"error if event without id": {
+			data:        &entities.Event{},
+			expectedErr: "missing primary key",
+		},
+		"error without match": {
+			data:        getEvent(t),
+			responses:   mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 0}),
+			expectedErr: "error deleting data: 0 documents matched",
+		},
+		"mongo returns error": {
+			data:        getEvent(t),
+			responses:   mtest.CreateCommandErrorResponse(mtest.CommandError{Message: "some error"}),
+			expectedErr: "some error",
+		},
+	}
+
+	for testName, test := range tests {
+		mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+		mt.Run(testName, func(mt *mtest.T) {
+			writer := &Writer[entities.PipelineEvent]{
+				client:        mt.Client,
+				collection:    mt.Coll.Name(),
+				database:      mt.DB.Name(),
+				upsertIDField: "_eventId",
+				softDelete:    true,
+				now:           func() time.Time { return deletedAt },
+			}
+
+			mt.AddMockResponses(test.responses)
+
+			ctx, cancel := context.WithTimeout(t.Context(), 500*time.Millisecond)
+			defer cancel()
+
+			err := writer.Delete(ctx, test.data)
+			if test.expectedErr != "" {
+				require.EqualError(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			started := mt.GetStartedEvent()
+			require.Equal(t, "update", started.CommandName)
+			update := started.Command.Lookup("updates").Array().Index(0).Value().Document()
+			require.Equal(t, "12345", update.Lookup("q", "_eventId").StringValue())
+			require.True(t, update.Lookup("u", "$set", deletedField).Boolean())
+			require.Equal(t, deletedAt.UnixMilli(), update.Lookup("u", "$set", deletedAtField).DateTime())
+		})
+	}
+}
+
+func TestHistory(t *testing.T) {
+	recordedAt := time.Date(2024, 11, 6, 10, 15, 30, 0, time.UTC)
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	newWriter := func(mt *mtest.T) *Writer[entities.PipelineEvent] {
+		return &Writer[entities.PipelineEvent]{
+			client:            mt.Client,
+			collection:        mt.Coll.Name(),
+			database:          mt.DB.Name(),
+			upsertIDField:     "_eventId",
+			historyCollection: "history",
+			now:               func() time.Time { return recordedAt },
+		}
+	}
+
+	insertedVersion := func(mt *mtest.T) bson.Raw {
+		mt.Helper()
+
+		started := mt.GetAllStartedEvents()
+		require.Len(mt, started, 2)
+		require.Equal(mt, "insert", started[1].CommandName)
+		require.Equal(mt, "history", started[1].Command.Lookup("insert").StringValue())
+		return started[1].Command.Lookup("documents").Array().Index(0).Value().Document()
+	}
+
+	mt.Run("write is upserted and appended to the history", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "upserted", Value: []any{bson.D{}}}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		require.NoError(mt, newWriter(mt).WriteData(mt.Context(), getEvent(t)))
+
+		version := insertedVersion(mt)
+		require.Equal(mt, "test", version.Lookup("event").StringValue())
+		require.Equal(mt, "12345", version.Lookup("_eventId").StringValue())
+		require.Equal(mt, "Write", version.Lookup(operationField).StringValue())
+		require.Equal(mt, recordedAt.UnixMilli(), version.Lookup(recordedAtField).DateTime())
+	})
+
+	mt.Run("delete without payload is appended to the history", func(mt *mtest.T) {
+		mt.AddMockResponses(
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}),
+			mtest.CreateSuccessResponse(),
+		)
+
+		event := &entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "test", Value: "12345"}},
+			OperationType: entities.Delete,
+		}
+		require.NoError(mt, newWriter(mt).WriteData(mt.Context(), event))
+
+		version := insertedVersion(mt)
+		require.Equal(mt, "12345", version.Lookup("_eventId").StringValue())
+		require.Equal(mt, "Delete", version.Lookup(operationField).StringValue())
+	})
+
+	mt.Run("failed write is not appended to the history", func(mt *mtest.T) {
+		mt.AddMockResponses(mtest.CreateCommandErrorResponse(mtest.CommandError{Message: "some error"}))
+
+		require.EqualError(mt, newWriter(mt).WriteData(mt.Context(), getEvent(t)), "some error")
+		require.Len(mt, mt.GetAllStartedEvents(), 1)
+	})
+}
+
 func getEvent(t *testing.T) entities.PipelineEvent {
 	t.Helper()
 
@@ -0,0 +1,95 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package postgres
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mia-platform/integration-connector-agent/internal/config"
+)
+
+var (
+	ErrURLNotSet       = errors.New("url is required")
+	ErrTableNotSet     = errors.New("table is required")
+	ErrInvalidColumn   = errors.New("column requires name and path")
+	ErrDuplicateColumn = errors.New("column is set more than once")
+)
+
+const defaultDataColumn = "data"
+
+// Config contains the configuration needed to write the events to a PostgreSQL table.
+type Config struct {
+	URL config.SecretSource `json:"url"`
+	// Table is the name of the table, optionally qualified by its schema, as inventory.items.
+	Table string `json:"table"`
+	// DataColumn is the jsonb column storing the payload of the events.
+	DataColumn string `json:"dataColumn,omitempty"`
+	// KeyColumns maps the primary keys of the events to the columns storing them. The primary
+	// keys not mapped are stored in the column with their name.
+	KeyColumns map[string]string `json:"keyColumns,omitempty"`
+	// Columns are the columns set with the values extracted from the payload.
+	Columns []Column `json:"columns,omitempty"`
+
+	// InsertOnly inserts a row for each event, including the deleted ones, keeping the history
+	// of the entities instead of their last state.
+	InsertOnly bool `json:"insertOnly,omitempty"`
+	// OperationColumn is the column storing the operation of the event in insert only mode.
+	OperationColumn string `json:"operationColumn,omitempty"`
+}
+
+type Column struct {
+	Name string `json:"name"`
+	// Path is the gjson path of the value in the payload.
+	Path string `json:"path"`
+}
+
+func (c *Config) Validate() error {
+	if c.URL == "" {
+		return ErrURLNotSet
+	}
+	if c.Table == "" {
+		return ErrTableNotSet
+	}
+	for i, column := range c.Columns {
+		if column.Name == "" || column.Path == "" {
+			return fmt.Errorf("%w: column %d", ErrInvalidColumn, i)
+		}
+	}
+
+	// the columns of the primary keys not mapped are known only from the events, and checked on write
+	names := make([]string, 0, len(c.KeyColumns)+2+len(c.Columns))
+	for _, column := range c.KeyColumns {
+		names = append(names, column)
+	}
+	names = append(names, c.dataColumn())
+	if c.InsertOnly && c.OperationColumn != "" {
+		names = append(names, c.OperationColumn)
+	}
+	for _, column := range c.Columns {
+		names = append(names, column.Name)
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if seen[name] {
+			return fmt.Errorf("%w: %s", ErrDuplicateColumn, name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+func (c *Config) dataColumn() string {
+	if c.DataColumn == "" {
+		return defaultDataColumn
+	}
+	return c.DataColumn
+}
+
+func (c *Config) keyColumn(key string) string {
+	if column, ok := c.KeyColumns[key]; ok {
+		return column
+	}
+	return key
+}
@@ -0,0 +1,99 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package postgres
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("unmarshal json", func(t *testing.T) {
+		t.Setenv("POSTGRES_URL", "postgres://localhost:5432/inventory")
+
+		cfg := Config{}
+		err := json.Unmarshal([]byte(`{
+			"url": {"fromEnv": "POSTGRES_URL"},
+			"table": "inventory.items",
+			"dataColumn": "payload",
+			"keyColumns": {"id": "item_id"},
+			"columns": [{"name": "status", "path": "fields.status.name"}],
+			"insertOnly": true,
+			"operationColumn": "operation"
+		}`), &cfg)
+		require.NoError(t, err)
+		require.Equal(t, Config{
+			URL:             "postgres://localhost:5432/inventory",
+			Table:           "inventory.items",
+			DataColumn:      "payload",
+			KeyColumns:      map[string]string{"id": "item_id"},
+			Columns:         []Column{{Name: "status", Path: "fields.status.name"}},
+			InsertOnly:      true,
+			OperationColumn: "operation",
+		}, cfg)
+		require.Equal(t, "payload", cfg.dataColumn())
+		require.Equal(t, "item_id", cfg.keyColumn("id"))
+		require.Equal(t, "tenant", cfg.keyColumn("tenant"))
+		require.Equal(t, defaultDataColumn, (&Config{}).dataColumn())
+	})
+
+	t.Run("validate", func(t *testing.T) {
+		testCases := map[string]struct {
+			cfg           Config
+			expectedError string
+		}{
+			"valid": {
+				cfg: Config{URL: "postgres://localhost", Table: "items"},
+			},
+			"without url": {
+				cfg:           Config{Table: "items"},
+				expectedError: "url is required",
+			},
+			"without table": {
+				cfg:           Config{URL: "postgres://localhost"},
+				expectedError: "table is required",
+			},
+			"column without path": {
+				cfg:           Config{URL: "postgres://localhost", Table: "items", Columns: []Column{{Name: "status"}}},
+				expectedError: "column requires name and path: column 0",
+			},
+			"key column also extracted": {
+				cfg: Config{
+					URL:        "postgres://localhost",
+					Table:      "items",
+					KeyColumns: map[string]string{"id": "item_id"},
+					Columns:    []Column{{Name: "item_id", Path: "id"}},
+				},
+				expectedError: "column is set more than once: item_id",
+			},
+			"data column also extracted": {
+				cfg:           Config{URL: "postgres://localhost", Table: "items", Columns: []Column{{Name: "data", Path: "fields"}}},
+				expectedError: "column is set more than once: data",
+			},
+			"operation column also extracted": {
+				cfg: Config{
+					URL:             "postgres://localhost",
+					Table:           "items",
+					Columns:         []Column{{Name: "operation", Path: "operation"}},
+					InsertOnly:      true,
+					OperationColumn: "operation",
+				},
+				expectedError: "column is set more than once: operation",
+			},
+		}
+
+		for name, tc := range testCases {
+			t.Run(name, func(t *testing.T) {
+				err := tc.cfg.Validate()
+				if tc.expectedError != "" {
+					require.EqualError(t, err, tc.expectedError)
+					return
+				}
+				require.NoError(t, err)
+			})
+		}
+	})
+}
@@ -0,0 +1,262 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/sinks"
+
+	"github.com/jackc/pgx/v5"
+	// registers the pgx driver in database/sql
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/tidwall/gjson"
+)
+
+var (
+	postgresTimeout = 5 * time.Second
+
+	ErrPostgresInitialization = errors.New("failed to start postgres writer")
+)
+
+// Writer saves and deletes the events in a PostgreSQL table, with a row for each entity identified
+// by the primary keys of the events, or a row for each event in insert only mode.
+type Writer[T entities.PipelineEvent] struct {
+	db  *sql.DB
+	cfg *Config
+}
+
+// NewPostgresWriter connects to PostgreSQL and validates the connection with a ping.
+func NewPostgresWriter[T entities.PipelineEvent](ctx context.Context, cfg *Config) (sinks.Sink[T], error) {
+	db, err := sql.Open("pgx", cfg.URL.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrPostgresInitialization, err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, postgresTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%w: %w", ErrPostgresInitialization, err)
+	}
+
+	return newWriter[T](db, cfg), nil
+}
+
+func newWriter[T entities.PipelineEvent](db *sql.DB, cfg *Config) *Writer[T] {
+	return &Writer[T]{db: db, cfg: cfg}
+}
+
+func (w *Writer[T]) WriteData(ctx context.Context, data T) error {
+	if w.cfg.InsertOnly {
+		return w.Insert(ctx, data)
+	}
+
+	switch data.Operation() {
+	case entities.Write:
+		return w.Upsert(ctx, data)
+	case entities.Delete:
+		return w.Delete(ctx, data)
+	}
+	return nil
+}
+
+// Insert adds a row with the event. A row conflicting with a unique constraint of the table, as
+// the one of an event delivered again, is skipped.
+func (w *Writer[T]) Insert(ctx context.Context, event T) error {
+	columns, values, err := w.rowValues(event)
+	if err != nil {
+		return err
+	}
+	if w.cfg.OperationColumn != "" {
+		columns = append(columns, w.cfg.OperationColumn)
+		values = append(values, event.Operation().String())
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT DO NOTHING", w.table(), columnList(columns), placeholders(len(values)))
+	if _, err := w.db.ExecContext(ctx, query, values...); err != nil {
+		return fmt.Errorf("error inserting data: %w", err)
+	}
+	return nil
+}
+
+// Upsert inserts the row of the entity, or updates it if already present. The key columns must
+// be the primary key, or have a unique constraint.
+func (w *Writer[T]) Upsert(ctx context.Context, event T) error {
+	primaryKeys := event.GetPrimaryKeys()
+	if primaryKeys.IsEmpty() {
+		return sinks.ErrEmptyID
+	}
+
+	columns, values, err := w.rowValues(event)
+	if err != nil {
+		return err
+	}
+	updates := make([]string, 0, len(columns)-len(primaryKeys))
+	for _, column := range columns[len(primaryKeys):] {
+		updates = append(updates, fmt.Sprintf("%[1]s = EXCLUDED.%[1]s", quote(column)))
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) DO UPDATE SET %s",
+		w.table(),
+		columnList(columns),
+		placeholders(len(values)),
+		columnList(columns[:len(primaryKeys)]),
+		strings.Join(updates, ", "),
+	)
+	if _, err := w.db.ExecContext(ctx, query, values...); err != nil {
+		return fmt.Errorf("error upserting data: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the row of the entity, if present.
+func (w *Writer[T]) Delete(ctx context.Context, event T) error {
+	primaryKeys := event.GetPrimaryKeys()
+	if primaryKeys.IsEmpty() {
+		return sinks.ErrEmptyID
+	}
+
+	keyColumns, err := w.keyColumns(primaryKeys)
+	if err != nil {
+		return err
+	}
+	conditions := make([]string, 0, len(primaryKeys))
+	values := make([]any, 0, len(primaryKeys))
+	for i, primaryKey := range primaryKeys {
+		conditions = append(conditions, fmt.Sprintf("%s = $%d", quote(keyColumns[i]), i+1))
+		values = append(values, primaryKey.Value)
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", w.table(), strings.Join(conditions, " AND "))
+	if _, err := w.db.ExecContext(ctx, query, values...); err != nil {
+		return fmt.Errorf("error deleting data: %w", err)
+	}
+	return nil
+}
+
+func (w *Writer[T]) Close(_ context.Context) error {
+	return w.db.Close()
+}
+
+// HealthCheck pings the PostgreSQL instance.
+func (w *Writer[T]) HealthCheck(ctx context.Context) error {
+	return w.db.PingContext(ctx)
+}
+
+// rowValues returns the columns of the row of the event, starting with the key columns, and their values.
+// It fails if a key column is also set from the payload.
+func (w *Writer[T]) rowValues(event T) ([]string, []any, error) {
+	primaryKeys := event.GetPrimaryKeys()
+	keyColumns, err := w.keyColumns(primaryKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+	otherColumns := append([]string{w.cfg.dataColumn()}, w.columnNames()...)
+	if w.cfg.OperationColumn != "" {
+		otherColumns = append(otherColumns, w.cfg.OperationColumn)
+	}
+	for _, column := range otherColumns {
+		if slices.Contains(keyColumns, column) {
+			return nil, nil, fmt.Errorf("%w: %w: %s", sinks.ErrNotRetryable, ErrDuplicateColumn, column)
+		}
+	}
+
+	columns := make([]string, 0, len(primaryKeys)+1+len(w.cfg.Columns))
+	values := make([]any, 0, cap(columns))
+	for i, primaryKey := range primaryKeys {
+		columns = append(columns, keyColumns[i])
+		values = append(values, primaryKey.Value)
+	}
+
+	data := event.Data()
+	columns = append(columns, w.cfg.dataColumn())
+	values = append(values, payload(data))
+	for _, column := range w.cfg.Columns {
+		columns = append(columns, column.Name)
+		values = append(values, columnValue(gjson.GetBytes(data, column.Path)))
+	}
+	return columns, values, nil
+}
+
+// keyColumns returns the columns of the primary keys. It fails if two primary keys are stored in the
+// same column, as a key without a configured column and the one of a key with the same name.
+func (w *Writer[T]) keyColumns(primaryKeys entities.PkFields) ([]string, error) {
+	columns := make([]string, 0, len(primaryKeys))
+	for _, primaryKey := range primaryKeys {
+		column := w.cfg.keyColumn(primaryKey.Key)
+		if slices.Contains(columns, column) {
+			return nil, fmt.Errorf("%w: %w: %s", sinks.ErrNotRetryable, ErrDuplicateColumn, column)
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+func (w *Writer[T]) columnNames() []string {
+	names := make([]string, 0, len(w.cfg.Columns))
+	for _, column := range w.cfg.Columns {
+		names = append(names, column.Name)
+	}
+	return names
+}
+
+func (w *Writer[T]) table() string {
+	return pgx.Identifier(strings.Split(w.cfg.Table, ".")).Sanitize()
+}
+
+// columnValue converts the value extracted from the payload to the value of a column. The values
+// are bound as text, converted by PostgreSQL to the type of the column: strings are passed as they
+// are, numbers, booleans, objects and arrays as JSON, and missing values as NULL.
+func columnValue(value gjson.Result) any {
+	switch value.Type {
+	case gjson.Null:
+		return nil
+	case gjson.String:
+		return value.Str
+	default:
+		return value.Raw
+	}
+}
+
+// payload returns the payload of the event as JSON text: null if empty, or a string if it is not JSON.
+func payload(data []byte) string {
+	if len(data) == 0 {
+		return "null"
+	}
+	if json.Valid(data) {
+		return string(data)
+	}
+	encoded, _ := json.Marshal(string(data))
+	return string(encoded)
+}
+
+func quote(column string) string {
+	return pgx.Identifier{column}.Sanitize()
+}
+
+func columnList(columns []string) string {
+	quoted := make([]string, 0, len(columns))
+	for _, column := range columns {
+		quoted = append(quoted, quote(column))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// placeholders returns the list of count query parameters.
+func placeholders(count int) string {
+	params := make([]string, 0, count)
+	for i := range count {
+		params = append(params, fmt.Sprintf("$%d", i+1))
+	}
+	return strings.Join(params, ", ")
+}
@@ -0,0 +1,145 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package postgres
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/mia-platform/integration-connector-agent/entities"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter(t *testing.T) {
+	event := func(operation entities.Operation) entities.PipelineEvent {
+		return &entities.Event{
+			PrimaryKeys:   entities.PkFields{{Key: "id", Value: "42"}, {Key: "tenant", Value: "acme"}},
+			OperationType: operation,
+			OriginalRaw:   []byte(`{"id":42,"fields":{"status":"done","points":3,"ratio":0.5,"blocked":false,"labels":["a"]}}`),
+		}
+	}
+	columns := []Column{
+		{Name: "status", Path: "fields.status"},
+		{Name: "points", Path: "fields.points"},
+		{Name: "ratio", Path: "fields.ratio"},
+		{Name: "blocked", Path: "fields.blocked"},
+		{Name: "labels", Path: "fields.labels"},
+		{Name: "assignee", Path: "fields.assignee"},
+	}
+
+	testCases := map[string]struct {
+		cfg   *Config
+		event entities.PipelineEvent
+
+		expectedQuery string
+		expectedArgs  []driver.Value
+		expectedError string
+	}{
+		"upsert": {
+			cfg:           &Config{Table: "inventory.items", KeyColumns: map[string]string{"id": "item_id"}},
+			event:         event(entities.Write),
+			expectedQuery: `INSERT INTO "inventory"."items" ("item_id", "tenant", "data") VALUES ($1, $2, $3) ON CONFLICT ("item_id", "tenant") DO UPDATE SET "data" = EXCLUDED."data"`,
+			expectedArgs:  []driver.Value{"42", "acme", `{"id":42,"fields":{"status":"done","points":3,"ratio":0.5,"blocked":false,"labels":["a"]}}`},
+		},
+		"upsert with extracted columns": {
+			cfg:   &Config{Table: "items", DataColumn: "payload", Columns: columns},
+			event: event(entities.Write),
+			expectedQuery: `INSERT INTO "items" ("id", "tenant", "payload", "status", "points", "ratio", "blocked", "labels", "assignee") ` +
+				`VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) ON CONFLICT ("id", "tenant") DO UPDATE SET "payload" = EXCLUDED."payload", ` +
+				`"status" = EXCLUDED."status", "points" = EXCLUDED."points", "ratio" = EXCLUDED."ratio", "blocked" = EXCLUDED."blocked", ` +
+				`"labels" = EXCLUDED."labels", "assignee" = EXCLUDED."assignee"`,
+			expectedArgs: []driver.Value{"42", "acme", `{"id":42,"fields":{"status":"done","points":3,"ratio":0.5,"blocked":false,"labels":["a"]}}`,
+				"done", "3", "0.5", "false", `["a"]`, nil},
+		},
+		"delete": {
+			cfg:           &Config{Table: "items", KeyColumns: map[string]string{"id": "item_id"}},
+			event:         event(entities.Delete),
+			expectedQuery: `DELETE FROM "items" WHERE "item_id" = $1 AND "tenant" = $2`,
+			expectedArgs:  []driver.Value{"42", "acme"},
+		},
+		"insert only": {
+			cfg:           &Config{Table: "items_history", InsertOnly: true, OperationColumn: "operation"},
+			event:         event(entities.Delete),
+			expectedQuery: `INSERT INTO "items_history" ("id", "tenant", "data", "operation") VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`,
+			expectedArgs:  []driver.Value{"42", "acme", `{"id":42,"fields":{"status":"done","points":3,"ratio":0.5,"blocked":false,"labels":["a"]}}`, "Delete"},
+		},
+		"key column also extracted": {
+			cfg:           &Config{Table: "items", Columns: []Column{{Name: "id", Path: "id"}}},
+			event:         event(entities.Write),
+			expectedError: "not retryable: column is set more than once: id",
+		},
+		"empty payload": {
+			cfg:           &Config{Table: "items"},
+			event:         &entities.Event{PrimaryKeys: entities.PkFields{{Key: "id", Value: "42"}}, OperationType: entities.Write},
+			expectedQuery: `INSERT INTO "items" ("id", "data") VALUES ($1, $2) ON CONFLICT ("id") DO UPDATE SET "data" = EXCLUDED."data"`,
+			expectedArgs:  []driver.Value{"42", "null"},
+		},
+		"payload not json": {
+			cfg:           &Config{Table: "items"},
+			event:         &entities.Event{PrimaryKeys: entities.PkFields{{Key: "id", Value: "42"}}, OperationType: entities.Write, OriginalRaw: []byte(`plain "text"`)},
+			expectedQuery: `INSERT INTO "items" ("id", "data") VALUES ($1, $2) ON CONFLICT ("id") DO UPDATE SET "data" = EXCLUDED."data"`,
+			expectedArgs:  []driver.Value{"42", `"plain \"text\""`},
+		},
+		"key column also used by an unmapped primary key": {
+			cfg:           &Config{Table: "items", KeyColumns: map[string]string{"id": "tenant"}},
+			event:         event(entities.Write),
+			expectedError: "not retryable: column is set more than once: tenant",
+		},
+		"delete with key column also used by an unmapped primary key": {
+			cfg:           &Config{Table: "items", KeyColumns: map[string]string{"id": "tenant"}},
+			event:         event(entities.Delete),
+			expectedError: "not retryable: column is set more than once: tenant",
+		},
+		"upsert without primary keys": {
+			cfg:           &Config{Table: "items"},
+			event:         &entities.Event{OperationType: entities.Write, OriginalRaw: []byte(`{}`)},
+			expectedError: "id is empty",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			w := newWriter[entities.PipelineEvent](db, tc.cfg)
+
+			if tc.expectedQuery != "" {
+				mock.ExpectExec(tc.expectedQuery).WithArgs(tc.expectedArgs...).WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			err = w.WriteData(t.Context(), tc.event)
+			if tc.expectedError != "" {
+				require.EqualError(t, err, tc.expectedError)
+			} else {
+				require.NoError(t, err)
+			}
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("query error", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		w := newWriter[entities.PipelineEvent](db, &Config{Table: "items"})
+
+		mock.ExpectExec("INSERT INTO").WillReturnError(errors.New("connection refused"))
+		err = w.WriteData(t.Context(), event(entities.Write))
+		require.EqualError(t, err, "error upserting data: connection refused")
+	})
+
+	t.Run("health check and close", func(t *testing.T) {
+		db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+		require.NoError(t, err)
+		w := newWriter[entities.PipelineEvent](db, &Config{Table: "items"})
+
+		mock.ExpectPing()
+		require.NoError(t, w.HealthCheck(t.Context()))
+		mock.ExpectClose()
+		require.NoError(t, w.Close(t.Context()))
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
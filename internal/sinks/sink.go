@@ -12,6 +12,9 @@ import (
 
 var (
 	ErrEmptyID = errors.New("id is empty")
+	// ErrNotRetryable is wrapped by the errors of the writes that would fail again if retried,
+	// as the requests rejected by the destination.
+	ErrNotRetryable = errors.New("not retryable")
 )
 
 type DataWithIdentifier interface {
@@ -35,6 +38,8 @@ const (
 	CRUDService    = "crud-service"
 	ConsoleCatalog = "console-catalog"
 	Kafka          = "kafka"
+	HTTP           = "http"
+	Postgres       = "postgres"
 
 	// Fake is a fake writer used for testing purposes
 	Fake = "fake"
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/mia-platform/integration-connector-agent/entities"
 	"github.com/mia-platform/integration-connector-agent/internal/processors/cloud-vendor-aggregator/commons"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -17,6 +18,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/sirupsen/logrus"
 )
 
@@ -104,35 +106,45 @@ func (s *concrete) Listen(ctx context.Context, handler ListenerFunc) error {
 		}).Debug("received messages from SQS")
 
 		for _, message := range result.Messages {
-			if err := handler(ctx, []byte(*message.Body)); err != nil {
+			if err := handler(ctx, []byte(*message.Body), s.ackMessage(ctx, message)); err != nil {
 				s.log.WithFields(logrus.Fields{
 					"queueUrl":  s.config.QueueURL,
 					"messageId": message.MessageId,
 				}).WithError(err).Warn("error processing message")
 				continue
 			}
+		}
+	}
+}
 
-			s.log.WithFields(logrus.Fields{
-				"queueUrl":  s.config.QueueURL,
-				"messageId": message.MessageId,
-			}).Debug("message processed successfully")
-			_, err := s.sqs.DeleteMessage(ctx, &sqs.DeleteMessageInput{
-				QueueUrl:      &s.config.QueueURL,
-				ReceiptHandle: message.ReceiptHandle,
-			})
-			if err != nil {
-				s.log.WithFields(logrus.Fields{
-					"queueUrl":  s.config.QueueURL,
-					"messageId": message.MessageId,
-				}).Warn("error deleting message from queue, it may be processed again later")
-				continue
-			}
+// ackMessage returns the ack callback of the message, which deletes it from the queue once
+// it has been processed successfully. A message not deleted is delivered again once its
+// visibility timeout expires.
+func (s *concrete) ackMessage(ctx context.Context, message types.Message) entities.AckFunc {
+	// the message can be acked after the listener has been stopped
+	ctx = context.WithoutCancel(ctx)
+
+	return func(err error) {
+		logger := s.log.WithFields(logrus.Fields{
+			"queueUrl":  s.config.QueueURL,
+			"messageId": message.MessageId,
+		})
+		if err != nil {
+			logger.WithError(err).Warn("message not processed, it will be delivered again after the visibility timeout")
+			return
+		}
 
-			s.log.WithFields(logrus.Fields{
-				"queueUrl":  s.config.QueueURL,
-				"messageId": message.MessageId,
-			}).Debug("message deleted successfully")
+		logger.Debug("message processed successfully")
+		_, err = s.sqs.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      &s.config.QueueURL,
+			ReceiptHandle: message.ReceiptHandle,
+		})
+		if err != nil {
+			logger.WithError(err).Warn("error deleting message from queue, it may be processed again later")
+			return
 		}
+
+		logger.Debug("message deleted successfully")
 	}
 }
 
@@ -6,6 +6,8 @@ package awsclient
 import (
 	"context"
 
+	"github.com/mia-platform/integration-connector-agent/entities"
+
 	"github.com/mia-platform/integration-connector-agent/internal/processors/cloud-vendor-aggregator/commons"
 )
 
@@ -14,7 +16,9 @@ const (
 	LambdaEventSource = "lambda.amazonaws.com"
 )
 
-type ListenerFunc func(ctx context.Context, data []byte) error
+// ListenerFunc handles a message received from the queue. The message is acknowledged only
+// once ack is invoked without error: on error, or if the handler fails, it is delivered again.
+type ListenerFunc func(ctx context.Context, data []byte, ack entities.AckFunc) error
 
 type AWS interface {
 	GetBucketTags(ctx context.Context, bucketName string) (commons.Tags, error)
@@ -11,6 +11,7 @@ import (
 	"net/http"
 
 	"github.com/mia-platform/integration-connector-agent/internal/config"
+	"github.com/mia-platform/integration-connector-agent/internal/health"
 	"github.com/mia-platform/integration-connector-agent/internal/pipeline"
 	"github.com/mia-platform/integration-connector-agent/internal/sources"
 	"github.com/mia-platform/integration-connector-agent/internal/sources/aws-sqs/awsclient"
@@ -132,6 +133,14 @@ func (s *CloudTrailSource) Close() error {
 	return nil
 }
 
+// HealthCheck reports whether the source is still receiving messages from SQS.
+func (s *CloudTrailSource) HealthCheck(ctx context.Context) error {
+	if s.sqs == nil {
+		return health.ErrLoopStopped
+	}
+	return s.sqs.HealthCheck(ctx)
+}
+
 func (s *CloudTrailSource) registerImportWebhook() error {
 	apiPath := s.config.WebhookPath
 
@@ -7,6 +7,7 @@ import (
 	"context"
 
 	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/health"
 	"github.com/mia-platform/integration-connector-agent/internal/pipeline"
 	"github.com/mia-platform/integration-connector-agent/internal/sources/aws-sqs/awsclient"
 
@@ -17,6 +18,7 @@ type sqsConsumer struct {
 	pipeline pipeline.IPipelineGroup
 	log      *logrus.Logger
 	client   awsclient.AWS
+	loop     *health.Loop
 }
 
 func newSQS(
@@ -26,8 +28,9 @@ func newSQS(
 	eventBuilder entities.EventBuilder,
 	client awsclient.AWS,
 ) *sqsConsumer {
-	go func(ctx context.Context, log *logrus.Logger, client awsclient.AWS) {
-		err := client.Listen(ctx, func(ctx context.Context, data []byte) error {
+	loop := &health.Loop{}
+	loop.Go(func() error {
+		err := client.Listen(ctx, func(ctx context.Context, data []byte, ack entities.AckFunc) error {
 			event, err := eventBuilder.GetPipelineEvent(ctx, data)
 			if err != nil {
 				return err
@@ -37,6 +40,7 @@ func newSQS(
 				"eventPrimaryKeys": event.GetPrimaryKeys(),
 			}).Debug("received event from AWS SQS queue")
 
+			event.WithAck(ack)
 			pipeline.AddMessage(event)
 			return nil
 		})
@@ -45,15 +49,22 @@ func newSQS(
 		}
 
 		client.Close()
-	}(ctx, log, client)
+		return err
+	})
 
 	return &sqsConsumer{
 		pipeline: pipeline,
 		log:      log,
 		client:   client,
+		loop:     loop,
 	}
 }
 
 func (a *sqsConsumer) Close() error {
 	return a.client.Close()
 }
+
+// HealthCheck reports whether the consumer is still listening to the queue.
+func (a *sqsConsumer) HealthCheck(ctx context.Context) error {
+	return a.loop.HealthCheck(ctx)
+}
@@ -8,9 +8,12 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/mia-platform/integration-connector-agent/entities"
 	"github.com/mia-platform/integration-connector-agent/internal/azure"
 	"github.com/mia-platform/integration-connector-agent/internal/config"
+	"github.com/mia-platform/integration-connector-agent/internal/health"
 	"github.com/mia-platform/integration-connector-agent/internal/pipeline"
+	"github.com/mia-platform/integration-connector-agent/internal/sources"
 	azureeventhub "github.com/mia-platform/integration-connector-agent/internal/sources/azure-event-hub"
 	"github.com/mia-platform/integration-connector-agent/internal/sources/webhook/hmac"
 	"github.com/mia-platform/integration-connector-agent/internal/utils"
@@ -41,22 +44,23 @@ func (c *Config) checkSignature(ctx *fiber.Ctx) error {
 	return c.Authentication.CheckSignature(ctx)
 }
 
-func AddSource(ctx context.Context, cfg config.GenericConfig, pg pipeline.IPipelineGroup, logger *logrus.Logger, router *swagger.Router[fiber.Handler, fiber.Router]) error {
+// Source consumes the activity logs from the Event Hub.
+type Source struct {
+	cancel context.CancelFunc
+	loop   *health.Loop
+}
+
+func AddSource(ctx context.Context, cfg config.GenericConfig, pg pipeline.IPipelineGroup, logger *logrus.Logger, router *swagger.Router[fiber.Handler, fiber.Router]) (sources.CloseableSource, error) {
 	config, err := configFromGeneric(cfg, pg, logger)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	pg.Start(ctx)
-	go func(ctx context.Context, config azure.EventHubConfig, logger *logrus.Logger) {
-		azureeventhub.SetupEventHub(ctx, config, logger)
-	}(ctx, config.EventHubConfig, logger)
-
 	if len(config.WebhookPath) > 0 {
 		logger.WithField("webhookPath", config.WebhookPath).Info("Registering import webhook")
 		client, err := azure.NewGraphClient(config.AuthConfig)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		_, err = router.AddRoute(
 			http.MethodPost,
@@ -65,13 +69,31 @@ func AddSource(ctx context.Context, cfg config.GenericConfig, pg pipeline.IPipel
 			swagger.Definitions{},
 		)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
+	pg.Start(ctx)
+
+	consumerCtx, cancel := context.WithCancel(ctx)
+	s := &Source{cancel: cancel, loop: &health.Loop{}}
+	s.loop.Go(func() error {
+		return azureeventhub.SetupEventHub(consumerCtx, config.EventHubConfig, logger)
+	})
+	return s, nil
+}
+
+// Close stops consuming the Event Hub.
+func (s *Source) Close() error {
+	s.cancel()
 	return nil
 }
 
+// HealthCheck reports whether the source is still consuming the Event Hub.
+func (s *Source) HealthCheck(ctx context.Context) error {
+	return s.loop.HealthCheck(ctx)
+}
+
 func configFromGeneric(cfg config.GenericConfig, pg pipeline.IPipelineGroup, logger *logrus.Logger) (*Config, error) {
 	sourceCfg, err := config.GetConfig[*Config](cfg)
 	if err != nil {
@@ -83,19 +105,29 @@ func configFromGeneric(cfg config.GenericConfig, pg pipeline.IPipelineGroup, log
 }
 
 func activityLogConsumer(pg pipeline.IPipelineGroup, logger *logrus.Logger) azure.EventConsumer {
-	return func(eventData *azeventhubs.ReceivedEventData) error {
+	return func(eventData *azeventhubs.ReceivedEventData, ack entities.AckFunc) error {
 		activityLogEventData := new(azure.ActivityLogEventData)
 		if err := json.Unmarshal(eventData.Body, activityLogEventData); err != nil {
 			logger.WithError(err).Error("failed to unmarshal activity log event data")
+			// the event cannot be processed, do not receive it again
+			ack(nil)
 			return nil
 		}
 
+		events := make([]entities.PipelineEvent, 0, len(activityLogEventData.Records))
 		for _, record := range activityLogEventData.Records {
 			if event := azure.EventFromRecord(record); event != nil {
-				pg.AddMessage(event)
+				events = append(events, event)
 			}
 		}
 
+		// the Event Hub event is acked once all its records have been processed
+		acks := entities.SplitAck(ack, len(events))
+		for i, event := range events {
+			event.WithAck(acks[i])
+			pg.AddMessage(event)
+		}
+
 		return nil
 	}
 }
@@ -294,7 +294,11 @@ func TestActivityLogConsumer(t *testing.T) {
 			pg := &testPipelineGroup{}
 			consumerFunction := activityLogConsumer(pg, log)
 
-			err := consumerFunction(test.eventData)
+			acked := 0
+			err := consumerFunction(test.eventData, func(err error) {
+				assert.NoError(t, err)
+				acked++
+			})
 			if test.expectedError {
 				assert.Error(t, err)
 				assert.Nil(t, pg.Messages)
@@ -302,6 +306,14 @@ func TestActivityLogConsumer(t *testing.T) {
 			}
 
 			assert.NoError(t, err)
+
+			// the event is acked only once all its records have been processed
+			for _, message := range pg.Messages {
+				assert.Zero(t, acked)
+				message.Ack(nil)
+				message.WithAck(nil)
+			}
+			assert.Equal(t, 1, acked)
 			assert.Equal(t, test.expectedMessages, pg.Messages)
 		})
 	}
@@ -13,7 +13,8 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func SetupEventHub(ctx context.Context, config azure.EventHubConfig, logger *logrus.Logger) {
+// SetupEventHub consumes the Event Hub until ctx is cancelled, or the consumer fails.
+func SetupEventHub(ctx context.Context, config azure.EventHubConfig, logger *logrus.Logger) error {
 	consumerClient, err := eventhub.NewConsumerClient(config, azeventhubs.DefaultConsumerGroup)
 	if err != nil {
 		logger.WithError(err).Error("error initializing azure event hub consumer client")
@@ -22,7 +23,7 @@ func SetupEventHub(ctx context.Context, config azure.EventHubConfig, logger *log
 			"2. Ensure service principal has 'Azure Event Hubs Data Receiver' role\n" +
 			"3. Check Azure credentials (AZURE_TENANT_ID, AZURE_CLIENT_ID, AZURE_CLIENT_SECRET)\n" +
 			"4. Confirm Event Hub exists and is accessible")
-		return
+		return err
 	}
 	defer consumerClient.Close(ctx)
 
@@ -33,5 +34,7 @@ func SetupEventHub(ctx context.Context, config azure.EventHubConfig, logger *log
 			"2. Ensure service principal has 'Storage Blob Data Contributor' role on storage account\n" +
 			"3. Check that the checkpoint container exists in the storage account\n" +
 			"4. Verify network connectivity to Azure services")
+		return err
 	}
+	return nil
 }
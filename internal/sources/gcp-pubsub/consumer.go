@@ -7,6 +7,7 @@ import (
 	"context"
 
 	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/health"
 	"github.com/mia-platform/integration-connector-agent/internal/pipeline"
 	"github.com/mia-platform/integration-connector-agent/internal/sources/gcp-pubsub/gcpclient"
 
@@ -17,6 +18,7 @@ type pubsubConsumer struct {
 	pipeline pipeline.IPipelineGroup
 	log      *logrus.Logger
 	client   gcpclient.GCP
+	loop     *health.Loop
 }
 
 func newPubSub(
@@ -26,13 +28,15 @@ func newPubSub(
 	eventBuilder entities.EventBuilder,
 	client gcpclient.GCP,
 ) *pubsubConsumer {
-	go func(ctx context.Context, log *logrus.Logger, client gcpclient.GCP) {
-		err := client.Listen(ctx, func(ctx context.Context, data []byte) error {
+	loop := &health.Loop{}
+	loop.Go(func() error {
+		err := client.Listen(ctx, func(ctx context.Context, data []byte, ack entities.AckFunc) error {
 			event, err := eventBuilder.GetPipelineEvent(ctx, data)
 			if err != nil {
 				return err
 			}
 
+			event.WithAck(ack)
 			pipeline.AddMessage(event)
 			return nil
 		})
@@ -41,15 +45,22 @@ func newPubSub(
 		}
 
 		client.Close()
-	}(ctx, log, client)
+		return err
+	})
 
 	return &pubsubConsumer{
 		log:      log,
 		client:   client,
 		pipeline: pipeline,
+		loop:     loop,
 	}
 }
 
 func (g *pubsubConsumer) Close() error {
 	return g.client.Close()
 }
+
+// HealthCheck reports whether the consumer is still listening to the subscription.
+func (g *pubsubConsumer) HealthCheck(ctx context.Context) error {
+	return g.loop.HealthCheck(ctx)
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/mia-platform/integration-connector-agent/entities"
+	"github.com/mia-platform/integration-connector-agent/internal/health"
 	"github.com/mia-platform/integration-connector-agent/internal/pipeline"
 	"github.com/mia-platform/integration-connector-agent/internal/sources/gcp-pubsub/gcpclient"
 
@@ -72,8 +73,20 @@ func TestClientIntegrationWithEventBuilder(t *testing.T) {
 				require.NotNil(t, handler)
 
 				// Simulate receiving a message from Pub/Sub
-				err := handler(ctx, dataFromPubSub)
+				var ackErr error
+				acked := false
+				err := handler(ctx, dataFromPubSub, func(err error) {
+					acked = true
+					ackErr = err
+				})
 				require.NoError(t, err)
+
+				// the message is acked once the pipelines have processed the event
+				require.Len(t, pg.Messages, 1)
+				require.False(t, acked)
+				pg.Messages[0].Ack(nil)
+				require.True(t, acked)
+				require.NoError(t, ackErr)
 			},
 		}
 
@@ -84,10 +97,14 @@ func TestClientIntegrationWithEventBuilder(t *testing.T) {
 		time.Sleep(10 * time.Millisecond)
 		require.True(t, client.ListenInvoked())
 		require.False(t, client.CloseInvoked())
+		require.NoError(t, consumer.HealthCheck(ctx))
 
 		cancel()
 		time.Sleep(10 * time.Millisecond)
 		require.True(t, client.CloseInvoked())
+		require.Eventually(t, func() bool {
+			return errors.Is(consumer.HealthCheck(ctx), health.ErrLoopStopped)
+		}, time.Second, 10*time.Millisecond)
 	})
 
 	t.Run("messages are not sent to the pipeline on event builder error", func(t *testing.T) {
@@ -113,7 +130,7 @@ func TestClientIntegrationWithEventBuilder(t *testing.T) {
 				require.NotNil(t, handler)
 
 				// Simulate receiving a message from Pub/Sub
-				err := handler(ctx, dataFromPubSub)
+				err := handler(ctx, dataFromPubSub, nil)
 				require.Error(t, err, "some error from event builder")
 			},
 		}
@@ -183,9 +200,9 @@ func TestClientIntegrationWithEventBuilder(t *testing.T) {
 		defer handlerRefLock.Unlock()
 		require.NotNil(t, handlerRef)
 
-		require.NoError(t, handlerRef(ctx, dataFromPubSub))
-		require.Error(t, handlerRef(ctx, []byte("failing payload")), "failing to process payload")
-		require.NoError(t, handlerRef(ctx, dataFromPubSub))
+		require.NoError(t, handlerRef(ctx, dataFromPubSub, nil))
+		require.Error(t, handlerRef(ctx, []byte("failing payload"), nil), "failing to process payload")
+		require.NoError(t, handlerRef(ctx, dataFromPubSub, nil))
 
 		cancel()
 		time.Sleep(10 * time.Millisecond)
@@ -116,35 +116,34 @@ func (p *concrete) Listen(ctx context.Context, handler ListenerFunc) error {
 
 func handlerPubSubMessage(p *concrete, handler ListenerFunc) func(ctx context.Context, msg *pubsub.Message) {
 	return func(ctx context.Context, msg *pubsub.Message) {
-		p.log.WithFields(logrus.Fields{
+		logFields := logrus.Fields{
 			"projectId":      p.config.ProjectID,
 			"topicName":      p.config.TopicName,
 			"subscriptionId": p.config.SubscriptionID,
 			"messageId":      msg.ID,
-		}).Trace("received message from Pub/Sub")
+		}
+		p.log.WithFields(logFields).Trace("received message from Pub/Sub")
+
+		// the message is acked only once all the pipelines have written it, so that
+		// it is delivered again if any of them fails
+		ack := func(err error) {
+			if err != nil {
+				p.log.WithFields(logFields).WithError(err).Warn("message not processed, it will be delivered again")
+				msg.Nack()
+				return
+			}
+			msg.Ack()
+		}
 
-		if err := handler(ctx, msg.Data); err != nil {
+		if err := handler(ctx, msg.Data, ack); err != nil {
 			p.log.
-				WithFields(logrus.Fields{
-					"projectId":      p.config.ProjectID,
-					"topicName":      p.config.TopicName,
-					"subscriptionId": p.config.SubscriptionID,
-					"messageId":      msg.ID,
-				}).
+				WithFields(logFields).
 				WithError(err).
 				Error("error handling message")
 
 			msg.Nack()
 			return
 		}
-
-		// TODO: message is Acked here once the pipelines have received the message for processing.
-		// This means that if the pipeline fails after this point, the message will not be
-		// retried. Consider implementing, either:
-		// - a dead-letter queue or similar mechanism.
-		// - a way to be notified here if all the pipelins have processed the
-		//   message successfully in order to correctly ack/nack it.
-		msg.Ack()
 	}
 }
 
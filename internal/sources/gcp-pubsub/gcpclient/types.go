@@ -6,6 +6,8 @@ package gcpclient
 import (
 	"context"
 
+	"github.com/mia-platform/integration-connector-agent/entities"
+
 	"cloud.google.com/go/asset/apiv1/assetpb"
 )
 
@@ -14,7 +16,9 @@ const (
 	InventoryEventFunctionPrefix = "//run.googleapis.com/"
 )
 
-type ListenerFunc func(ctx context.Context, data []byte) error
+// ListenerFunc handles a message received from the queue. The message is acknowledged only
+// once ack is invoked without error: on error, or if the handler fails, it is delivered again.
+type ListenerFunc func(ctx context.Context, data []byte, ack entities.AckFunc) error
 
 type GCP interface {
 	ListAssets(ctx context.Context) ([]*assetpb.Asset, error)
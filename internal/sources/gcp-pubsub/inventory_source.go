@@ -11,6 +11,7 @@ import (
 	"net/http"
 
 	"github.com/mia-platform/integration-connector-agent/internal/config"
+	"github.com/mia-platform/integration-connector-agent/internal/health"
 	"github.com/mia-platform/integration-connector-agent/internal/pipeline"
 	"github.com/mia-platform/integration-connector-agent/internal/sources"
 	gcppubsubevents "github.com/mia-platform/integration-connector-agent/internal/sources/gcp-pubsub/events"
@@ -55,7 +56,7 @@ type InventorySource struct {
 	pipeline pipeline.IPipelineGroup
 
 	gcp    gcpclient.GCP
-	pubsub sources.CloseableSource
+	pubsub *pubsubConsumer
 	router *swagger.Router[fiber.Handler, fiber.Router]
 }
 
@@ -134,6 +135,14 @@ func (s *InventorySource) Close() error {
 	return nil
 }
 
+// HealthCheck reports whether the source is still receiving messages from Pub/Sub.
+func (s *InventorySource) HealthCheck(ctx context.Context) error {
+	if s.pubsub == nil {
+		return health.ErrLoopStopped
+	}
+	return s.pubsub.HealthCheck(ctx)
+}
+
 func (s *InventorySource) registerImportWebhook() error {
 	apiPath := s.config.WebhookPath
 
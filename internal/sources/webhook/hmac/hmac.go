@@ -62,18 +62,26 @@ func (a Authentication) Validate() error {
 	return nil
 }
 
+// Signature returns the value of the signature header of a request with bodyData, signed with secret,
+// as expected by CheckSignature.
+func Signature(bodyData []byte, secret string) string {
+	return "sha256=" + hex.EncodeToString(sign(bodyData, secret))
+}
+
 // validateBody will generate an hmac encoding of bodyData using secret, and than compare it with the expectedSignature
 func validateBody(bodyData []byte, secret, expectedSignature string) bool {
-	hasher := hmac.New(sha256.New, []byte(secret))
-	hasher.Write(bodyData)
-	generatedMAC := hasher.Sum(nil)
-
 	expectedMac, err := hex.DecodeString(expectedSignature)
 	if err != nil {
 		return false
 	}
 
-	return hmac.Equal(generatedMAC, expectedMac)
+	return hmac.Equal(sign(bodyData, secret), expectedMac)
+}
+
+func sign(bodyData []byte, secret string) []byte {
+	hasher := hmac.New(sha256.New, []byte(secret))
+	hasher.Write(bodyData)
+	return hasher.Sum(nil)
 }
 
 func hmacSignatureHeaderValue(req webhook.ValidatingRequest, headerName, secret string) (string, error) {
@@ -117,6 +117,20 @@ func TestCheckHMACSignature(t *testing.T) {
 	}
 }
 
+func TestSignature(t *testing.T) {
+	t.Parallel()
+
+	signature := Signature([]byte("Hello World!"), "It's a Secret to Everybody")
+	assert.Equal(t, "sha256=a4771c39fbe90f317c7824e83ddef3caae9cb3d976c214ace1f2937e133263c9", signature)
+
+	authentication := Authentication{HeaderName: "X-Hub-Signature", Secret: "It's a Secret to Everybody"}
+	err := authentication.CheckSignature(fakeValidatingRequest{
+		body:    []byte("Hello World!"),
+		headers: map[string][]string{"X-Hub-Signature": {signature}},
+	})
+	assert.NoError(t, err)
+}
+
 type fakeValidatingRequest struct {
 	headers map[string][]string
 	body    []byte
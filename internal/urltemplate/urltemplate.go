@@ -0,0 +1,71 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package urltemplate
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// placeholderRegexp matches the placeholders of the mapper templates.
+var placeholderRegexp = regexp.MustCompile(`{{\s*(.*?)\s*}}`)
+
+// Template is a URL whose placeholders are mapper templates. The values of the placeholders in
+// the path are escaped as path segments, and the ones in the query as query values, except for
+// a placeholder at the start of the URL, which sets its scheme and host.
+type Template struct {
+	parts []part
+}
+
+// part is a piece of the URL template: a text kept as is, or a placeholder whose value is read
+// from the mapped event at key, and escaped with escape.
+type part struct {
+	text   string
+	key    string
+	escape func(string) string
+}
+
+// Parse splits the URL template in its texts and placeholders, and returns the mapper templates
+// of the placeholders, by key. The keys start with keyPrefix, followed by the index of the placeholder.
+func Parse(template, keyPrefix string) (*Template, map[string]string) {
+	templates := make(map[string]string)
+	parts := make([]part, 0)
+	inQuery := false
+	last := 0
+	for i, match := range placeholderRegexp.FindAllStringIndex(template, -1) {
+		text := template[last:match[0]]
+		inQuery = inQuery || strings.Contains(text, "?")
+		parts = append(parts, part{text: text})
+
+		key := fmt.Sprintf("%s%d", keyPrefix, i)
+		templates[key] = template[match[0]:match[1]]
+		placeholder := part{key: key, escape: url.PathEscape}
+		switch {
+		case match[0] == 0:
+			placeholder.escape = func(s string) string { return s }
+		case inQuery:
+			placeholder.escape = url.QueryEscape
+		}
+		parts = append(parts, placeholder)
+		last = match[1]
+	}
+	return &Template{parts: append(parts, part{text: template[last:]})}, templates
+}
+
+// Build returns the URL, with the values of the placeholders read from the output of the mapper.
+func (t *Template) Build(mapped []byte) string {
+	var url strings.Builder
+	for _, part := range t.parts {
+		if part.key == "" {
+			url.WriteString(part.text)
+			continue
+		}
+		url.WriteString(part.escape(gjson.GetBytes(mapped, part.key).String()))
+	}
+	return url.String()
+}
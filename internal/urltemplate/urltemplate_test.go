@@ -0,0 +1,26 @@
+// Copyright Mia srl
+// SPDX-License-Identifier: AGPL-3.0-only or Commercial
+
+package urltemplate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplate(t *testing.T) {
+	template, templates := Parse("{{ base }}/issues/{{ issue.key }}/comments?author={{ author }}", "url")
+	require.Equal(t, map[string]string{
+		"url0": "{{ base }}",
+		"url1": "{{ issue.key }}",
+		"url2": "{{ author }}",
+	}, templates)
+
+	url := template.Build([]byte(`{"url0":"https://jira.example.com","url1":"a/b c#d","url2":"x&y=z"}`))
+	require.Equal(t, "https://jira.example.com/issues/a%2Fb%20c%23d/comments?author=x%26y%3Dz", url)
+
+	template, templates = Parse("https://jira.example.com/health", "url")
+	require.Empty(t, templates)
+	require.Equal(t, "https://jira.example.com/health", template.Build([]byte(`{}`)))
+}
@@ -31,9 +31,11 @@ func main() {
 
 	sysChan := make(chan os.Signal, 1)
 	signal.Notify(sysChan, syscall.SIGTERM)
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
 	exitCode := 0
 
-	if err := server.New(context.Background(), envVars, config, sysChan); err != nil {
+	if err := server.New(context.Background(), envVars, config, sysChan, reloadChan); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		exitCode = 1
 	}